@@ -0,0 +1,159 @@
+// Copyright Safing ICS Technologies GmbH. Use of this source code is governed by the AGPL license that can be found in the LICENSE file.
+
+package algs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	ngramStart = '^'
+	ngramEnd   = '$'
+	// ngramAlphabetSize is the number of characters that may occur in n-grams of domain labels: a-z, 0-9, "-", start and end.
+	ngramAlphabetSize = 39
+)
+
+// NgramModel scores how much a string resembles the strings the model was trained with, based on character n-grams.
+type NgramModel struct {
+	N int
+	// LogProbs holds the log probability of every known n-gram.
+	LogProbs map[string]float64
+	// Unknown is the log probability of n-grams that are not in the model.
+	Unknown float64
+	// Threshold is the score below which a string is regarded as random.
+	Threshold float64
+}
+
+// TrainNgramModel creates a new model with n-grams of length n from the given samples. The threshold is set so that the given fraction of samples scores above it.
+func TrainNgramModel(n int, samples []string, acceptRate float64) *NgramModel {
+	counts := make(map[string]int)
+	var total int
+	for _, sample := range samples {
+		for _, ngram := range ngrams(n, sample) {
+			counts[ngram]++
+			total++
+		}
+	}
+
+	// add-one smoothing
+	possible := math.Pow(ngramAlphabetSize, float64(n))
+	model := &NgramModel{
+		N:        n,
+		LogProbs: make(map[string]float64, len(counts)),
+		Unknown:  math.Log(1 / (float64(total) + possible)),
+	}
+	for ngram, count := range counts {
+		model.LogProbs[ngram] = math.Log(float64(count+1) / (float64(total) + possible))
+	}
+
+	// set threshold
+	if len(samples) > 0 {
+		scores := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			scores = append(scores, model.Score(sample))
+		}
+		model.Threshold = quantile(scores, 1-acceptRate)
+	}
+
+	return model
+}
+
+// LoadNgramModel loads a JSON encoded model.
+func LoadNgramModel(r io.Reader) (*NgramModel, error) {
+	model := &NgramModel{}
+	err := json.NewDecoder(r).Decode(model)
+	if err != nil {
+		return nil, err
+	}
+	if model.N < 1 || len(model.LogProbs) == 0 {
+		return nil, errors.New("model is empty")
+	}
+	return model, nil
+}
+
+// Score returns the average log probability of the n-grams of the given string. Higher is more familiar.
+func (m *NgramModel) Score(s string) float64 {
+	grams := ngrams(m.N, s)
+	if len(grams) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, ngram := range grams {
+		logProb, ok := m.LogProbs[ngram]
+		if !ok {
+			logProb = m.Unknown
+		}
+		sum += logProb
+	}
+	return sum / float64(len(grams))
+}
+
+// IsRandom returns whether the given string scores below the threshold of the model.
+func (m *NgramModel) IsRandom(s string) bool {
+	return m.Score(s) < m.Threshold
+}
+
+// Randomness returns how far the score of the given string lies below the threshold, from 0 (at or above the threshold) to 1 (only unknown n-grams).
+func (m *NgramModel) Randomness(s string) float64 {
+	span := m.Threshold - m.Unknown
+	if span <= 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(1, (m.Threshold-m.Score(s))/span))
+}
+
+func ngrams(n int, s string) []string {
+	padded := string(ngramStart) + strings.ToLower(s) + string(ngramEnd)
+	if len(padded) < n {
+		return nil
+	}
+
+	grams := make([]string, 0, len(padded)-n+1)
+	for i := 0; i+n <= len(padded); i++ {
+		grams = append(grams, padded[i:i+n])
+	}
+	return grams
+}
+
+// quantile returns the value below which the given fraction of values lie.
+func quantile(values []float64, fraction float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	index := int(fraction * float64(len(sorted)))
+	switch {
+	case index < 0:
+		index = 0
+	case index >= len(sorted):
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// Entropy returns the Shannon entropy of the given string in bits per character.
+func Entropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	var length int
+	for _, c := range s {
+		counts[c]++
+		length++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(length)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,65 @@
+// Copyright Safing ICS Technologies GmbH. Use of this source code is governed by the AGPL license that can be found in the LICENSE file.
+
+package algs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+var testCorpus = []string{
+	"google", "facebook", "amazon", "microsoft", "wikipedia", "netflix", "download", "update", "network",
+	"service", "security", "cloud", "account", "support", "mail", "search", "shop", "online", "store",
+	"media", "news", "weather", "music", "video", "photo", "travel", "market", "bank", "world", "portal",
+}
+
+func TestNgramModel(t *testing.T) {
+	model := TrainNgramModel(2, testCorpus, 0.9)
+
+	for _, label := range []string{"microsoft", "cloudstore", "newsportal"} {
+		if model.IsRandom(label) {
+			t.Errorf("%s should not be regarded as random (score %.2f, threshold %.2f)", label, model.Score(label), model.Threshold)
+		}
+	}
+	for _, label := range []string{"xjwqpzkrtv", "kq3v9z1x7ht2", "qwhfjjsyqgrw"} {
+		if !model.IsRandom(label) {
+			t.Errorf("%s should be regarded as random (score %.2f, threshold %.2f)", label, model.Score(label), model.Threshold)
+		}
+		if model.Randomness(label) <= 0 {
+			t.Errorf("%s should have a randomness above zero", label)
+		}
+	}
+
+	// save and load
+	data, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNgramModel(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Score("microsoft") != model.Score("microsoft") {
+		t.Error("loaded model scores differently")
+	}
+
+	_, err = LoadNgramModel(bytes.NewReader([]byte("{}")))
+	if err == nil {
+		t.Error("empty model should fail to load")
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	testEntropy(t, "", 0, 0)
+	testEntropy(t, "aaaa", 0, 0)
+	testEntropy(t, "abab", 1, 1)
+	testEntropy(t, "abcdefgh", 3, 3)
+}
+
+func testEntropy(t *testing.T, s string, min, max float64) {
+	entropy := Entropy(s)
+	if entropy < min || entropy > max {
+		t.Errorf("%q has an entropy of %.2f, but should be between %.0f and %.0f", s, entropy, min, max)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portmaster/internal/apiutil"
+	"github.com/Safing/portmaster/internal/modstatus"
+)
+
+const (
+	healthCheckKey = "core:status/health"
+)
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/core/v1/health", handleHealthCheck).Methods("GET")
+	api.RegisterHandleFunc("/api/core/v1/modules", handleGetModules).Methods("GET")
+	return nil
+}
+
+// handleHealthCheck reports whether the core is responsive. It accesses the database, as a core that is stuck usually fails there first.
+func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	_, err := database.NewInterface(nil).Get(healthCheckKey)
+	if err != nil && err != database.ErrNotFound {
+		http.Error(w, fmt.Sprintf("database unavailable: %s", err), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGetModules reports the state of all modules.
+func handleGetModules(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(w, modstatus.All())
+}
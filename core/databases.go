@@ -2,8 +2,8 @@ package core
 
 import (
 	"github.com/Safing/portbase/database"
-	"github.com/Safing/portbase/modules"
 	"github.com/Safing/portbase/notifications"
+	"github.com/Safing/portmaster/internal/modstatus"
 
 	// module dependencies
 	_ "github.com/Safing/portbase/database/dbmodule"
@@ -11,11 +11,15 @@ import (
 )
 
 func init() {
-	modules.Register("core", nil, start, nil, "database")
+	modstatus.Register("core", prep, start, nil, "database", "api")
 
 	notifications.SetPersistenceBasePath("core:notifications")
 }
 
+func prep() error {
+	return registerAPI()
+}
+
 func start() error {
 	_, err := database.Register(&database.Database{
 		Name:        "core",
@@ -0,0 +1,74 @@
+package firewall
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portmaster/firewall/interception"
+	"github.com/Safing/portmaster/internal/apiutil"
+)
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/firewall/v1/prompts", handleListPrompts).Methods("GET")
+	api.RegisterHandleFunc("/api/firewall/v1/prompts/{id:.+}/respond", apiutil.Protect(handleRespondToPrompt)).Methods("POST")
+	api.RegisterHandleFunc("/api/firewall/v1/interception", handleGetInterceptionStatus).Methods("GET")
+	return nil
+}
+
+// interceptionStatus describes the state of the packet interception for diagnostics.
+type interceptionStatus struct {
+	Rules      map[string]bool
+	RulesError string `json:",omitempty"`
+	// packet counters since the last statistics interval
+	PacketsAccepted uint64
+	PacketsBlocked  uint64
+	PacketsDropped  uint64
+	// process attribution counters since the last statistics interval
+	AttributionRetried   uint64
+	AttributionRecovered uint64
+	AttributionFailed    uint64
+}
+
+func handleGetInterceptionStatus(w http.ResponseWriter, r *http.Request) {
+	is := &interceptionStatus{
+		PacketsAccepted: atomic.LoadUint64(packetsAccepted),
+		PacketsBlocked:  atomic.LoadUint64(packetsBlocked),
+		PacketsDropped:  atomic.LoadUint64(packetsDropped),
+
+		AttributionRetried:   atomic.LoadUint64(attributionRetried),
+		AttributionRecovered: atomic.LoadUint64(attributionRecovered),
+		AttributionFailed:    atomic.LoadUint64(attributionFailed),
+	}
+
+	var err error
+	is.Rules, err = interception.CheckRules()
+	if err != nil {
+		is.RulesError = err.Error()
+	}
+	apiutil.WriteJSON(w, is)
+}
+
+func handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(w, GetPrompts())
+}
+
+func handleRespondToPrompt(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Query().Get("action")
+	if action == "" {
+		http.Error(w, "action is required", http.StatusBadRequest)
+		return
+	}
+
+	err := RespondToPrompt(mux.Vars(r)["id"], action)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+	case ErrPromptNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
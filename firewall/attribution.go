@@ -0,0 +1,107 @@
+package firewall
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/network/packet"
+	"github.com/Safing/portmaster/process"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	attributionRetried   *uint64
+	attributionRecovered *uint64
+	attributionFailed    *uint64
+
+	attributionInitialBackoff = 10 * time.Millisecond
+	// attributionMaxRetryTimeout is the highest configurable retry timeout. Senders retransmit dropped packets after about a second, so longer timeouts only delay connections.
+	attributionMaxRetryTimeout = 2 * time.Second
+
+	// getCommunicationByFirstPacket is replaced in tests.
+	getCommunicationByFirstPacket = network.GetCommunicationByFirstPacket
+)
+
+// attributionRetryTimeout returns how long to retry finding the owner of a connection in the given security level.
+func attributionRetryTimeout(securityLevel uint8) time.Duration {
+	var ms int64
+	switch securityLevel {
+	case status.SecurityLevelFortress:
+		ms = attributionRetryTimeoutFortress()
+	case status.SecurityLevelSecure:
+		ms = attributionRetryTimeoutSecure()
+	default:
+		ms = attributionRetryTimeoutDynamic()
+	}
+
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout > attributionMaxRetryTimeout {
+		return attributionMaxRetryTimeout
+	}
+	return timeout
+}
+
+// retryAttribution retries finding the process of an outbound connection in the background, as its socket may not yet be visible. The packet queue is not held up meanwhile: packets of the link are dropped until the process was found, leaving it to the sender to retransmit them. It reports whether the link is retried, otherwise it must be decided on right away.
+func retryAttribution(pkt packet.Packet, link *network.Link, err error) bool {
+	// other errors, eg. unsupported protocols or inbound packets to closed ports, will not resolve by waiting
+	if !pkt.IsOutbound() || err != process.ErrConnectionNotFound {
+		atomic.AddUint64(attributionFailed, 1)
+		return false
+	}
+	timeout := attributionRetryTimeout(status.ActiveSecurityLevel())
+	if timeout <= 0 {
+		atomic.AddUint64(attributionFailed, 1)
+		return false
+	}
+	atomic.AddUint64(attributionRetried, 1)
+
+	link.SetFirewallHandler(attributionPendingHandler)
+	pkt.Drop()
+
+	go func() {
+		comm, err := getCommunicationWithRetry(pkt, timeout)
+		link.SetFirewallHandler(func(pkt packet.Packet, link *network.Link) {
+			handleCommunication(pkt, link, comm, err)
+		})
+	}()
+	return true
+}
+
+// attributionPendingHandler drops packets of links whose process is still being searched for.
+func attributionPendingHandler(pkt packet.Packet, link *network.Link) {
+	log.Tracer(pkt.Ctx()).Trace("firewall: dropping packet, process is not yet known")
+	pkt.Drop()
+}
+
+// getCommunicationWithRetry retries finding the communication of the given packet with an exponential backoff until the timeout is reached. It blocks and must not be called within the packet handling.
+func getCommunicationWithRetry(pkt packet.Packet, timeout time.Duration) (comm *network.Communication, err error) {
+	started := time.Now()
+	deadline := started.Add(timeout)
+	backoff := attributionInitialBackoff
+	for tries := 1; ; tries++ {
+		// do not wait beyond the deadline
+		wait := deadline.Sub(time.Now())
+		if wait <= 0 {
+			break
+		}
+		if backoff < wait {
+			wait = backoff
+		}
+		time.Sleep(wait)
+
+		comm, err = getCommunicationByFirstPacket(pkt)
+		if err == nil {
+			atomic.AddUint64(attributionRecovered, 1)
+			log.Tracer(pkt.Ctx()).Tracef("firewall: found process after %d retries (%s)", tries, time.Now().Sub(started))
+			return comm, nil
+		}
+
+		backoff *= 2
+	}
+
+	atomic.AddUint64(attributionFailed, 1)
+	log.Tracer(pkt.Ctx()).Tracef("firewall: giving up on finding process after %s", time.Now().Sub(started))
+	return nil, err
+}
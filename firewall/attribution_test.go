@@ -0,0 +1,159 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/network/packet"
+	"github.com/Safing/portmaster/process"
+)
+
+// testPacket is a packet without a connection to the packet interception.
+type testPacket struct {
+	packet.Base
+	dropped bool
+}
+
+func (pkt *testPacket) Accept() error              { return nil }
+func (pkt *testPacket) Block() error               { return nil }
+func (pkt *testPacket) Drop() error                { pkt.dropped = true; return nil }
+func (pkt *testPacket) PermanentAccept() error     { return nil }
+func (pkt *testPacket) PermanentBlock() error      { return nil }
+func (pkt *testPacket) PermanentDrop() error       { return nil }
+func (pkt *testPacket) RerouteToNameserver() error { return nil }
+func (pkt *testPacket) RerouteToTunnel() error     { return nil }
+
+func TestGetCommunicationWithRetry(t *testing.T) {
+	oldGet := getCommunicationByFirstPacket
+	oldDynamic, oldSecure, oldFortress := attributionRetryTimeoutDynamic, attributionRetryTimeoutSecure, attributionRetryTimeoutFortress
+	oldRetried, oldRecovered, oldFailed := attributionRetried, attributionRecovered, attributionFailed
+	defer func() {
+		getCommunicationByFirstPacket = oldGet
+		attributionRetryTimeoutDynamic, attributionRetryTimeoutSecure, attributionRetryTimeoutFortress = oldDynamic, oldSecure, oldFortress
+		attributionRetried, attributionRecovered, attributionFailed = oldRetried, oldRecovered, oldFailed
+	}()
+
+	setTimeout := func(ms int64) {
+		timeout := func() int64 { return ms }
+		attributionRetryTimeoutDynamic, attributionRetryTimeoutSecure, attributionRetryTimeoutFortress = timeout, timeout, timeout
+	}
+	resetCounters := func() {
+		attributionRetried, attributionRecovered, attributionFailed = new(uint64), new(uint64), new(uint64)
+	}
+	checkCounters := func(name string, retried, recovered, failed uint64) {
+		if atomic.LoadUint64(attributionRetried) != retried ||
+			atomic.LoadUint64(attributionRecovered) != recovered ||
+			atomic.LoadUint64(attributionFailed) != failed {
+			t.Errorf("%s: expected retried=%d recovered=%d failed=%d, got retried=%d recovered=%d failed=%d",
+				name, retried, recovered, failed,
+				atomic.LoadUint64(attributionRetried), atomic.LoadUint64(attributionRecovered), atomic.LoadUint64(attributionFailed))
+		}
+	}
+	// lookupSucceedsAfter fails the given amount of lookups
+	lookupSucceedsAfter := func(failures int) *int32 {
+		var lookups int32
+		getCommunicationByFirstPacket = func(pkt packet.Packet) (*network.Communication, error) {
+			if int(atomic.AddInt32(&lookups, 1)) > failures {
+				return &network.Communication{}, nil
+			}
+			return nil, process.ErrConnectionNotFound
+		}
+		return &lookups
+	}
+	newPacket := func(inbound bool) *testPacket {
+		pkt := &testPacket{}
+		pkt.SetCtx(context.Background())
+		if inbound {
+			pkt.SetInbound()
+		}
+		return pkt
+	}
+
+	// success after some retries
+	resetCounters()
+	lookups := lookupSucceedsAfter(3)
+	comm, err := getCommunicationWithRetry(newPacket(false), time.Second)
+	if err != nil || comm == nil {
+		t.Errorf("retry success: expected communication, got %s", err)
+	}
+	if *lookups != 4 {
+		t.Errorf("retry success: expected 4 lookups, got %d", *lookups)
+	}
+	checkCounters("retry success", 0, 1, 0)
+
+	// retries exhausted when the timeout is reached
+	resetCounters()
+	lookups = lookupSucceedsAfter(1000)
+	started := time.Now()
+	_, err = getCommunicationWithRetry(newPacket(false), 100*time.Millisecond)
+	elapsed := time.Since(started)
+	if err != process.ErrConnectionNotFound {
+		t.Errorf("retry exhaustion: expected lookup error, got %v", err)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("retry exhaustion: expected to give up after the timeout of 100ms, took %s", elapsed)
+	}
+	if *lookups < 2 {
+		t.Errorf("retry exhaustion: expected retries, got %d lookups", *lookups)
+	}
+	checkCounters("retry exhaustion", 0, 0, 1)
+
+	// the timeout is capped
+	setTimeout(60000)
+	if timeout := attributionRetryTimeout(0); timeout != attributionMaxRetryTimeout {
+		t.Errorf("timeout cap: expected %s, got %s", attributionMaxRetryTimeout, timeout)
+	}
+
+	// errors that will not resolve by waiting are not retried
+	testCases := []struct {
+		name    string
+		inbound bool
+		err     error
+		timeout int64
+	}{
+		{"inbound", true, process.ErrConnectionNotFound, 100},
+		{"other error", false, errors.New("unsupported protocol for finding process"), 100},
+		{"no timeout", false, process.ErrConnectionNotFound, 0},
+	}
+	for _, tc := range testCases {
+		resetCounters()
+		setTimeout(tc.timeout)
+		pkt := newPacket(tc.inbound)
+		if retryAttribution(pkt, &network.Link{}, tc.err) {
+			t.Errorf("%s: expected no retry", tc.name)
+		}
+		if pkt.dropped {
+			t.Errorf("%s: packet must be decided on by the caller", tc.name)
+		}
+		checkCounters(tc.name, 0, 0, 1)
+	}
+
+	// outbound connections that are not yet visible are retried in the background
+	resetCounters()
+	setTimeout(100)
+	lookupSucceedsAfter(1)
+	pkt := newPacket(false)
+	link := &network.Link{}
+	started = time.Now()
+	if !retryAttribution(pkt, link, process.ErrConnectionNotFound) {
+		t.Error("background retry: expected outbound connection to be retried")
+	}
+	if time.Since(started) > 50*time.Millisecond {
+		t.Errorf("background retry: packet handling was blocked for %s", time.Since(started))
+	}
+	if !pkt.dropped || !link.FirewallHandlerIsSet() {
+		t.Error("background retry: expected packet to be dropped while the link is pending")
+	}
+	checkCounters("background retry", 1, 0, 0)
+
+	// the link is decided on once the process was found
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadUint64(attributionRecovered) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkCounters("background retry finished", 1, 1, 0)
+}
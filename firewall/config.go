@@ -9,6 +9,22 @@ var (
 	permanentVerdicts  config.BoolOption
 	filterDNSByScope   status.SecurityLevelOption
 	filterDNSByProfile status.SecurityLevelOption
+
+	attributionRetryTimeoutDynamic  config.IntOption
+	attributionRetryTimeoutSecure   config.IntOption
+	attributionRetryTimeoutFortress config.IntOption
+
+	vpnKillSwitch           config.BoolOption
+	vpnInterfaces           config.StringArrayOption
+	vpnKillSwitchExemptions config.StringArrayOption
+	defaultVPNClients       = []string{
+		"/usr/sbin/openvpn",
+		"/usr/bin/openvpn",
+		"/usr/sbin/openconnect",
+		"/usr/bin/openconnect",
+		"/usr/sbin/vpnc",
+		"/usr/sbin/charon",
+	}
 )
 
 func registerConfig() error {
@@ -55,5 +71,86 @@ func registerConfig() error {
 	}
 	filterDNSByProfile = status.ConfigIsActiveConcurrent("firewall/filterDNSByProfile")
 
+	err = config.Register(&config.Option{
+		Name:            "Process Attribution Retry Timeout (Dynamic)",
+		Key:             "firewall/attributionRetryTimeoutDynamic",
+		Description:     "How long to retry finding the process of a new outgoing connection before treating it as unknown, in milliseconds (up to 2000). Packets of the connection are dropped meanwhile. Applies in the Dynamic security level.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    200,
+		ValidationRegex: "^([0-9]{1,3}|1[0-9]{3}|2000)$",
+	})
+	if err != nil {
+		return err
+	}
+	attributionRetryTimeoutDynamic = config.Concurrent.GetAsInt("firewall/attributionRetryTimeoutDynamic", 200)
+
+	err = config.Register(&config.Option{
+		Name:            "Process Attribution Retry Timeout (Secure)",
+		Key:             "firewall/attributionRetryTimeoutSecure",
+		Description:     "How long to retry finding the process of a new outgoing connection before treating it as unknown, in milliseconds (up to 2000). Packets of the connection are dropped meanwhile. Applies in the Secure security level.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    100,
+		ValidationRegex: "^([0-9]{1,3}|1[0-9]{3}|2000)$",
+	})
+	if err != nil {
+		return err
+	}
+	attributionRetryTimeoutSecure = config.Concurrent.GetAsInt("firewall/attributionRetryTimeoutSecure", 100)
+
+	err = config.Register(&config.Option{
+		Name:            "Process Attribution Retry Timeout (Fortress)",
+		Key:             "firewall/attributionRetryTimeoutFortress",
+		Description:     "How long to retry finding the process of a new outgoing connection before treating it as unknown, in milliseconds (up to 2000). Packets of the connection are dropped meanwhile. Applies in the Fortress security level.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    50,
+		ValidationRegex: "^([0-9]{1,3}|1[0-9]{3}|2000)$",
+	})
+	if err != nil {
+		return err
+	}
+	attributionRetryTimeoutFortress = config.Concurrent.GetAsInt("firewall/attributionRetryTimeoutFortress", 50)
+
+	err = config.Register(&config.Option{
+		Name:           "VPN Kill Switch",
+		Key:            "firewall/vpnKillSwitch",
+		Description:    "Deny all connections to the Internet that would not go through a VPN interface, including those of the Portmaster itself. Connections within the LAN and to localhost are not affected. Connections are not marked permanently while enabled, so that they can be checked again when the VPN goes away.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeBool,
+		DefaultValue:   false,
+	})
+	if err != nil {
+		return err
+	}
+	vpnKillSwitch = config.Concurrent.GetAsBool("firewall/vpnKillSwitch", false)
+
+	err = config.Register(&config.Option{
+		Name:           "VPN Interfaces",
+		Key:            "firewall/vpnInterfaces",
+		Description:    "Names of network interfaces that are regarded as VPN interfaces by the VPN kill switch, wildcards like \"corp*\" are supported. Tun, tap and WireGuard interfaces are detected automatically.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeStringArray,
+		DefaultValue:   []string{},
+	})
+	if err != nil {
+		return err
+	}
+	vpnInterfaces = config.Concurrent.GetAsStringArray("firewall/vpnInterfaces", []string{})
+
+	err = config.Register(&config.Option{
+		Name:           "VPN Kill Switch Exemptions",
+		Key:            "firewall/vpnKillSwitchExemptions",
+		Description:    "Paths of programs that are not affected by the VPN kill switch. Your VPN client must be listed here in order to connect to the VPN server.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeStringArray,
+		DefaultValue:   defaultVPNClients,
+	})
+	if err != nil {
+		return err
+	}
+	vpnKillSwitchExemptions = config.Concurrent.GetAsStringArray("firewall/vpnKillSwitchExemptions", defaultVPNClients)
+
 	return nil
 }
@@ -5,10 +5,12 @@ import (
 	"net"
 
 	"github.com/Safing/portmaster/intel"
+	"github.com/Safing/portmaster/updates"
 )
 
 func init() {
 	intel.SetLocalAddrFactory(PermittedAddr)
+	updates.SetLocalAddrFactory(PermittedAddr)
 }
 
 // PermittedAddr returns an already permitted local address for the given network for reliable connectivity.
@@ -12,8 +12,10 @@ import (
 	"github.com/Safing/portbase/modules"
 	"github.com/Safing/portmaster/firewall/inspection"
 	"github.com/Safing/portmaster/firewall/interception"
+	"github.com/Safing/portmaster/internal/modstatus"
 	"github.com/Safing/portmaster/network"
 	"github.com/Safing/portmaster/network/packet"
+	"github.com/Safing/portmaster/threats/portscan"
 
 	// module dependencies
 	_ "github.com/Safing/portmaster/core"
@@ -40,7 +42,7 @@ var (
 )
 
 func init() {
-	modules.Register("firewall", prep, start, stop, "core", "network", "nameserver", "profile", "updates")
+	modstatus.Register("firewall", prep, start, stop, "core", "network", "nameserver", "profile", "updates", "api")
 }
 
 func prep() (err error) {
@@ -50,6 +52,11 @@ func prep() (err error) {
 		return err
 	}
 
+	err = registerAPI()
+	if err != nil {
+		return err
+	}
+
 	_, localNet4, err = net.ParseCIDR("127.0.0.0/24")
 	// Yes, this would normally be 127.0.0.0/8
 	// TODO: figure out any side effects
@@ -73,6 +80,13 @@ func prep() (err error) {
 	var pD uint64
 	packetsDropped = &pD
 
+	var aRt uint64
+	attributionRetried = &aRt
+	var aRc uint64
+	attributionRecovered = &aRc
+	var aF uint64
+	attributionFailed = &aF
+
 	return nil
 }
 
@@ -84,6 +98,7 @@ func start() error {
 	// go run()
 
 	go portsInUseCleaner()
+	go killSwitchWatcher()
 
 	return interception.Start()
 }
@@ -193,6 +208,17 @@ func initialHandler(pkt packet.Packet, link *network.Link) {
 			go comm.SaveIfNeeded()
 		}()
 
+		// internal links must not bypass the vpn kill switch
+		var processPath string
+		if err == nil {
+			processPath = comm.Process().Path
+		}
+		if checkVPNKillSwitch(processPath, link, pkt) {
+			link.StopFirewallHandler()
+			issueVerdict(pkt, link, 0, true)
+			return
+		}
+
 		// approve
 		link.Accept("internally approved")
 		log.Tracer(pkt.Ctx()).Tracef("firewall: internally approved link (via local port %d)", pkt.Info().LocalPort())
@@ -203,8 +229,26 @@ func initialHandler(pkt packet.Packet, link *network.Link) {
 		return
 	}
 
+	// drop inbound traffic from port scanners
+	if pkt.IsInbound() && portscan.IsBlocked(pkt.Info().RemoteIP()) {
+		log.Tracer(pkt.Ctx()).Tracef("firewall: dropping link from port scanner %s", pkt.Info().RemoteIP())
+		link.Drop("source was detected scanning ports")
+		link.StopFirewallHandler()
+		issueVerdict(pkt, link, 0, true)
+		return
+	}
+
 	// get Communication
-	comm, err := network.GetCommunicationByFirstPacket(pkt)
+	comm, err := getCommunicationByFirstPacket(pkt)
+	if err != nil && retryAttribution(pkt, link, err) {
+		// the link is decided on once the process was found
+		return
+	}
+	handleCommunication(pkt, link, comm, err)
+}
+
+// handleCommunication decides on the link of the given communication. If err is set, the process could not be found and the link is denied.
+func handleCommunication(pkt packet.Packet, link *network.Link, comm *network.Communication, err error) {
 	if err != nil {
 		log.Tracer(pkt.Ctx()).Warningf("firewall: could not get process, denying link: %s", err)
 
@@ -242,6 +286,14 @@ func initialHandler(pkt packet.Packet, link *network.Link) {
 	DecideOnCommunication(comm, pkt)
 	DecideOnLink(comm, link, pkt)
 
+	// feed denied inbound connections to port scan detection
+	if pkt.IsInbound() && pkt.HasPorts() {
+		switch link.GetVerdict() {
+		case network.VerdictBlock, network.VerdictDrop:
+			portscan.Report(pkt.Info().RemoteIP(), pkt.Info().LocalIP(), uint8(pkt.Info().Protocol), pkt.Info().LocalPort())
+		}
+	}
+
 	// TODO: link this to real status
 	// gate17Active := mode.Client()
 
@@ -283,7 +335,8 @@ func issueVerdict(pkt packet.Packet, link *network.Link, verdict network.Verdict
 
 	// enable permanent verdict
 	if allowPermanent && !link.VerdictPermanent {
-		link.VerdictPermanent = permanentVerdicts()
+		// links must be checked again when the vpn goes away, which permanent verdicts prevent
+		link.VerdictPermanent = permanentVerdicts() && !vpnKillSwitch()
 		if link.VerdictPermanent {
 			link.SaveWhenFinished()
 		}
@@ -364,6 +417,12 @@ func statLogger() {
 			atomic.StoreUint64(packetsAccepted, 0)
 			atomic.StoreUint64(packetsBlocked, 0)
 			atomic.StoreUint64(packetsDropped, 0)
+			if atomic.LoadUint64(attributionRetried) > 0 || atomic.LoadUint64(attributionFailed) > 0 {
+				log.Tracef("firewall: process attribution retried %d, recovered %d, failed %d", atomic.LoadUint64(attributionRetried), atomic.LoadUint64(attributionRecovered), atomic.LoadUint64(attributionFailed))
+				atomic.StoreUint64(attributionRetried, 0)
+				atomic.StoreUint64(attributionRecovered, 0)
+				atomic.StoreUint64(attributionFailed, 0)
+			}
 		}
 	}
 }
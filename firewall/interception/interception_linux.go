@@ -18,3 +18,8 @@ func Start() error {
 func Stop() error {
 	return StopNfqueueInterception()
 }
+
+// CheckRules reports which of the rules needed for interception are present in the system.
+func CheckRules() (map[string]bool, error) {
+	return checkNfqueueRules()
+}
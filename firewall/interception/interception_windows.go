@@ -1,6 +1,7 @@
 package interception
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Safing/portbase/log"
@@ -91,3 +92,8 @@ func notifyRebootRequired() {
 		Type:    notifications.Warning,
 	}).Init().Save()
 }
+
+// CheckRules reports which of the rules needed for interception are present in the system. This is not supported on Windows.
+func CheckRules() (map[string]bool, error) {
+	return nil, errors.New("interception: checking rules is not supported on windows")
+}
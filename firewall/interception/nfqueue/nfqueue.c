@@ -16,6 +16,8 @@ int nfqueue_cb_new(struct nfq_q_handle *qh, struct nfgenmsg *nfmsg, struct nfq_d
   unsigned char * saddr, * daddr;
   uint16_t sport = 0,  dport = 0, checksum = 0;
   uint32_t mark = nfq_get_nfmark(nfa);
+  uint32_t indev = nfq_get_indev(nfa);
+  uint32_t outdev = nfq_get_outdev(nfa);
 
   int len = nfq_get_payload(nfa, &payload);
 
@@ -74,7 +76,7 @@ int nfqueue_cb_new(struct nfq_q_handle *qh, struct nfgenmsg *nfmsg, struct nfq_d
   }
   //pass everything we can and let Go handle it, I'm not a big fan of C
   uint32_t verdict = go_nfq_callback(id, ntohs(ph->hw_protocol), ph->hook, &mark, ip->version, ip->protocol,
-                  ip->tos, ip->ttl, saddr, daddr, sport, dport, checksum, origlen, origpayload, data);
+                  ip->tos, ip->ttl, saddr, daddr, sport, dport, checksum, indev, outdev, origlen, origpayload, data);
   return nfq_set_verdict2(qh, id, verdict, mark, 0, NULL);
 }
 
@@ -139,7 +139,7 @@ func (this *NFQueue) Valid() bool {
 //export go_nfq_callback
 func go_nfq_callback(id uint32, hwproto uint16, hook uint8, mark *uint32,
 	version, protocol, tos, ttl uint8, saddr, daddr unsafe.Pointer,
-	sport, dport, checksum uint16, payload_len uint32, payload, data unsafe.Pointer) (v uint32) {
+	sport, dport, checksum uint16, indev, outdev uint32, payload_len uint32, payload, data unsafe.Pointer) (v uint32) {
 
 	qidptr := (*uint16)(data)
 	qid := uint16(*qidptr)
@@ -177,6 +177,10 @@ func go_nfq_callback(id uint32, hwproto uint16, hook uint8, mark *uint32,
 	info.SrcPort = sport
 	info.DstPort = dport
 
+	// Interfaces
+	info.InIface = indev
+	info.OutIface = outdev
+
 	// fmt.Printf("%s queuing packet\n", time.Now().Format("060102 15:04:05.000"))
 	// BUG: "panic: send on closed channel" when shutting down
 	queues[qid].Packets <- &pkt
@@ -242,6 +242,61 @@ func deactivateNfqueueFirewall() error {
 	return nil
 }
 
+// checkNfqueueRules reports which chains and hooking rules of the nfqueue interception are present.
+func checkNfqueueRules() (map[string]bool, error) {
+	present := make(map[string]bool)
+
+	ip4tables, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+	if err != nil {
+		return nil, err
+	}
+	err = checkIPTablesRules(ip4tables, "ipv4", v4chains, v4once, present)
+	if err != nil {
+		return present, err
+	}
+
+	ip6tables, err := iptables.NewWithProtocol(iptables.ProtocolIPv6)
+	if err != nil {
+		return present, err
+	}
+	err = checkIPTablesRules(ip6tables, "ipv6", v6chains, v6once, present)
+	return present, err
+}
+
+func checkIPTablesRules(ipt *iptables.IPTables, prefix string, chains, once []string, present map[string]bool) error {
+	existingChains := make(map[string][]string)
+	for _, chain := range chains {
+		splittedRule := strings.Split(chain, " ")
+		tableChains, ok := existingChains[splittedRule[0]]
+		if !ok {
+			var err error
+			tableChains, err = ipt.ListChains(splittedRule[0])
+			if err != nil {
+				return err
+			}
+			existingChains[splittedRule[0]] = tableChains
+		}
+
+		present[fmt.Sprintf("%s chain %s", prefix, chain)] = false
+		for _, existing := range tableChains {
+			if existing == splittedRule[1] {
+				present[fmt.Sprintf("%s chain %s", prefix, chain)] = true
+				break
+			}
+		}
+	}
+
+	for _, rule := range once {
+		splittedRule := strings.Split(rule, " ")
+		ok, err := ipt.Exists(splittedRule[0], splittedRule[1], splittedRule[2:]...)
+		if err != nil {
+			return err
+		}
+		present[fmt.Sprintf("%s rule %s", prefix, rule)] = ok
+	}
+	return nil
+}
+
 // StartNfqueueInterception starts the nfqueue interception.
 func StartNfqueueInterception() (err error) {
 
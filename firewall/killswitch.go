@@ -0,0 +1,177 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/network/environment"
+	"github.com/Safing/portmaster/network/netutils"
+	"github.com/Safing/portmaster/network/packet"
+	"github.com/Safing/portmaster/status"
+)
+
+const (
+	killSwitchWarningMsg = "VPN kill switch is denying connections, because they would not go through a VPN"
+)
+
+var (
+	// killSwitchWarningDuration defines how long the warning is shown after the last denied link, so that it does not flap while VPN and non-VPN links alternate.
+	killSwitchWarningDuration = 1 * time.Minute
+
+	killSwitchWarningActive bool
+	killSwitchLastDenied    time.Time
+	killSwitchWarningLock   sync.Mutex
+
+	// killSwitchLinks holds the local IPs of links that were accepted because they go through a VPN. They are checked again when the network changes, as they must not continue without the VPN.
+	killSwitchLinks     = make(map[*network.Link]net.IP)
+	killSwitchLinksLock sync.Mutex
+	// killSwitchCleanInterval defines how often ended links are removed from killSwitchLinks.
+	killSwitchCleanInterval = 5 * time.Minute
+)
+
+// checkVPNKillSwitch denies the link, if the VPN kill switch is enabled and the link does not go through a VPN interface. processPath may be empty, if the process is unknown. It returns whether the link was denied.
+func checkVPNKillSwitch(processPath string, link *network.Link, pkt packet.Packet) (denied bool) {
+	if !vpnKillSwitch() {
+		clearKillSwitchWarning(true)
+		return false
+	}
+
+	deny, viaVPN, reason := vpnKillSwitchVerdict(pkt.Info().RemoteIP(), processPath, func() string {
+		return getInterfaceName(pkt)
+	})
+	if viaVPN {
+		clearKillSwitchWarning(false)
+
+		killSwitchLinksLock.Lock()
+		killSwitchLinks[link] = pkt.Info().LocalIP()
+		killSwitchLinksLock.Unlock()
+	}
+	if !deny {
+		return false
+	}
+
+	log.Infof("firewall: denying link %s, %s", link, reason)
+	link.Deny(reason)
+	setKillSwitchWarning()
+	return true
+}
+
+// killSwitchWatcher checks the links accepted by the VPN kill switch again, when the network changes.
+func killSwitchWatcher() {
+	ticker := time.NewTicker(killSwitchCleanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-modules.ShuttingDown():
+			return
+		case <-environment.NetworkChanged():
+			recheckKillSwitchLinks(true)
+		case <-ticker.C:
+			recheckKillSwitchLinks(false)
+		}
+	}
+}
+
+// recheckKillSwitchLinks removes ended links from killSwitchLinks. If checkInterfaces is set, open links whose local IP no longer belongs to a VPN interface are denied.
+func recheckKillSwitchLinks(checkInterfaces bool) {
+	enabled := vpnKillSwitch()
+
+	killSwitchLinksLock.Lock()
+	defer killSwitchLinksLock.Unlock()
+
+	for link, localIP := range killSwitchLinks {
+		link.Lock()
+		ended := link.Ended != 0
+		link.Unlock()
+		if ended || !enabled {
+			delete(killSwitchLinks, link)
+			continue
+		}
+		if !checkInterfaces {
+			continue
+		}
+
+		iface := environment.InterfaceByIP(localIP)
+		if environment.IsVPNInterface(iface, vpnInterfaces()) {
+			continue
+		}
+		reason := "VPN kill switch: VPN interface of connection went away"
+		if iface != "" {
+			reason = fmt.Sprintf("VPN kill switch: connection would leave through %s, which is not a VPN interface", iface)
+		}
+		log.Infof("firewall: denying link %s, %s", link, reason)
+		link.Deny(reason)
+		go link.SaveIfNeeded()
+		setKillSwitchWarning()
+		delete(killSwitchLinks, link)
+	}
+}
+
+// vpnKillSwitchVerdict returns whether the VPN kill switch denies a connection of the given process to the given IP, whether it leaves through a VPN interface, and the reason for denying it. getIface is only called when needed, as it may be expensive.
+func vpnKillSwitchVerdict(remoteIP net.IP, processPath string, getIface func() string) (deny, viaVPN bool, reason string) {
+	// LAN and localhost are not affected
+	switch netutils.ClassifyIP(remoteIP) {
+	case netutils.HostLocal, netutils.LinkLocal, netutils.SiteLocal, netutils.LocalMulticast:
+		return false, false, ""
+	}
+
+	// VPN clients need to reach the VPN server
+	for _, exemption := range vpnKillSwitchExemptions() {
+		if processPath == exemption {
+			return false, false, ""
+		}
+	}
+
+	iface := getIface()
+	switch {
+	case iface == "":
+		return true, false, "VPN kill switch: network interface of connection is unknown"
+	case environment.IsVPNInterface(iface, vpnInterfaces()):
+		return false, true, ""
+	default:
+		return true, false, fmt.Sprintf("VPN kill switch: connection would leave through %s, which is not a VPN interface", iface)
+	}
+}
+
+// getInterfaceName returns the name of the network interface the packet passes. If the interception does not provide it, the interface is looked up by the local IP address.
+func getInterfaceName(pkt packet.Packet) string {
+	if index := pkt.Info().Iface(); index > 0 {
+		iface, err := net.InterfaceByIndex(int(index))
+		if err == nil {
+			return iface.Name
+		}
+	}
+	return environment.InterfaceByIP(pkt.Info().LocalIP())
+}
+
+func setKillSwitchWarning() {
+	killSwitchWarningLock.Lock()
+	defer killSwitchWarningLock.Unlock()
+
+	killSwitchLastDenied = time.Now()
+	if !killSwitchWarningActive {
+		killSwitchWarningActive = true
+		status.SetPortmasterStatus(status.StatusWarning, killSwitchWarningMsg)
+	}
+}
+
+// clearKillSwitchWarning clears the warning of the kill switch, if it is shown. Unless forced, it is kept until no link was denied for a while.
+func clearKillSwitchWarning(force bool) {
+	killSwitchWarningLock.Lock()
+	defer killSwitchWarningLock.Unlock()
+
+	if !killSwitchWarningActive {
+		return
+	}
+	if !force && time.Since(killSwitchLastDenied) < killSwitchWarningDuration {
+		return
+	}
+	killSwitchWarningActive = false
+	status.ClearPortmasterStatus(killSwitchWarningMsg)
+}
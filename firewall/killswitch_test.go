@@ -0,0 +1,124 @@
+package firewall
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/status"
+)
+
+func TestVPNKillSwitchVerdict(t *testing.T) {
+	oldInterfaces, oldExemptions := vpnInterfaces, vpnKillSwitchExemptions
+	vpnInterfaces = func() []string { return []string{"corp*"} }
+	vpnKillSwitchExemptions = func() []string { return []string{"/usr/sbin/openvpn"} }
+	defer func() {
+		vpnInterfaces, vpnKillSwitchExemptions = oldInterfaces, oldExemptions
+	}()
+
+	testCases := []struct {
+		name        string
+		remoteIP    string
+		processPath string
+		iface       string
+		deny        bool
+		viaVPN      bool
+	}{
+		{"lan", "192.168.1.1", "/usr/bin/app", "not-a-vpn0", false, false},
+		{"localhost", "127.0.0.1", "/usr/bin/app", "", false, false},
+		{"vpn client", "1.1.1.1", "/usr/sbin/openvpn", "not-a-vpn0", false, false},
+		{"vpn interface", "1.1.1.1", "/usr/bin/app", "tun0", false, true},
+		{"configured vpn interface", "1.1.1.1", "/usr/bin/app", "corp-vpn", false, true},
+		{"other interface", "1.1.1.1", "/usr/bin/app", "not-a-vpn0", true, false},
+		{"unknown interface", "1.1.1.1", "/usr/bin/app", "", true, false},
+	}
+	for _, tc := range testCases {
+		var ifaceChecked bool
+		deny, viaVPN, reason := vpnKillSwitchVerdict(net.ParseIP(tc.remoteIP), tc.processPath, func() string {
+			ifaceChecked = true
+			return tc.iface
+		})
+		if deny != tc.deny || viaVPN != tc.viaVPN {
+			t.Errorf("%s: expected deny=%v viaVPN=%v, got deny=%v viaVPN=%v", tc.name, tc.deny, tc.viaVPN, deny, viaVPN)
+		}
+		if deny && reason == "" {
+			t.Errorf("%s: denied without reason", tc.name)
+		}
+		if tc.name == "lan" && ifaceChecked {
+			t.Errorf("%s: interface should not be looked up", tc.name)
+		}
+	}
+}
+
+func TestKillSwitchWarning(t *testing.T) {
+	defer clearKillSwitchWarning(true)
+
+	setKillSwitchWarning()
+	if status.PortmasterStatus() != status.StatusWarning {
+		t.Fatal("warning should be shown")
+	}
+
+	// a link through the VPN does not clear a recent warning
+	clearKillSwitchWarning(false)
+	if status.PortmasterStatus() != status.StatusWarning {
+		t.Error("warning should be kept for a while")
+	}
+
+	// but it is cleared after a while
+	killSwitchWarningLock.Lock()
+	killSwitchLastDenied = time.Now().Add(-2 * killSwitchWarningDuration)
+	killSwitchWarningLock.Unlock()
+	clearKillSwitchWarning(false)
+	if status.PortmasterStatus() != status.StatusOk {
+		t.Error("warning should be cleared")
+	}
+
+	// other warnings are not cleared
+	setKillSwitchWarning()
+	status.SetPortmasterStatus(status.StatusWarning, "other warning")
+	clearKillSwitchWarning(true)
+	if status.PortmasterStatus() != status.StatusWarning {
+		t.Error("other warning must not be cleared")
+	}
+	status.SetPortmasterStatus(status.StatusOk, "")
+}
+
+func TestRecheckKillSwitchLinks(t *testing.T) {
+	oldKillSwitch, oldInterfaces := vpnKillSwitch, vpnInterfaces
+	vpnKillSwitch = func() bool { return true }
+	vpnInterfaces = func() []string { return nil }
+	defer func() {
+		vpnKillSwitch, vpnInterfaces = oldKillSwitch, oldInterfaces
+		clearKillSwitchWarning(true)
+	}()
+
+	// localhost is never a vpn interface, the documentation IP is on no interface at all
+	viaLoopback := &network.Link{Verdict: network.VerdictAccept}
+	viaGoneInterface := &network.Link{Verdict: network.VerdictAccept}
+	ended := &network.Link{Verdict: network.VerdictAccept, Ended: time.Now().Unix()}
+	killSwitchLinksLock.Lock()
+	killSwitchLinks[viaLoopback] = net.ParseIP("127.0.0.1")
+	killSwitchLinks[viaGoneInterface] = net.ParseIP("192.0.2.1")
+	killSwitchLinks[ended] = net.ParseIP("192.0.2.1")
+	killSwitchLinksLock.Unlock()
+
+	// only ended links are removed periodically
+	recheckKillSwitchLinks(false)
+	if viaLoopback.GetVerdict() != network.VerdictAccept || len(killSwitchLinks) != 2 {
+		t.Fatal("open links must only be checked when the network changes")
+	}
+
+	recheckKillSwitchLinks(true)
+	for _, link := range []*network.Link{viaLoopback, viaGoneInterface} {
+		if link.GetVerdict() != network.VerdictBlock {
+			t.Errorf("link %s should be denied, as it does not go through a vpn anymore", link)
+		}
+	}
+	if ended.GetVerdict() != network.VerdictAccept {
+		t.Error("ended link must not be changed")
+	}
+	if len(killSwitchLinks) != 0 {
+		t.Errorf("denied and ended links should be removed, %d left", len(killSwitchLinks))
+	}
+}
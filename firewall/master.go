@@ -11,6 +11,7 @@ import (
 	"github.com/Safing/portbase/notifications"
 	"github.com/Safing/portmaster/intel"
 	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/network/environment"
 	"github.com/Safing/portmaster/network/netutils"
 	"github.com/Safing/portmaster/network/packet"
 	"github.com/Safing/portmaster/process"
@@ -62,6 +63,13 @@ func DecideOnCommunicationBeforeIntel(comm *network.Communication, fqdn string)
 	}
 	profileSet.Update(status.ActiveSecurityLevel())
 
+	// check if profile is quarantined
+	if quarantined, reason := profileSet.Quarantined(); quarantined {
+		log.Infof("firewall: denying communication %s, profile is quarantined: %s", comm, reason)
+		comm.Deny(fmt.Sprintf("profile is quarantined: %s", reason))
+		return
+	}
+
 	// check for any network access
 	if !profileSet.CheckFlag(profile.Internet) && !profileSet.CheckFlag(profile.LAN) {
 		log.Infof("firewall: denying communication %s, accessing Internet or LAN not permitted", comm)
@@ -71,6 +79,14 @@ func DecideOnCommunicationBeforeIntel(comm *network.Communication, fqdn string)
 
 	// check endpoint list
 	result, reason := profileSet.CheckEndpointDomain(fqdn)
+
+	// permit captive portal, unless explicitly denied
+	if result != profile.Denied && environment.IsCaptivePortalDomain(fqdn) {
+		log.Infof("firewall: permitting communication %s, domain belongs to captive portal", comm)
+		comm.Accept("domain belongs to captive portal")
+		return
+	}
+
 	switch result {
 	case profile.NoMatch:
 		comm.UpdateVerdict(network.VerdictUndecided)
@@ -179,6 +195,8 @@ func DecideOnCommunicationAfterIntel(comm *network.Communication, fqdn string, r
 		},
 		Expires: time.Now().Add(nTTL).Unix(),
 	}).Init().Save()
+	registerPrompt(n, comm, nil)
+	defer removePrompt(nID)
 
 	// react
 	select {
@@ -241,6 +259,11 @@ func FilterDNSResponse(comm *network.Communication, fqdn string, rrCache *intel.
 	}
 	profileSet.Update(status.ActiveSecurityLevel())
 
+	// do not filter captive portal, as it usually resolves to a local address
+	if comm.GetVerdict() == network.VerdictAccept && environment.IsCaptivePortalDomain(fqdn) {
+		return rrCache
+	}
+
 	// save config for consistency during function call
 	secLevel := profileSet.SecurityLevel()
 	filterByScope := filterDNSByScope(secLevel)
@@ -389,6 +412,13 @@ func DecideOnCommunication(comm *network.Communication, pkt packet.Packet) {
 	}
 	profileSet.Update(status.ActiveSecurityLevel())
 
+	// check if profile is quarantined
+	if quarantined, reason := profileSet.Quarantined(); quarantined {
+		log.Infof("firewall: denying communication %s, profile is quarantined: %s", comm, reason)
+		comm.Deny(fmt.Sprintf("profile is quarantined: %s", reason))
+		return
+	}
+
 	// check comm type
 	switch comm.Domain {
 	case network.IncomingHost, network.IncomingLAN, network.IncomingInternet, network.IncomingInvalid:
@@ -464,6 +494,11 @@ func DecideOnCommunication(comm *network.Communication, pkt packet.Packet) {
 // DecideOnLink makes a decision about a link with the first packet.
 func DecideOnLink(comm *network.Communication, link *network.Link, pkt packet.Packet) {
 
+	// check vpn kill switch first, as links of the Portmaster itself, eg. upstream DNS queries, must not leak either
+	if checkVPNKillSwitch(comm.Process().Path, link, pkt) {
+		return
+	}
+
 	// grant self
 	if comm.Process().Pid == os.Getpid() {
 		log.Infof("firewall: granting own link %s", comm)
@@ -645,6 +680,8 @@ func DecideOnLink(comm *network.Communication, link *network.Link, pkt packet.Pa
 		Text: "deny",
 	})
 	n.Init().Save()
+	registerPrompt(n, comm, remoteIP)
+	defer removePrompt(nID)
 
 	// react
 	select {
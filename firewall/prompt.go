@@ -0,0 +1,111 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/notifications"
+	"github.com/Safing/portmaster/network"
+)
+
+// Prompt describes a pending firewall prompt, so that it can be answered by prompt agents without access to the notifications database, such as pmctl.
+type Prompt struct {
+	ID          string
+	Message     string
+	Actions     []*notifications.Action
+	Pid         int
+	ProcessPath string
+	Domain      string
+	RemoteIP    string
+	Inbound     bool
+	Expires     int64
+
+	n *notifications.Notification
+}
+
+var (
+	prompts     = make(map[string]*Prompt)
+	promptsLock sync.Mutex
+
+	promptDB = database.NewInterface(nil)
+
+	// ErrPromptNotFound is returned when a prompt does not exist or has already been answered.
+	ErrPromptNotFound = errors.New("prompt not found")
+)
+
+// registerPrompt makes a saved prompt notification available to prompt agents. remoteIP may be nil.
+func registerPrompt(n *notifications.Notification, comm *network.Communication, remoteIP net.IP) {
+	prompt := &Prompt{
+		ID:      n.ID,
+		Message: n.Message,
+		Actions: n.AvailableActions,
+		Domain:  comm.Domain,
+		Inbound: comm.Direction,
+		Expires: n.Expires,
+		n:       n,
+	}
+	if proc := comm.Process(); proc != nil {
+		prompt.Pid = proc.Pid
+		prompt.ProcessPath = proc.Path
+	}
+	if remoteIP != nil {
+		prompt.RemoteIP = remoteIP.String()
+	}
+
+	promptsLock.Lock()
+	defer promptsLock.Unlock()
+	prompts[n.ID] = prompt
+}
+
+func removePrompt(id string) {
+	promptsLock.Lock()
+	defer promptsLock.Unlock()
+	delete(prompts, id)
+}
+
+// GetPrompts returns all pending prompts, the oldest first.
+func GetPrompts() []*Prompt {
+	promptsLock.Lock()
+	defer promptsLock.Unlock()
+
+	pending := make([]*Prompt, 0, len(prompts))
+	for _, prompt := range prompts {
+		pending = append(pending, prompt)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Expires < pending[j].Expires
+	})
+	return pending
+}
+
+// RespondToPrompt answers the prompt with the given action. The response is put into the notifications database, like any other notifications client would, and reaches the firewall through the response channel of the notification.
+func RespondToPrompt(id, actionID string) error {
+	promptsLock.Lock()
+	prompt, ok := prompts[id]
+	promptsLock.Unlock()
+	if !ok {
+		return ErrPromptNotFound
+	}
+
+	var validAction bool
+	for _, action := range prompt.Actions {
+		if action.ID == actionID {
+			validAction = true
+			break
+		}
+	}
+	if !validAction {
+		return fmt.Errorf("invalid action %s for prompt %s", actionID, id)
+	}
+
+	response := &notifications.Notification{
+		ID:               prompt.ID,
+		SelectedActionID: actionID,
+	}
+	response.SetKey(prompt.n.Key())
+	return promptDB.Put(response)
+}
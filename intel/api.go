@@ -0,0 +1,71 @@
+package intel
+
+import (
+	"net/http"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portmaster/internal/apiutil"
+)
+
+// ResolverInfo describes the state of a resolver for API clients.
+type ResolverInfo struct {
+	Server      string
+	Source      string
+	Local       bool
+	Initialized bool
+	Fails       int
+	LastFail    int64
+	FailReason  string
+}
+
+// ScopeInfo describes a domain scope and the resolvers responsible for it.
+type ScopeInfo struct {
+	Domain    string
+	Resolvers []string
+}
+
+// ResolverStatus holds the state of all resolvers.
+type ResolverStatus struct {
+	Resolvers []*ResolverInfo
+	Scopes    []*ScopeInfo
+}
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/intel/v1/resolvers", handleGetResolvers).Methods("GET")
+	return nil
+}
+
+func handleGetResolvers(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(w, GetResolverStatus())
+}
+
+// GetResolverStatus returns the state of all resolvers and local scopes.
+func GetResolverStatus() *ResolverStatus {
+	resolversLock.RLock()
+	defer resolversLock.RUnlock()
+
+	rs := &ResolverStatus{}
+	for _, resolver := range globalResolvers {
+		resolver.Lock()
+		rs.Resolvers = append(rs.Resolvers, &ResolverInfo{
+			Server:      resolver.Server,
+			Source:      resolver.Source,
+			Local:       indexOfResolver(resolver.Server, localResolvers) >= 0,
+			Initialized: resolver.initialized,
+			Fails:       resolver.fails,
+			LastFail:    resolver.lastFail,
+			FailReason:  resolver.failReason,
+		})
+		resolver.Unlock()
+	}
+	for _, scope := range localScopes {
+		scopeInfo := &ScopeInfo{
+			Domain: scope.Domain,
+		}
+		for _, resolver := range scope.Resolvers {
+			scopeInfo.Resolvers = append(scopeInfo.Resolvers, resolver.Server)
+		}
+		rs.Scopes = append(rs.Scopes, scopeInfo)
+	}
+	return rs
+}
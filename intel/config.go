@@ -112,5 +112,5 @@ func prep() error {
 	}
 	doNotResolveSpecialDomains = status.ConfigIsActiveConcurrent("intel/doNotResolveSpecialDomains")
 
-	return nil
+	return registerAPI()
 }
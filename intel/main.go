@@ -6,14 +6,15 @@ import (
 	"github.com/miekg/dns"
 
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/network/environment"
 
 	// module dependencies
 	_ "github.com/Safing/portmaster/core"
 )
 
 func init() {
-	modules.Register("intel", prep, start, nil, "core")
+	modstatus.Register("intel", prep, start, nil, "core", "api")
 }
 
 func start() error {
@@ -21,10 +22,23 @@ func start() error {
 	loadResolvers(false)
 
 	go listenToMDNS()
+	go reloadResolversOnNetworkChange()
 
 	return nil
 }
 
+func reloadResolversOnNetworkChange() {
+	changed := environment.NetworkChanged()
+	for {
+		<-changed
+		// get next event channel before reloading in order to not miss any changes
+		changed = environment.NetworkChanged()
+
+		log.Info("intel: network changed, reloading resolvers")
+		loadResolvers(true)
+	}
+}
+
 // GetIntelAndRRs returns intel and DNS resource records for the given domain.
 func GetIntelAndRRs(ctx context.Context, domain string, qtype dns.Type, securityLevel uint8) (intel *Intel, rrs *RRCache) {
 	log.Tracer(ctx).Trace("intel: getting intel")
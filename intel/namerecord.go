@@ -27,6 +27,7 @@ type NameRecord struct {
 	Ns       []string
 	Extra    []string
 	TTL      int64
+	Rcode    int
 
 	Server      string
 	ServerScope int8
@@ -156,13 +156,10 @@ func intelligentResolve(ctx context.Context, fqdn string, qtype dns.Type, securi
 	// TODO: handle being offline
 	// TODO: handle multiple network connections
 
-	// TODO: handle these in a separate goroutine
+	// TODO: reload resolvers on config change, network changes are handled by reloadResolversOnNetworkChange
 	// if config.Changed() {
 	// 	log.Info("intel: config changed, reloading resolvers")
 	// 	loadResolvers(false)
-	// } else if env.NetworkChanged() {
-	// 	log.Info("intel: network changed, reloading resolvers")
-	// 	loadResolvers(true)
 	// }
 
 	resolversLock.RLock()
@@ -367,6 +364,7 @@ func query(ctx context.Context, resolver *Resolver, fqdn string, qtype dns.Type)
 		Answer:      reply.Answer,
 		Ns:          reply.Ns,
 		Extra:       reply.Extra,
+		Rcode:       reply.Rcode,
 		Server:      resolver.Server,
 		ServerScope: resolver.ServerIPScope,
 	}
@@ -19,6 +19,8 @@ type RRCache struct {
 	Ns     []dns.RR
 	Extra  []dns.RR
 	TTL    int64
+	// Rcode is the response code of the reply.
+	Rcode int
 
 	Server      string
 	ServerScope int8
@@ -83,6 +85,7 @@ func (m *RRCache) ToNameRecord() *NameRecord {
 		Domain:      m.Domain,
 		Question:    m.Question.String(),
 		TTL:         m.TTL,
+		Rcode:       m.Rcode,
 		Server:      m.Server,
 		ServerScope: m.ServerScope,
 	}
@@ -119,6 +122,7 @@ func GetRRCache(domain string, question dns.Type) (*RRCache, error) {
 	}
 
 	rrCache.TTL = nameRecord.TTL
+	rrCache.Rcode = nameRecord.Rcode
 	for _, entry := range nameRecord.Answer {
 		rr, err := dns.NewRR(entry)
 		if err == nil {
@@ -187,6 +191,7 @@ func (m *RRCache) ShallowCopy() *RRCache {
 		Ns:       m.Ns,
 		Extra:    m.Extra,
 		TTL:      m.TTL,
+		Rcode:    m.Rcode,
 
 		Server:      m.Server,
 		ServerScope: m.ServerScope,
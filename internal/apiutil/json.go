@@ -0,0 +1,25 @@
+// Package apiutil provides helpers for the API endpoints of the Portmaster modules.
+package apiutil
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Safing/portbase/log"
+)
+
+// WriteJSON writes the given value as a JSON response.
+func WriteJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Warningf("api: failed to write api response: %s", err)
+	}
+}
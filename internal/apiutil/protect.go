@@ -0,0 +1,27 @@
+package apiutil
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RequestHeader must be set on all requests to endpoints that change state. Browsers only send custom headers cross-site after a CORS preflight, which the API does not permit, so web pages cannot forge these requests.
+const RequestHeader = "X-Portmaster-Request"
+
+// Protect wraps a handler that changes state. It rejects requests without RequestHeader and requests from other origins.
+func Protect(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(RequestHeader) == "" {
+			http.Error(w, "missing "+RequestHeader+" header", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		fn(w, r)
+	}
+}
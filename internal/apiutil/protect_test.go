@@ -0,0 +1,40 @@
+package apiutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	handler := Protect(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name   string
+		header bool
+		origin string
+		status int
+	}{
+		{"simple request", false, "", http.StatusForbidden},
+		{"cross-site request", true, "http://example.com", http.StatusForbidden},
+		{"cross-site request without header", false, "http://example.com", http.StatusForbidden},
+		{"request from the ui", true, "http://127.0.0.1:817", http.StatusOK},
+		{"request from a client", true, "", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest("POST", "http://127.0.0.1:817/api/test", nil)
+		if tc.header {
+			r.Header.Set(RequestHeader, "pmctl")
+		}
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Package modstatus registers Portmaster modules and keeps track of their state, so that it can be reported for diagnostics.
+
+The portbase module system does not expose whether a module is prepped, started or failed. Modules therefore register with modstatus.Register instead of modules.Register, which takes the same arguments, registers the module with portbase and records the state changes of the module. Modules that register with portbase directly are missing from the reported status.
+*/
+package modstatus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/modules"
+)
+
+// Module states.
+const (
+	StateRegistered = "registered"
+	StatePrepping   = "prepping"
+	StatePrepped    = "prepped"
+	StateStarting   = "starting"
+	StateStarted    = "started"
+	StateStopping   = "stopping"
+	StateStopped    = "stopped"
+	StateFailed     = "failed"
+)
+
+// ModuleStatus describes the state of a module.
+type ModuleStatus struct {
+	Name         string
+	Dependencies []string
+	State        string
+	// Error holds the error of the last failed prep, start or stop.
+	Error   string `json:",omitempty"`
+	Changed int64
+}
+
+var (
+	statuses     = make(map[string]*ModuleStatus)
+	statusesLock sync.Mutex
+)
+
+// Register registers the module with portbase, like modules.Register, and tracks its state.
+func Register(name string, prep, start, stop func() error, dependencies ...string) {
+	statusesLock.Lock()
+	statuses[name] = &ModuleStatus{
+		Name:         name,
+		Dependencies: dependencies,
+		State:        StateRegistered,
+		Changed:      time.Now().Unix(),
+	}
+	statusesLock.Unlock()
+
+	modules.Register(
+		name,
+		track(name, prep, StatePrepping, StatePrepped),
+		track(name, start, StateStarting, StateStarted),
+		track(name, stop, StateStopping, StateStopped),
+		dependencies...,
+	)
+}
+
+// track wraps fn to record the state of the module before and after it runs.
+func track(name string, fn func() error, during, after string) func() error {
+	return func() error {
+		setState(name, during, nil)
+		var err error
+		if fn != nil {
+			err = fn()
+		}
+		if err != nil && err != modules.ErrCleanExit {
+			setState(name, StateFailed, err)
+		} else {
+			setState(name, after, nil)
+		}
+		return err
+	}
+}
+
+func setState(name, state string, err error) {
+	statusesLock.Lock()
+	defer statusesLock.Unlock()
+
+	status, ok := statuses[name]
+	if !ok {
+		return
+	}
+	status.State = state
+	status.Changed = time.Now().Unix()
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+// All returns the status of all registered modules, sorted by name.
+func All() []*ModuleStatus {
+	statusesLock.Lock()
+	defer statusesLock.Unlock()
+
+	all := make([]*ModuleStatus, 0, len(statuses))
+	for _, status := range statuses {
+		copied := *status
+		all = append(all, &copied)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
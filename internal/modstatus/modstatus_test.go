@@ -0,0 +1,48 @@
+package modstatus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrack(t *testing.T) {
+	statuses["test"] = &ModuleStatus{
+		Name:  "test",
+		State: StateRegistered,
+	}
+	defer delete(statuses, "test")
+
+	getState := func() *ModuleStatus {
+		for _, status := range All() {
+			if status.Name == "test" {
+				return status
+			}
+		}
+		t.Fatal("module status missing")
+		return nil
+	}
+
+	// modules without a function still change their state
+	err := track("test", nil, StatePrepping, StatePrepped)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := getState().State; state != StatePrepped {
+		t.Errorf("expected state %s, got %s", StatePrepped, state)
+	}
+
+	// failures are recorded
+	err = track("test", func() error {
+		if state := getState().State; state != StateStarting {
+			t.Errorf("expected state %s while starting, got %s", StateStarting, state)
+		}
+		return errors.New("test failure")
+	}, StateStarting, StateStarted)()
+	if err == nil {
+		t.Fatal("expected error to be passed through")
+	}
+	status := getState()
+	if status.State != StateFailed || status.Error != "test failure" {
+		t.Errorf("expected failed state with error, got %s (%s)", status.State, status.Error)
+	}
+}
@@ -17,6 +17,8 @@ import (
 	_ "github.com/Safing/portmaster/core"
 	_ "github.com/Safing/portmaster/firewall"
 	_ "github.com/Safing/portmaster/nameserver"
+	_ "github.com/Safing/portmaster/network/known"
+	_ "github.com/Safing/portmaster/threats"
 	_ "github.com/Safing/portmaster/ui"
 )
 
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// parseImports returns the import paths of the given Go file.
+func parseImports(t *testing.T, file string) map[string]bool {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := make(map[string]bool)
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imported[path] = true
+	}
+	return imported
+}
+
+func TestModulePackagesIncluded(t *testing.T) {
+	imported := parseImports(t, "main.go")
+
+	// packages that register modules, but are not imported by any other module
+	for _, path := range []string{
+		"github.com/Safing/portmaster/core",
+		"github.com/Safing/portmaster/firewall",
+		"github.com/Safing/portmaster/nameserver",
+		"github.com/Safing/portmaster/network/known",
+		"github.com/Safing/portmaster/threats",
+		"github.com/Safing/portmaster/ui",
+	} {
+		if !imported[path] {
+			t.Errorf("%s is not included, its modules will never be started", path)
+		}
+	}
+
+	// all threat detectors must be included by the threats package
+	imported = parseImports(t, filepath.Join("threats", "all.go"))
+	dirs, err := ioutil.ReadDir("threats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		// only packages that register a module are detectors
+		if _, err := os.Stat(filepath.Join("threats", dir.Name(), "module.go")); err != nil {
+			continue
+		}
+		path := "github.com/Safing/portmaster/threats/" + dir.Name()
+		if !imported[path] {
+			t.Errorf("detector %s is not included in threats/all.go, its module will never be started", path)
+		}
+	}
+}
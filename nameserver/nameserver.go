@@ -10,13 +10,14 @@ import (
 	"github.com/miekg/dns"
 
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 
-	"github.com/Safing/portmaster/analytics/algs"
 	"github.com/Safing/portmaster/firewall"
 	"github.com/Safing/portmaster/intel"
 	"github.com/Safing/portmaster/network"
 	"github.com/Safing/portmaster/network/netutils"
+	"github.com/Safing/portmaster/threats/dga"
+	"github.com/Safing/portmaster/threats/dnstunnel"
 )
 
 var (
@@ -29,7 +30,7 @@ var (
 )
 
 func init() {
-	modules.Register("nameserver", prep, start, nil, "intel")
+	modstatus.Register("nameserver", prep, start, nil, "intel")
 
 	if runtime.GOOS == "windows" {
 		listenAddress = "0.0.0.0:53"
@@ -143,12 +144,9 @@ func handleRequest(w dns.ResponseWriter, query *dns.Msg) {
 		go comm.SaveIfNeeded()
 	}()
 
-	// check for possible DNS tunneling / data transmission
-	// TODO: improve this
-	lms := algs.LmsScoreOfDomain(fqdn)
-	// log.Tracef("nameserver: domain %s has lms score of %f", fqdn, lms)
-	if lms < 10 {
-		log.WarningTracef(ctx, "nameserver: possible data tunnel by %s: %s has lms score of %f, returning nxdomain", comm.Process(), fqdn, lms)
+	// deny processes that were detected querying generated domains
+	if dga.IsBlocked(comm.Process()) {
+		log.WarningTracef(ctx, "nameserver: %s is blocked because of domain generation, returning nxdomain", comm.Process())
 		nxDomain(w, query)
 		return
 	}
@@ -165,10 +163,22 @@ func handleRequest(w dns.ResponseWriter, query *dns.Msg) {
 		return
 	}
 
+	// check for possible DNS tunneling / data transmission
+	if dnstunnel.Check(comm.Process(), fqdn, qtype) {
+		log.WarningTracef(ctx, "nameserver: %s is tunneling data via %s, returning nxdomain", comm.Process(), fqdn)
+		nxDomain(w, query)
+		return
+	}
+
 	// get intel and RRs
 	domainIntel, rrCache := intel.GetIntelAndRRs(ctx, fqdn, qtype, comm.Process().ProfileSet().SecurityLevel())
+	// analyze requests, malware could be trying DGA-domains
+	rcode := dns.RcodeServerFailure
+	if rrCache != nil {
+		rcode = rrCache.Rcode
+	}
+	dga.Report(comm.Process(), fqdn, rcode)
 	if rrCache == nil {
-		// TODO: analyze nxdomain requests, malware could be trying DGA-domains
 		log.WarningTracef(ctx, "nameserver: %s requested %s%s, is nxdomain", comm.Process(), fqdn, qtype)
 		nxDomain(w, query)
 		return
@@ -8,7 +8,7 @@ import (
 	"github.com/miekg/dns"
 
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 
 	"github.com/Safing/portmaster/analytics/algs"
 	"github.com/Safing/portmaster/intel"
@@ -21,7 +21,7 @@ var (
 )
 
 func init() {
-	modules.Register("nameserver", prep, start, nil, "intel")
+	modstatus.Register("nameserver", prep, start, nil, "intel")
 }
 
 func prep() error {
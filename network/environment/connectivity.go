@@ -0,0 +1,218 @@
+package environment
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/Safing/portbase/log"
+)
+
+// Connectivity Check Defaults
+const (
+	DefaultConnectivityCheckURL  = "http://detectportal.firefox.com/success.txt"
+	DefaultConnectivityCheckBody = "success"
+)
+
+var (
+	probeTimeout = 5 * time.Second
+	// probeRecheck defines how long results of active probing are cached, as probing is expensive.
+	probeRecheck = 1 * time.Minute
+	// portalAllowDuration defines how long the domain of a detected captive portal is permitted.
+	portalAllowDuration = 10 * time.Minute
+	maxProbeBodySize    = 4096
+
+	// portalDomains is read on every DNS request and must never wait for a probe, as probes use DNS themselves.
+	portalDomains     = make(map[string]int64) // fqdn -> expires (unix timestamp)
+	portalDomainsLock sync.RWMutex
+
+	probeTrigger = make(chan struct{}, 1)
+	lastProbe    time.Time // protected by connectivityLock
+)
+
+type prober struct {
+	checkURL     string
+	expectedBody string
+
+	hasNetwork func() bool
+	lookupHost func(ctx context.Context, host string) error
+	client     *http.Client
+}
+
+func newProber() *prober {
+	return &prober{
+		checkURL:     connectivityCheckURL(),
+		expectedBody: connectivityCheckBody(),
+		hasNetwork:   hasAssignedAddresses,
+		lookupHost: func(ctx context.Context, host string) error {
+			_, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			return err
+		},
+		client: &http.Client{
+			Timeout: probeTimeout,
+			// redirects are what captive portals do, do not follow them
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// probe actively checks the connectivity to the Internet. If a captive portal is detected, the URL it redirected to is returned, if known.
+func (p *prober) probe(ctx context.Context) (state uint8, portalURL *url.URL) {
+	if !p.hasNetwork() {
+		return OFFLINE, nil
+	}
+
+	checkURL, err := url.Parse(p.checkURL)
+	if err != nil {
+		log.Warningf("environment: invalid connectivity check url %s: %s", p.checkURL, err)
+		return UNKNOWN, nil
+	}
+
+	// check dns
+	if net.ParseIP(checkURL.Hostname()) == nil {
+		err = p.lookupHost(ctx, checkURL.Hostname())
+		if err != nil {
+			log.Debugf("environment: connectivity check failed to resolve %s: %s", checkURL.Hostname(), err)
+			return LIMITED, nil
+		}
+	}
+
+	// check http
+	req, err := http.NewRequest("GET", p.checkURL, nil)
+	if err != nil {
+		log.Warningf("environment: failed to create connectivity check request: %s", err)
+		return UNKNOWN, nil
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		log.Debugf("environment: connectivity check request failed: %s", err)
+		return LIMITED, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location, err := resp.Location()
+		if err != nil {
+			// portal is unknown
+			return PORTAL, nil
+		}
+		return PORTAL, location
+	case resp.StatusCode == http.StatusOK:
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxProbeBodySize)))
+		if err != nil {
+			log.Debugf("environment: failed to read connectivity check response: %s", err)
+			return LIMITED, nil
+		}
+		if strings.TrimSpace(string(body)) == strings.TrimSpace(p.expectedBody) {
+			return ONLINE, nil
+		}
+		// the response was replaced by the portal, which is unknown
+		return PORTAL, nil
+	case resp.StatusCode == http.StatusNetworkAuthenticationRequired:
+		return PORTAL, nil
+	default:
+		log.Debugf("environment: connectivity check received unexpected status %s", resp.Status)
+		return LIMITED, nil
+	}
+}
+
+// triggerProbe requests an active connectivity probe from the prober worker.
+func triggerProbe() {
+	select {
+	case probeTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// connectivityProber probes the connectivity whenever triggered.
+func connectivityProber() {
+	for range probeTrigger {
+		probeConnectivity()
+	}
+}
+
+// probeConnectivity actively checks the connectivity and permits the domain of a detected captive portal. No locks may be held, as probing uses the network, including our own nameserver.
+func probeConnectivity() {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	state, portalURL := newProber().probe(ctx)
+	if state == PORTAL {
+		if portalURL != nil {
+			allowPortalDomain(portalURL.Hostname(), time.Now())
+		}
+	} else {
+		revokePortalDomains()
+	}
+
+	connectivityLock.Lock()
+	defer connectivityLock.Unlock()
+	lastProbe = time.Now()
+	setConnectivity(state, probeRecheck)
+}
+
+// resetConnectivity forces connectivity to be re-checked and revokes permitted captive portals, as they belong to the previous network.
+func resetConnectivity() {
+	connectivityLock.Lock()
+	connectivityExpires = time.Now()
+	lastProbe = time.Time{}
+	connectivityLock.Unlock()
+
+	revokePortalDomains()
+	triggerProbe()
+}
+
+func hasAssignedAddresses() bool {
+	ipv4, ipv6, err := GetAssignedAddresses()
+	if err != nil {
+		log.Warningf("environment: failed to get assigned addresses: %s", err)
+		return true
+	}
+	for _, ip := range append(ipv4, ipv6...) {
+		if !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+			return true
+		}
+	}
+	return false
+}
+
+func allowPortalDomain(host string, now time.Time) {
+	if host == "" || net.ParseIP(host) != nil {
+		return
+	}
+	fqdn := dns.Fqdn(strings.ToLower(host))
+
+	portalDomainsLock.Lock()
+	defer portalDomainsLock.Unlock()
+
+	if _, ok := portalDomains[fqdn]; !ok {
+		log.Infof("environment: detected captive portal at %s, permitting it for %s", fqdn, portalAllowDuration)
+	}
+	portalDomains[fqdn] = now.Add(portalAllowDuration).Unix()
+}
+
+func revokePortalDomains() {
+	portalDomainsLock.Lock()
+	defer portalDomainsLock.Unlock()
+	portalDomains = make(map[string]int64)
+}
+
+// IsCaptivePortalDomain returns whether the given domain belongs to a captive portal that was detected on the current network and should be permitted.
+func IsCaptivePortalDomain(fqdn string) bool {
+	portalDomainsLock.RLock()
+	defer portalDomainsLock.RUnlock()
+
+	expires, ok := portalDomains[strings.ToLower(fqdn)]
+	return ok && expires >= time.Now().Unix()
+}
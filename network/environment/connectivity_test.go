@@ -0,0 +1,106 @@
+package environment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testProber(checkURL string) *prober {
+	p := newProber()
+	p.checkURL = checkURL
+	p.expectedBody = DefaultConnectivityCheckBody
+	p.hasNetwork = func() bool { return true }
+	p.lookupHost = func(ctx context.Context, host string) error { return nil }
+	return p
+}
+
+func TestProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/success", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success\n"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://portal.example.com/login", http.StatusFound)
+	})
+	mux.HandleFunc("/intercepted", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>Please accept the terms of use.</html>"))
+	})
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNetworkAuthenticationRequired)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	testCases := []struct {
+		path   string
+		state  uint8
+		portal string
+	}{
+		{"/success", ONLINE, ""},
+		{"/redirect", PORTAL, "portal.example.com"},
+		{"/intercepted", PORTAL, ""},
+		{"/auth", PORTAL, ""},
+		{"/error", LIMITED, ""},
+	}
+	for _, tc := range testCases {
+		state, portalURL := testProber(server.URL + tc.path).probe(context.Background())
+		if state != tc.state {
+			t.Errorf("%s: expected state %d, got %d", tc.path, tc.state, state)
+		}
+		var portal string
+		if portalURL != nil {
+			portal = portalURL.Hostname()
+		}
+		if portal != tc.portal {
+			t.Errorf("%s: expected portal %q, got %q", tc.path, tc.portal, portal)
+		}
+	}
+
+	// dns failure
+	p := testProber("http://connectivity.example.com/success")
+	p.lookupHost = func(ctx context.Context, host string) error { return errors.New("no such host") }
+	if state, _ := p.probe(context.Background()); state != LIMITED {
+		t.Errorf("expected limited connectivity without dns, got %d", state)
+	}
+
+	// no network
+	p = testProber(server.URL + "/success")
+	p.hasNetwork = func() bool { return false }
+	if state, _ := p.probe(context.Background()); state != OFFLINE {
+		t.Errorf("expected offline without network, got %d", state)
+	}
+
+	// server down
+	closedURL := server.URL + "/success"
+	server.Close()
+	if state, _ := testProber(closedURL).probe(context.Background()); state != LIMITED {
+		t.Errorf("expected limited connectivity if server is unreachable, got %d", state)
+	}
+}
+
+func TestCaptivePortalDomain(t *testing.T) {
+	allowPortalDomain("Portal.Example.com", time.Now())
+	if !IsCaptivePortalDomain("portal.example.com.") {
+		t.Error("portal domain should be permitted")
+	}
+	if IsCaptivePortalDomain("other.example.com.") {
+		t.Error("other domain should not be permitted")
+	}
+
+	allowPortalDomain("expired.example.com", time.Now().Add(-2*portalAllowDuration))
+	if IsCaptivePortalDomain("expired.example.com.") {
+		t.Error("expired portal domain should not be permitted")
+	}
+
+	resetConnectivity()
+	if IsCaptivePortalDomain("portal.example.com.") {
+		t.Error("portal domain should be revoked on network change")
+	}
+}
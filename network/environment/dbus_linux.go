@@ -203,6 +203,80 @@ func getConnectivityStateFromDbus() (uint8, error) {
 	return UNKNOWN, nil
 }
 
+func getWirelessSSIDFromDbus() (string, error) {
+	var err error
+
+	dbusConnLock.Lock()
+	defer dbusConnLock.Unlock()
+
+	if dbusConn == nil {
+		dbusConn, err = dbus.SystemBus()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	primaryConnectionVariant, err := getNetworkManagerProperty(dbusConn, dbus.ObjectPath("/org/freedesktop/NetworkManager"), "org.freedesktop.NetworkManager.PrimaryConnection")
+	if err != nil {
+		return "", err
+	}
+	primaryConnection, ok := primaryConnectionVariant.Value().(dbus.ObjectPath)
+	if !ok {
+		return "", errors.New("dbus: could not assert type of /org/freedesktop/NetworkManager:org.freedesktop.NetworkManager.PrimaryConnection")
+	}
+	if primaryConnection == "/" {
+		// no active connection
+		return "", nil
+	}
+
+	connectionTypeVariant, err := getNetworkManagerProperty(dbusConn, primaryConnection, "org.freedesktop.NetworkManager.Connection.Active.Type")
+	if err != nil {
+		return "", err
+	}
+	connectionType, ok := connectionTypeVariant.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("dbus: could not assert type of %s:org.freedesktop.NetworkManager.Connection.Active.Type", primaryConnection)
+	}
+	if connectionType != "802-11-wireless" {
+		return "", nil
+	}
+
+	devicesVariant, err := getNetworkManagerProperty(dbusConn, primaryConnection, "org.freedesktop.NetworkManager.Connection.Active.Devices")
+	if err != nil {
+		return "", err
+	}
+	devices, ok := devicesVariant.Value().([]dbus.ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("dbus: could not assert type of %s:org.freedesktop.NetworkManager.Connection.Active.Devices", primaryConnection)
+	}
+
+	for _, device := range devices {
+		accessPointVariant, err := getNetworkManagerProperty(dbusConn, device, "org.freedesktop.NetworkManager.Device.Wireless.ActiveAccessPoint")
+		if err != nil {
+			return "", err
+		}
+		accessPoint, ok := accessPointVariant.Value().(dbus.ObjectPath)
+		if !ok {
+			return "", fmt.Errorf("dbus: could not assert type of %s:org.freedesktop.NetworkManager.Device.Wireless.ActiveAccessPoint", device)
+		}
+		if accessPoint == "/" {
+			continue
+		}
+
+		ssidVariant, err := getNetworkManagerProperty(dbusConn, accessPoint, "org.freedesktop.NetworkManager.AccessPoint.Ssid")
+		if err != nil {
+			return "", err
+		}
+		ssid, ok := ssidVariant.Value().([]byte)
+		if !ok {
+			return "", fmt.Errorf("dbus: could not assert type of %s:org.freedesktop.NetworkManager.AccessPoint.Ssid", accessPoint)
+		}
+		return string(ssid), nil
+	}
+
+	return "", nil
+}
+
 func getNetworkManagerProperty(conn *dbus.Conn, objectPath dbus.ObjectPath, property string) (dbus.Variant, error) {
 	object := conn.Object("org.freedesktop.NetworkManager", objectPath)
 	return object.GetProperty(property)
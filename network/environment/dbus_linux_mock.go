@@ -12,3 +12,7 @@ func getNameserversFromDbus() ([]Nameserver, error) {
 func getConnectivityStateFromDbus() (uint8, error) {
 	return UNKNOWN, nil
 }
+
+func getWirelessSSIDFromDbus() (string, error) {
+	return "", nil
+}
@@ -16,4 +16,10 @@ func TestDbus(t *testing.T) {
 		t.Errorf("getConnectivityStateFromDbus failed: %s", err)
 	}
 	t.Logf("getConnectivityStateFromDbus: %v", connectivityState)
+
+	ssid, err := getWirelessSSIDFromDbus()
+	if err != nil {
+		t.Errorf("getWirelessSSIDFromDbus failed: %s", err)
+	}
+	t.Logf("getWirelessSSIDFromDbus: %s", ssid)
 }
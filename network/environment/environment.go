@@ -7,6 +7,7 @@ import (
 	"crypto/sha1"
 	"io"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,8 +35,13 @@ const (
 const (
 	connectivityRecheck = 2 * time.Second
 	interfacesRecheck   = 2 * time.Second
-	gatewaysRecheck     = 2 * time.Second
-	nameserversRecheck  = 2 * time.Second
+	// gateways and nameservers are reset on network events, the recheck is only a safeguard
+	gatewaysRecheck    = 1 * time.Minute
+	nameserversRecheck = 1 * time.Minute
+
+	networkChangeDebounce = 1 * time.Second
+	networkChangeMaxDelay = 5 * time.Second
+	networkPollInterval   = 2 * time.Second
 )
 
 var (
@@ -67,12 +73,6 @@ type Nameserver struct {
 func init() {
 	lnc := int64(0)
 	lastNetworkChange = &lnc
-	go func() {
-		time.Sleep(1 * time.Second)
-		Connectivity()
-	}()
-
-	go monitorNetworkChanges()
 }
 
 // Connectivity returns the current state of connectivity to the network/Internet
@@ -85,21 +85,28 @@ func Connectivity() uint8 {
 		return connectivity
 	}
 	// logic
-	// TODO: implement more methods
 	status, err := getConnectivityStateFromDbus()
 	if err != nil {
-		log.Warningf("environment: could not get connectivity: %s", err)
-		setConnectivity(UNKNOWN)
-		return UNKNOWN
+		log.Debugf("environment: could not get connectivity from dbus: %s", err)
+		status = UNKNOWN
+	}
+	// probe actively if NetworkManager is not available or to find the captive portal
+	if status == UNKNOWN || status == PORTAL {
+		// the prober updates the connectivity, keep the last known state until then
+		if time.Now().After(lastProbe.Add(probeRecheck)) {
+			triggerProbe()
+		}
+		connectivityExpires = time.Now().Add(connectivityRecheck)
+		return connectivity
 	}
-	setConnectivity(status)
+	setConnectivity(status, connectivityRecheck)
 	return status
 }
 
-func setConnectivity(status uint8) {
+func setConnectivity(status uint8, recheck time.Duration) {
+	connectivityExpires = time.Now().Add(recheck)
 	if connectivity != status {
 		connectivity = status
-		connectivityExpires = time.Now().Add(connectivityRecheck)
 
 		var connectivityName string
 		switch connectivity {
@@ -124,44 +131,104 @@ func setConnectivity(status uint8) {
 func ConnectionSucceeded() {
 	connectivityLock.Lock()
 	defer connectivityLock.Unlock()
-	setConnectivity(ONLINE)
+	setConnectivity(ONLINE, connectivityRecheck)
 }
 
 func monitorNetworkChanges() {
-	// TODO: make more elegant solution
+	events := make(chan struct{}, 1)
+	err := watchNetworkEvents(events)
+	if err != nil {
+		log.Warningf("environment: failed to watch network events, falling back to polling: %s", err)
+		go pollNetworkEvents(events)
+	}
+
+	lastNetworkChecksum = networkChecksum()
 	for {
-		time.Sleep(2 * time.Second)
-		hasher := sha1.New()
-		interfaces, err := net.Interfaces()
-		if err != nil {
-			log.Warningf("environment: failed to get interfaces: %s", err)
-			continue
-		}
-		for _, iface := range interfaces {
-			io.WriteString(hasher, iface.Name)
-			// log.Tracef("adding: %s", iface.Name)
-			io.WriteString(hasher, iface.Flags.String())
-			// log.Tracef("adding: %s", iface.Flags.String())
-			addrs, err := iface.Addrs()
-			if err != nil {
-				log.Warningf("environment: failed to get addrs from interface %s: %s", iface.Name, err)
-				continue
-			}
-			for _, addr := range addrs {
-				io.WriteString(hasher, addr.String())
-				// log.Tracef("adding: %s", addr.String())
+		<-events
+
+		// wait for the network to settle, as changes usually come in bursts
+		debounce := time.NewTimer(networkChangeDebounce)
+		maxDelay := time.NewTimer(networkChangeMaxDelay)
+	settle:
+		for {
+			select {
+			case <-events:
+				if !debounce.Stop() {
+					<-debounce.C
+				}
+				debounce.Reset(networkChangeDebounce)
+			case <-debounce.C:
+				break settle
+			case <-maxDelay.C:
+				break settle
 			}
 		}
-		newChecksum := hasher.Sum(nil)
+		debounce.Stop()
+		maxDelay.Stop()
+
+		resetCaches()
+		newChecksum := networkChecksum()
 		if !bytes.Equal(lastNetworkChecksum, newChecksum) {
-			if len(lastNetworkChecksum) == 0 {
-				lastNetworkChecksum = newChecksum
-				continue
-			}
 			lastNetworkChecksum = newChecksum
 			atomic.StoreInt64(lastNetworkChange, time.Now().Unix())
+			resetConnectivity()
 			log.Info("environment: network changed")
 			triggerNetworkChanged()
 		}
 	}
 }
+
+// pollNetworkEvents signals a possible network change in a fixed interval, on platforms where network events are not available.
+func pollNetworkEvents(events chan<- struct{}) {
+	for {
+		time.Sleep(networkPollInterval)
+		signalNetworkEvent(events)
+	}
+}
+
+func signalNetworkEvent(events chan<- struct{}) {
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+}
+
+// resetCaches forces gateways and nameservers to be re-read on the next request.
+func resetCaches() {
+	gatewaysLock.Lock()
+	gatewaysExpires = time.Now()
+	gatewaysLock.Unlock()
+
+	nameserversLock.Lock()
+	nameserversExpires = time.Now()
+	nameserversLock.Unlock()
+}
+
+// networkChecksum returns a checksum of the interfaces, addresses, gateways and nameservers.
+func networkChecksum() []byte {
+	hasher := sha1.New()
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Warningf("environment: failed to get interfaces: %s", err)
+	}
+	for _, iface := range interfaces {
+		io.WriteString(hasher, iface.Name)
+		io.WriteString(hasher, iface.Flags.String())
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Warningf("environment: failed to get addrs from interface %s: %s", iface.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			io.WriteString(hasher, addr.String())
+		}
+	}
+	for _, gateway := range Gateways() {
+		io.WriteString(hasher, gateway.String())
+	}
+	for _, nameserver := range Nameservers() {
+		io.WriteString(hasher, nameserver.IP.String())
+		io.WriteString(hasher, strings.Join(nameserver.Search, " "))
+	}
+	return hasher.Sum(nil)
+}
@@ -5,8 +5,10 @@ package environment
 import (
 	"bufio"
 	"encoding/hex"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -25,13 +27,14 @@ func Gateways() []*net.IP {
 	if gatewaysExpires.After(time.Now()) {
 		return gateways
 	}
-	// update cache expiry when finished
+	// update cache when finished
+	newGateways := make([]*net.IP, 0)
 	defer func() {
+		gateways = newGateways
 		gatewaysExpires = time.Now().Add(gatewaysRecheck)
 	}()
 	// logic
 
-	newGateways := make([]*net.IP, 0)
 	var decoded []byte
 
 	// open file
@@ -206,3 +209,67 @@ func addNameservers(nameservers, newNameservers []Nameserver) []Nameserver {
 	}
 	return nameservers
 }
+
+// gatewayMACsSupported defines whether getHardwareAddress is implemented on this platform.
+const gatewayMACsSupported = true
+
+// getHardwareAddress returns the hardware address of the given IP as found in the ARP table.
+func getHardwareAddress(ip net.IP) string {
+	// open file
+	arp, err := os.Open("/proc/net/arp")
+	if err != nil {
+		log.Warningf("environment: could not read /proc/net/arp: %s", err)
+		return ""
+	}
+	defer arp.Close()
+
+	// file scanner
+	scanner := bufio.NewScanner(arp)
+	scanner.Split(bufio.ScanLines)
+
+	// parse
+	// IP address       HW type     Flags       HW address            Mask     Device
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		if lineIP := net.ParseIP(fields[0]); lineIP != nil && lineIP.Equal(ip) {
+			return strings.ToLower(fields[3])
+		}
+	}
+
+	return ""
+}
+
+func getWirelessSSID() string {
+	ssid, err := getWirelessSSIDFromDbus()
+	if err != nil {
+		log.Debugf("environment: could not get wireless ssid from dbus: %s", err)
+		return ""
+	}
+	return ssid
+}
+
+// isVPNInterfaceType checks the interface type via sysfs, as VPN interfaces may have arbitrary names.
+func isVPNInterfaceType(name string) bool {
+	// tun and tap devices
+	if _, err := os.Stat(filepath.Join("/sys/class/net", name, "tun_flags")); err == nil {
+		return true
+	}
+
+	// wireguard devices
+	uevent, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name, "uevent"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(uevent), "\n") {
+		if line == "DEVTYPE=wireguard" {
+			return true
+		}
+	}
+	return false
+}
@@ -9,3 +9,18 @@ func Nameservers() []Nameserver {
 func Gateways() []*net.IP {
 	return nil
 }
+
+// gatewayMACsSupported defines whether getHardwareAddress is implemented on this platform.
+const gatewayMACsSupported = false
+
+func getHardwareAddress(ip net.IP) string {
+	return ""
+}
+
+func getWirelessSSID() string {
+	return ""
+}
+
+func isVPNInterfaceType(name string) bool {
+	return false
+}
@@ -0,0 +1,198 @@
+package environment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Safing/portbase/log"
+)
+
+var (
+	// gatewayResolveAttempts and gatewayResolveInterval define how long to wait for the hardware addresses of gateways to appear in the ARP table.
+	gatewayResolveAttempts = 5
+	gatewayResolveInterval = 200 * time.Millisecond
+)
+
+// NetworkIdentity holds the attributes that identify the network this host is connected to.
+type NetworkIdentity struct {
+	// GatewayMACs are the hardware addresses of the gateways, as seen in the ARP table.
+	GatewayMACs []string
+	// SearchDomains are the DNS search domains, as assigned via DHCP.
+	SearchDomains []string
+	// SSID is the name of the wireless network, if connected via wifi.
+	SSID string
+	// Prefixes are the network prefixes of the assigned addresses.
+	Prefixes []string
+	// UnresolvedGateways are the IPv4 gateways whose hardware address is not known yet.
+	UnresolvedGateways []string
+}
+
+// CurrentNetworkIdentity returns the identity of the currently connected network.
+func CurrentNetworkIdentity() *NetworkIdentity {
+	ni := &NetworkIdentity{
+		SSID: getWirelessSSID(),
+	}
+
+	ni.GatewayMACs, ni.UnresolvedGateways = resolveGatewayMACs(Gateways())
+
+	for _, nameserver := range Nameservers() {
+		for _, domain := range nameserver.Search {
+			ni.SearchDomains = appendUnique(ni.SearchDomains, strings.ToLower(strings.TrimSuffix(domain, ".")))
+		}
+	}
+
+	ni.Prefixes = getAssignedPrefixes()
+
+	sort.Strings(ni.GatewayMACs)
+	sort.Strings(ni.SearchDomains)
+	sort.Strings(ni.Prefixes)
+	return ni
+}
+
+// ID returns a stable identifier for the network. Prefixes are only considered if the network has no gateway with a hardware address, as they may change over time. An empty string is returned, if the network cannot be identified (yet).
+func (ni *NetworkIdentity) ID() string {
+	if len(ni.GatewayMACs) == 0 && len(ni.SearchDomains) == 0 && ni.SSID == "" && len(ni.Prefixes) == 0 {
+		return ""
+	}
+	// falling back to the prefixes would give the network a different ID than it gets once the gateways are resolved
+	if len(ni.UnresolvedGateways) > 0 {
+		return ""
+	}
+
+	hasher := sha256.New()
+	for _, mac := range ni.GatewayMACs {
+		io.WriteString(hasher, "mac:"+mac+"\n")
+	}
+	for _, domain := range ni.SearchDomains {
+		io.WriteString(hasher, "domain:"+domain+"\n")
+	}
+	if ni.SSID != "" {
+		io.WriteString(hasher, "ssid:"+ni.SSID+"\n")
+	}
+	if len(ni.GatewayMACs) == 0 {
+		for _, prefix := range ni.Prefixes {
+			io.WriteString(hasher, "prefix:"+prefix+"\n")
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)[:16])
+}
+
+// String returns a human readable representation of the network identity.
+func (ni *NetworkIdentity) String() string {
+	var parts []string
+	if ni.SSID != "" {
+		parts = append(parts, fmt.Sprintf("ssid=%s", ni.SSID))
+	}
+	if len(ni.GatewayMACs) > 0 {
+		parts = append(parts, fmt.Sprintf("gateways=%s", strings.Join(ni.GatewayMACs, ",")))
+	}
+	if len(ni.SearchDomains) > 0 {
+		parts = append(parts, fmt.Sprintf("domains=%s", strings.Join(ni.SearchDomains, ",")))
+	}
+	if len(ni.Prefixes) > 0 {
+		parts = append(parts, fmt.Sprintf("prefixes=%s", strings.Join(ni.Prefixes, ",")))
+	}
+	if len(ni.UnresolvedGateways) > 0 {
+		parts = append(parts, fmt.Sprintf("unresolved=%s", strings.Join(ni.UnresolvedGateways, ",")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolveGatewayMACs returns the hardware addresses of the IPv4 gateways and the gateways that could not be resolved. Gateways missing from the ARP table are contacted to trigger address resolution and are checked again for a short while.
+func resolveGatewayMACs(gateways []*net.IP) (macs, unresolved []string) {
+	if !gatewayMACsSupported {
+		return nil, nil
+	}
+
+	var pending []net.IP
+	for _, gateway := range gateways {
+		// IPv6 neighbors are not listed in the ARP table
+		if gateway.To4() != nil {
+			pending = append(pending, *gateway)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var stillPending []net.IP
+		for _, gateway := range pending {
+			if mac := getHardwareAddress(gateway); mac != "" {
+				macs = appendUnique(macs, mac)
+			} else {
+				stillPending = append(stillPending, gateway)
+			}
+		}
+		pending = stillPending
+		if len(pending) == 0 || attempt >= gatewayResolveAttempts {
+			break
+		}
+
+		if attempt == 0 {
+			for _, gateway := range pending {
+				triggerAddressResolution(gateway)
+			}
+		}
+		time.Sleep(gatewayResolveInterval)
+	}
+
+	for _, gateway := range pending {
+		unresolved = append(unresolved, gateway.String())
+	}
+	return macs, unresolved
+}
+
+// triggerAddressResolution sends a datagram to the discard port of the given IP, which makes the kernel resolve its hardware address.
+func triggerAddressResolution(ip net.IP) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: 9})
+	if err != nil {
+		log.Debugf("environment: failed to contact gateway %s: %s", ip, err)
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{0})
+	if err != nil {
+		log.Debugf("environment: failed to contact gateway %s: %s", ip, err)
+	}
+}
+
+func getAssignedPrefixes() (prefixes []string) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			ones, _ := ipNet.Mask.Size()
+			prefix := fmt.Sprintf("%s/%d", ipNet.IP.Mask(ipNet.Mask), ones)
+			prefixes = appendUnique(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func appendUnique(list []string, entry string) []string {
+	for _, existing := range list {
+		if existing == entry {
+			return list
+		}
+	}
+	return append(list, entry)
+}
@@ -0,0 +1,47 @@
+package environment
+
+import "testing"
+
+func TestNetworkIdentityID(t *testing.T) {
+	empty := &NetworkIdentity{}
+	if empty.ID() != "" {
+		t.Errorf("empty identity should not have an ID, got %s", empty.ID())
+	}
+
+	home := &NetworkIdentity{
+		GatewayMACs:   []string{"00:11:22:33:44:55"},
+		SearchDomains: []string{"home.lan"},
+		Prefixes:      []string{"192.168.1.0/24"},
+	}
+	renumbered := &NetworkIdentity{
+		GatewayMACs:   []string{"00:11:22:33:44:55"},
+		SearchDomains: []string{"home.lan"},
+		Prefixes:      []string{"192.168.2.0/24"},
+	}
+	if home.ID() != renumbered.ID() {
+		t.Error("prefixes should not change the ID if a gateway is known")
+	}
+
+	cafe := &NetworkIdentity{
+		GatewayMACs: []string{"66:77:88:99:aa:bb"},
+		SSID:        "Cafe",
+	}
+	if home.ID() == cafe.ID() {
+		t.Error("different networks must have different IDs")
+	}
+
+	noGateway := &NetworkIdentity{Prefixes: []string{"10.0.0.0/8"}}
+	otherNoGateway := &NetworkIdentity{Prefixes: []string{"172.16.0.0/12"}}
+	if noGateway.ID() == "" || noGateway.ID() == otherNoGateway.ID() {
+		t.Error("prefixes should identify networks without a known gateway")
+	}
+
+	resolving := &NetworkIdentity{
+		SearchDomains:      []string{"home.lan"},
+		Prefixes:           []string{"192.168.1.0/24"},
+		UnresolvedGateways: []string{"192.168.1.1"},
+	}
+	if resolving.ID() != "" {
+		t.Error("a network with unresolved gateways must not be identified before they are resolved")
+	}
+}
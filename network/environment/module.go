@@ -0,0 +1,53 @@
+package environment
+
+import (
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portmaster/internal/modstatus"
+)
+
+var (
+	connectivityCheckURL  = func() string { return DefaultConnectivityCheckURL }
+	connectivityCheckBody = func() string { return DefaultConnectivityCheckBody }
+)
+
+func init() {
+	modstatus.Register("network:environment", prep, start, nil, "core")
+}
+
+func prep() error {
+	err := config.Register(&config.Option{
+		Name:            "Connectivity Check URL",
+		Key:             "network/connectivityCheckURL",
+		Description:     "URL that is requested to check for connectivity and captive portals, if NetworkManager is not available. It must be plain HTTP, as portals cannot intercept HTTPS.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeString,
+		DefaultValue:    DefaultConnectivityCheckURL,
+		ValidationRegex: "^http://",
+	})
+	if err != nil {
+		return err
+	}
+	connectivityCheckURL = config.Concurrent.GetAsString("network/connectivityCheckURL", DefaultConnectivityCheckURL)
+
+	err = config.Register(&config.Option{
+		Name:           "Connectivity Check Response",
+		Key:            "network/connectivityCheckBody",
+		Description:    "Expected response body of the connectivity check URL. Any other response is regarded as being intercepted by a captive portal.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeString,
+		DefaultValue:   DefaultConnectivityCheckBody,
+	})
+	if err != nil {
+		return err
+	}
+	connectivityCheckBody = config.Concurrent.GetAsString("network/connectivityCheckBody", DefaultConnectivityCheckBody)
+
+	return nil
+}
+
+func start() error {
+	go monitorNetworkChanges()
+	go connectivityProber()
+	triggerProbe()
+	return nil
+}
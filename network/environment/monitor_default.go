@@ -0,0 +1,10 @@
+// +build !linux
+
+package environment
+
+import "errors"
+
+// watchNetworkEvents is not yet implemented on this platform, network changes are detected by polling.
+func watchNetworkEvents(events chan<- struct{}) error {
+	return errors.New("not supported on this platform")
+}
@@ -0,0 +1,154 @@
+package environment
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/Safing/portbase/log"
+)
+
+const (
+	resolvconfPath = "/etc/resolv.conf"
+
+	rtnetlinkGroups = 1<<(syscall.RTNLGRP_LINK-1) |
+		1<<(syscall.RTNLGRP_IPV4_IFADDR-1) |
+		1<<(syscall.RTNLGRP_IPV6_IFADDR-1) |
+		1<<(syscall.RTNLGRP_IPV4_ROUTE-1) |
+		1<<(syscall.RTNLGRP_IPV6_ROUTE-1)
+
+	resolvconfWatchMask = syscall.IN_CLOSE_WRITE |
+		syscall.IN_CREATE |
+		syscall.IN_DELETE |
+		syscall.IN_MOVED_FROM |
+		syscall.IN_MOVED_TO
+)
+
+// watchNetworkEvents subscribes to link, address and route changes via rtnetlink and watches resolv.conf via inotify. Every event is signaled on the given channel.
+func watchNetworkEvents(events chan<- struct{}) error {
+	netlinkFD, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("failed to open rtnetlink socket: %s", err)
+	}
+	err = syscall.Bind(netlinkFD, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtnetlinkGroups,
+	})
+	if err != nil {
+		syscall.Close(netlinkFD)
+		return fmt.Errorf("failed to subscribe to rtnetlink: %s", err)
+	}
+	go readRtnetlink(netlinkFD, events)
+
+	// a missing resolv.conf watch is not fatal, as nameserver changes usually come with link or address changes
+	err = watchResolvconf(events)
+	if err != nil {
+		log.Warningf("environment: failed to watch %s: %s", resolvconfPath, err)
+	}
+
+	return nil
+}
+
+func readRtnetlink(fd int, events chan<- struct{}) {
+	buf := make([]byte, syscall.Getpagesize()*4)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			switch err {
+			case syscall.EINTR:
+				continue
+			case syscall.ENOBUFS:
+				// we missed messages, check anyway
+				signalNetworkEvent(events)
+				continue
+			}
+			log.Warningf("environment: failed to read from rtnetlink, falling back to polling: %s", err)
+			syscall.Close(fd)
+			pollNetworkEvents(events)
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			log.Warningf("environment: failed to parse rtnetlink message: %s", err)
+			continue
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.RTM_NEWLINK, syscall.RTM_DELLINK,
+				syscall.RTM_NEWADDR, syscall.RTM_DELADDR,
+				syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+				signalNetworkEvent(events)
+			}
+		}
+	}
+}
+
+// watchResolvconf watches the directories of resolv.conf and its symlink target, as resolv.conf is usually replaced instead of written to.
+func watchResolvconf(events chan<- struct{}) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{resolvconfPath}
+	if target, err := filepath.EvalSymlinks(resolvconfPath); err == nil && target != resolvconfPath {
+		paths = append(paths, target)
+	}
+
+	names := make(map[string]struct{})
+	var watched int
+	for _, path := range paths {
+		_, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), resolvconfWatchMask)
+		if err != nil {
+			log.Warningf("environment: failed to watch %s: %s", filepath.Dir(path), err)
+			continue
+		}
+		names[filepath.Base(path)] = struct{}{}
+		watched++
+	}
+	if watched == 0 {
+		syscall.Close(fd)
+		return fmt.Errorf("no directory could be watched")
+	}
+
+	go readInotify(fd, names, events)
+	return nil
+}
+
+func readInotify(fd int, names map[string]struct{}, events chan<- struct{}) {
+	defer syscall.Close(fd)
+
+	buf := make([]byte, (syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)*16)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			log.Warningf("environment: failed to read from inotify, stopped watching %s: %s", resolvconfPath, err)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > n {
+				break
+			}
+			offset = nameEnd
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				signalNetworkEvent(events)
+				continue
+			}
+			name := strings.TrimRight(string(buf[nameStart:nameEnd]), "\x00")
+			if _, ok := names[name]; ok {
+				signalNetworkEvent(events)
+			}
+		}
+	}
+}
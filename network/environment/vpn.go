@@ -0,0 +1,55 @@
+package environment
+
+import (
+	"net"
+	"path"
+	"strings"
+)
+
+var (
+	// vpnInterfacePrefixes are name prefixes of interfaces that are commonly used by VPN software.
+	vpnInterfacePrefixes = []string{
+		"tun",  // OpenVPN, OpenConnect and others
+		"tap",  // OpenVPN in bridged mode
+		"wg",   // WireGuard
+		"utun", // macOS
+	}
+)
+
+// IsVPNInterface returns whether the network interface with the given name is a VPN interface. Interfaces matching one of the given patterns (see path.Match) are regarded as VPN interfaces, too.
+func IsVPNInterface(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	for _, prefix := range vpnInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return isVPNInterfaceType(name)
+}
+
+// InterfaceByIP returns the name of the network interface the given local IP is assigned to, or an empty string if not found.
+func InterfaceByIP(ip net.IP) string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
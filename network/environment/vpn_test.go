@@ -0,0 +1,18 @@
+package environment
+
+import "testing"
+
+func TestIsVPNInterface(t *testing.T) {
+	patterns := []string{"corp*"}
+
+	for _, name := range []string{"tun0", "tap1", "wg0", "utun2", "corp-vpn"} {
+		if !IsVPNInterface(name, patterns) {
+			t.Errorf("%s should be detected as a VPN interface", name)
+		}
+	}
+	for _, name := range []string{"notavpn0", "vpncorp"} {
+		if IsVPNInterface(name, patterns) {
+			t.Errorf("%s should not be detected as a VPN interface", name)
+		}
+	}
+}
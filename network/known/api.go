@@ -0,0 +1,102 @@
+package known
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portmaster/internal/apiutil"
+	"github.com/Safing/portmaster/status"
+)
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/network/v1/known", handleListNetworks).Methods("GET")
+	api.RegisterHandleFunc("/api/network/v1/known/current", handleCurrentNetwork).Methods("GET")
+	api.RegisterHandleFunc("/api/network/v1/known/{id:[a-f0-9]+}", apiutil.Protect(handleUpdateNetwork)).Methods("POST")
+	return nil
+}
+
+func handleListNetworks(w http.ResponseWriter, r *http.Request) {
+	networks, err := AllNetworks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apiutil.WriteJSON(w, networks)
+}
+
+func handleCurrentNetwork(w http.ResponseWriter, r *http.Request) {
+	n := Current()
+	if n == nil {
+		http.Error(w, "not connected to a known network", http.StatusNotFound)
+		return
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	apiutil.WriteJSON(w, n)
+}
+
+// handleUpdateNetwork changes the settings given as the query parameters trust, securityLevel, overlay and name.
+func handleUpdateNetwork(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var trust, securityLevel *uint8
+	if value := params.Get("trust"); value != "" {
+		parsed, err := ParseTrust(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		trust = &parsed
+	}
+	if value := params.Get("securityLevel"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			http.Error(w, "invalid securityLevel parameter", http.StatusBadRequest)
+			return
+		}
+		switch uint8(parsed) {
+		case status.SecurityLevelOff, status.SecurityLevelDynamic, status.SecurityLevelSecure, status.SecurityLevelFortress:
+		default:
+			http.Error(w, "securityLevel must be 0 (trust default), 1, 2 or 4", http.StatusBadRequest)
+			return
+		}
+		level := uint8(parsed)
+		securityLevel = &level
+	}
+	_, setOverlay := params["overlay"]
+	name := params.Get("name")
+
+	n, err := UpdateNetwork(mux.Vars(r)["id"], func(n *Network) error {
+		if trust != nil {
+			n.Trust = *trust
+		}
+		if securityLevel != nil {
+			n.SecurityLevel = *securityLevel
+		}
+		if setOverlay {
+			n.ProfileOverlay = params.Get("overlay")
+		}
+		if name != "" {
+			n.Name = name
+		}
+		return nil
+	})
+	switch err {
+	case nil:
+	case database.ErrNotFound:
+		http.Error(w, "unknown network", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	apiutil.WriteJSON(w, n)
+}
@@ -0,0 +1,263 @@
+package known
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portbase/notifications"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/network/environment"
+	"github.com/Safing/portmaster/profile"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	trustPromptTTL = 24 * time.Hour
+	// identifyRetryDelay defines when to try again to identify a network whose gateways were not resolved yet.
+	identifyRetryDelay = 10 * time.Second
+
+	adaptToNetwork config.BoolOption
+
+	current     *Network
+	currentLock sync.Mutex
+
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("network:known", prep, start, stop, "core", "profile", "status", "api")
+}
+
+func prep() error {
+	err := config.Register(&config.Option{
+		Name:           "Adapt to Known Networks",
+		Key:            "network/adaptToKnownNetworks",
+		Description:    "Select the security level and profile overlay based on the trust level of the connected network. New networks are treated like public networks until you rate them.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeBool,
+		DefaultValue:   true,
+	})
+	if err != nil {
+		return err
+	}
+	adaptToNetwork = config.Concurrent.GetAsBool("network/adaptToKnownNetworks", true)
+
+	return registerAPI()
+}
+
+func start() error {
+	go networkWatcher()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	return nil
+}
+
+func networkWatcher() {
+	for {
+		changed := environment.NetworkChanged()
+		var retry <-chan time.Time
+		if !handleNetworkChange() {
+			retry = time.After(identifyRetryDelay)
+		}
+
+		select {
+		case <-shutdownSignal:
+			return
+		case <-changed:
+		case <-retry:
+		}
+	}
+}
+
+// Current returns the network this host is currently connected to, or nil if offline or unknown.
+func Current() *Network {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	return current
+}
+
+// handleNetworkChange identifies the connected network and applies its settings. It returns false if the network should be identified again later, as its gateways were not resolved yet.
+func handleNetworkChange() (done bool) {
+	identity := environment.CurrentNetworkIdentity()
+	id := identity.ID()
+	if id == "" {
+		if len(identity.UnresolvedGateways) > 0 {
+			log.Infof("known: waiting for gateways to resolve before identifying network [%s]", identity)
+		} else {
+			log.Infof("known: not connected to an identifiable network")
+		}
+		currentLock.Lock()
+		current = nil
+		currentLock.Unlock()
+		applyNetwork(nil)
+		return len(identity.UnresolvedGateways) == 0
+	}
+
+	now := time.Now().Unix()
+	n, err := GetNetwork(id)
+	isNew := false
+	switch err {
+	case nil:
+	case database.ErrNotFound:
+		isNew = true
+		n = &Network{
+			ID:        id,
+			Name:      defaultName(identity),
+			Trust:     TrustUnknown,
+			FirstSeen: now,
+		}
+	default:
+		log.Warningf("known: failed to load known network %s: %s", id, err)
+		return true
+	}
+
+	n.Lock()
+	n.Identity = identity
+	n.LastSeen = now
+	n.Unlock()
+	err = n.Save()
+	if err != nil {
+		log.Warningf("known: failed to save known network %s: %s", id, err)
+	}
+
+	currentLock.Lock()
+	current = n
+	currentLock.Unlock()
+
+	log.Infof("known: connected to %s [%s]", n, identity)
+	applyNetwork(n)
+
+	if isNew {
+		go promptForTrust(n)
+	}
+	return true
+}
+
+// applyNetwork selects the security level and profile overlay of the given network. A nil network resets both.
+func applyNetwork(n *Network) {
+	if n == nil || !adaptToNetwork() {
+		status.SetNetworkSecurityLevel(status.SecurityLevelOff)
+		err := profile.SetNetworkProfile("")
+		if err != nil {
+			log.Warningf("known: failed to reset network profile: %s", err)
+		}
+		return
+	}
+
+	n.Lock()
+	securityLevel := n.EffectiveSecurityLevel()
+	overlay := n.ProfileOverlay
+	n.Unlock()
+
+	status.SetNetworkSecurityLevel(securityLevel)
+	err := profile.SetNetworkProfile(overlay)
+	if err != nil {
+		log.Warningf("known: failed to apply profile overlay of %s: %s", n, err)
+	}
+}
+
+// UpdateNetwork changes the user settings of a known network and applies them, if it is the current network.
+func UpdateNetwork(id string, update func(n *Network) error) (*Network, error) {
+	n, err := GetNetwork(id)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Lock()
+	err = update(n)
+	n.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	err = n.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	currentLock.Lock()
+	isCurrent := current != nil && current.ID == id
+	if isCurrent {
+		current = n
+	}
+	currentLock.Unlock()
+
+	if isCurrent {
+		applyNetwork(n)
+	}
+	return n, nil
+}
+
+// SetTrust sets the trust level of a known network.
+func SetTrust(id string, trust uint8) error {
+	if _, ok := trustNames[trust]; !ok {
+		return ErrInvalidTrust
+	}
+
+	_, err := UpdateNetwork(id, func(n *Network) error {
+		n.Trust = trust
+		return nil
+	})
+	return err
+}
+
+func promptForTrust(n *Network) {
+	n.Lock()
+	id := n.ID
+	name := n.Name
+	n.Unlock()
+
+	nID := fmt.Sprintf("known:trust-%s", id)
+	if notifications.Get(nID) != nil {
+		return
+	}
+
+	notif := (&notifications.Notification{
+		ID:      nID,
+		Type:    notifications.Prompt,
+		Message: fmt.Sprintf("You joined the new network %s, it is treated as a public network until you rate it. Which kind of network is it?", name),
+		Expires: time.Now().Add(trustPromptTTL).Unix(),
+		AvailableActions: []*notifications.Action{
+			&notifications.Action{
+				ID:   TrustName(TrustHome),
+				Text: "Home",
+			},
+			&notifications.Action{
+				ID:   TrustName(TrustWork),
+				Text: "Work",
+			},
+			&notifications.Action{
+				ID:   TrustName(TrustPublic),
+				Text: "Public",
+			},
+		},
+	}).Init().Save()
+
+	select {
+	case response := <-notif.Response():
+		notif.Cancel()
+
+		trust, err := ParseTrust(response)
+		if err != nil {
+			log.Warningf("known: received invalid trust level for network %s: %s", id, response)
+			return
+		}
+		err = SetTrust(id, trust)
+		if err != nil {
+			log.Warningf("known: failed to set trust level of network %s: %s", id, err)
+			return
+		}
+		log.Infof("known: user rated network %s as %s", name, TrustName(trust))
+	case <-time.After(trustPromptTTL):
+		notif.Cancel()
+	case <-shutdownSignal:
+	}
+}
@@ -0,0 +1,176 @@
+package known
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/database/query"
+	"github.com/Safing/portbase/database/record"
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/network/environment"
+	"github.com/Safing/portmaster/status"
+)
+
+// Trust Levels
+const (
+	TrustUnknown uint8 = iota // not yet rated by the user, handled like a public network
+	TrustPublic               // eg. café or airport wifi
+	TrustWork
+	TrustHome
+)
+
+const (
+	knownNetworksKeyPrefix = "core:network/known/"
+)
+
+var (
+	knownNetworksDB = database.NewInterface(nil)
+
+	trustNames = map[uint8]string{
+		TrustUnknown: "unknown",
+		TrustPublic:  "public",
+		TrustWork:    "work",
+		TrustHome:    "home",
+	}
+
+	// ErrInvalidTrust is returned when parsing an unknown trust level.
+	ErrInvalidTrust = errors.New("invalid trust level, must be one of unknown, public, work or home")
+)
+
+// Network is a network this host was connected to before.
+type Network struct {
+	record.Base
+	sync.Mutex
+
+	ID       string
+	Name     string
+	Identity *environment.NetworkIdentity
+
+	Trust uint8
+	// SecurityLevel overrides the default security level of the trust level, if set.
+	SecurityLevel uint8
+	// ProfileOverlay is the ID of a special profile that overlays all profiles while connected to this network.
+	ProfileOverlay string
+
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// TrustName returns the name of the given trust level.
+func TrustName(trust uint8) string {
+	name, ok := trustNames[trust]
+	if !ok {
+		return "invalid"
+	}
+	return name
+}
+
+// ParseTrust returns the trust level with the given name.
+func ParseTrust(name string) (uint8, error) {
+	for trust, trustName := range trustNames {
+		if trustName == strings.ToLower(name) {
+			return trust, nil
+		}
+	}
+	return 0, ErrInvalidTrust
+}
+
+// DefaultSecurityLevel returns the security level that is selected for networks with the given trust level. Networks that were not rated by the user are treated like public networks.
+func DefaultSecurityLevel(trust uint8) uint8 {
+	switch trust {
+	case TrustHome, TrustWork:
+		return status.SecurityLevelDynamic
+	default:
+		return status.SecurityLevelSecure
+	}
+}
+
+// EffectiveSecurityLevel returns the security level that applies while connected to the network. The network must be locked.
+func (n *Network) EffectiveSecurityLevel() uint8 {
+	if n.SecurityLevel > 0 {
+		return n.SecurityLevel
+	}
+	return DefaultSecurityLevel(n.Trust)
+}
+
+// String returns a human readable representation of the network.
+func (n *Network) String() string {
+	n.Lock()
+	defer n.Unlock()
+
+	return fmt.Sprintf("%s (%s, %s)", n.Name, TrustName(n.Trust), n.ID)
+}
+
+// GetNetwork loads a known network from the database.
+func GetNetwork(id string) (*Network, error) {
+	r, err := knownNetworksDB.Get(knownNetworksKeyPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	return EnsureNetwork(r)
+}
+
+// AllNetworks returns all known networks.
+func AllNetworks() ([]*Network, error) {
+	it, err := knownNetworksDB.Query(query.New(knownNetworksKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []*Network
+	for r := range it.Next {
+		n, err := EnsureNetwork(r)
+		if err != nil {
+			log.Warningf("known: failed to parse known network %s: %s", r.Key(), err)
+			continue
+		}
+		networks = append(networks, n)
+	}
+	return networks, it.Err()
+}
+
+// Save saves the known network to the database.
+func (n *Network) Save() error {
+	if !n.KeyIsSet() {
+		n.SetKey(knownNetworksKeyPrefix + n.ID)
+	}
+	return knownNetworksDB.Put(n)
+}
+
+// EnsureNetwork ensures that the given record is a *Network, and returns it.
+func EnsureNetwork(r record.Record) (*Network, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &Network{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*Network)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *Network, but %T", r)
+	}
+	return new, nil
+}
+
+// defaultName returns a name for a newly seen network.
+func defaultName(identity *environment.NetworkIdentity) string {
+	switch {
+	case identity.SSID != "":
+		return identity.SSID
+	case len(identity.SearchDomains) > 0:
+		return identity.SearchDomains[0]
+	case len(identity.Prefixes) > 0:
+		return identity.Prefixes[0]
+	default:
+		return "Unnamed Network"
+	}
+}
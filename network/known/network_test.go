@@ -0,0 +1,32 @@
+package known
+
+import (
+	"testing"
+
+	"github.com/Safing/portmaster/status"
+)
+
+func TestTrust(t *testing.T) {
+	for trust := range trustNames {
+		parsed, err := ParseTrust(TrustName(trust))
+		if err != nil || parsed != trust {
+			t.Errorf("failed to parse trust level %s", TrustName(trust))
+		}
+	}
+	if _, err := ParseTrust("friends"); err != ErrInvalidTrust {
+		t.Error("should fail to parse invalid trust level")
+	}
+
+	n := &Network{Trust: TrustUnknown}
+	if n.EffectiveSecurityLevel() != status.SecurityLevelSecure {
+		t.Error("unrated networks should be treated like public networks")
+	}
+	n.Trust = TrustHome
+	if n.EffectiveSecurityLevel() != status.SecurityLevelDynamic {
+		t.Error("home networks should select the dynamic security level")
+	}
+	n.SecurityLevel = status.SecurityLevelFortress
+	if n.EffectiveSecurityLevel() != status.SecurityLevelFortress {
+		t.Error("the security level set by the user should take precedence")
+	}
+}
@@ -298,6 +298,18 @@ func GetLink(id string) (*Link, bool) {
 	return link, ok
 }
 
+// GetLinks returns all currently known Links.
+func GetLinks() []*Link {
+	linksLock.RLock()
+	defer linksLock.RUnlock()
+
+	all := make([]*Link, 0, len(links))
+	for _, link := range links {
+		all = append(all, link)
+	}
+	return all
+}
+
 // GetOrCreateLinkByPacket returns the associated Link for a packet and a bool expressing if the Link was newly created
 func GetOrCreateLinkByPacket(pkt packet.Packet) (*Link, bool) {
 	link, ok := GetLink(pkt.GetLinkID())
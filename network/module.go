@@ -1,11 +1,11 @@
 package network
 
 import (
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 )
 
 func init() {
-	modules.Register("network", nil, start, nil, "database")
+	modstatus.Register("network", nil, start, nil, "database")
 }
 
 func start() error {
@@ -15,6 +15,9 @@ type Info struct {
 	Src, Dst         net.IP
 	Protocol         IPProtocol
 	SrcPort, DstPort uint16
+
+	// InIface and OutIface hold the index of the network interface the packet was received on or is sent out of, zero if unknown.
+	InIface, OutIface uint32
 }
 
 // LocalIP returns the local IP of the packet.
@@ -48,3 +51,11 @@ func (pi *Info) RemotePort() uint16 {
 	}
 	return pi.DstPort
 }
+
+// Iface returns the index of the network interface the packet passes, or zero if unknown.
+func (pi *Info) Iface() uint32 {
+	if pi.Direction {
+		return pi.InIface
+	}
+	return pi.OutIface
+}
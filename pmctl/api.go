@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Safing/portmaster/internal/apiutil"
+)
+
+var (
+	apiAddress *string
+
+	apiClient = &http.Client{
+		Timeout: 30 * time.Second,
+	}
+)
+
+func init() {
+	apiAddress = rootCmd.PersistentFlags().String("api", "127.0.0.1:817", "set address of the Portmaster API")
+}
+
+// callAPI sends a request to the Portmaster API and decodes the JSON response into result, if given.
+func callAPI(method, path string, result interface{}) error {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", *apiAddress, path), nil)
+	if err != nil {
+		return err
+	}
+	// required for requests that change state
+	req.Header.Set(apiutil.RequestHeader, "pmctl")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact Portmaster (is it running?): %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed (%s): %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
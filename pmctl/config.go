@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Safing/portbase/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	configKeyPrefix = "config:"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+}
+
+// configRecord is a config option as exported by the config database, including its current value.
+type configRecord struct {
+	config.Option
+	Value interface{}
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage settings",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Show a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opt := &configRecord{}
+		err := withDatabaseAPI(func(db *dbClient) error {
+			return db.Get(configKeyPrefix+args[0], opt)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s (%s)\n", opt.Key, opt.Name)
+		if opt.Description != "" {
+			fmt.Printf("  %s\n", opt.Description)
+		}
+		fmt.Printf("  Default: %s\n", fmtConfigValue(opt.DefaultValue))
+		if opt.Value != nil {
+			fmt.Printf("  Value:   %s\n", fmtConfigValue(opt.Value))
+		} else {
+			fmt.Printf("  Value:   (default)\n")
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Long:  "Change a setting. Lists are given as comma separated values or as a JSON array.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := configKeyPrefix + args[0]
+		var value interface{}
+		err := withDatabaseAPI(func(db *dbClient) error {
+			opt := &configRecord{}
+			err := db.Get(key, opt)
+			if err != nil {
+				return err
+			}
+			value, err = parseConfigValue(opt, strings.TrimSpace(args[1]))
+			if err != nil {
+				return err
+			}
+
+			// the config database applies the value when the option record is saved
+			var optRecord map[string]interface{}
+			err = db.Get(key, &optRecord)
+			if err != nil {
+				return err
+			}
+			optRecord["Value"] = value
+			return db.Update(key, optRecord)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s set %s to %s\n", logPrefix, args[0], fmtConfigValue(value))
+		return nil
+	},
+}
+
+func fmtConfigValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case nil:
+		return "(none)"
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(data)
+	}
+}
+
+// parseConfigValue converts a textual value to the type of the config option. String arrays are accepted as JSON arrays or comma separated lists.
+func parseConfigValue(opt *configRecord, value string) (interface{}, error) {
+	switch opt.OptType {
+	case config.OptTypeString:
+		return value, nil
+	case config.OptTypeStringArray:
+		var values []string
+		if strings.HasPrefix(value, "[") {
+			err := json.Unmarshal([]byte(value), &values)
+			if err != nil {
+				return nil, fmt.Errorf("%s invalid list: %s", logPrefix, err)
+			}
+			return values, nil
+		}
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		return values, nil
+	case config.OptTypeInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s invalid integer: %s", logPrefix, err)
+		}
+		return i, nil
+	case config.OptTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s invalid boolean: %s", logPrefix, err)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("%s unsupported option type %d", logPrefix, opt.OptType)
+	}
+}
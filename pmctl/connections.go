@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/process"
+	"github.com/spf13/cobra"
+)
+
+const (
+	networkTreePrefix = "network:tree/"
+)
+
+var (
+	connectionsWatch    bool
+	connectionsInterval time.Duration
+	connectionsActive   bool
+)
+
+func init() {
+	rootCmd.AddCommand(connectionsCmd)
+	connectionsCmd.Flags().BoolVarP(&connectionsWatch, "watch", "w", false, "follow changes of the network tree continuously")
+	connectionsCmd.Flags().DurationVar(&connectionsInterval, "interval", 2*time.Second, "minimum time between screen updates when watching")
+	connectionsCmd.Flags().BoolVar(&connectionsActive, "active", false, "only show connections that are still open")
+}
+
+var connectionsCmd = &cobra.Command{
+	Use:   "connections",
+	Short: "Show the network connections of all processes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := connectDatabaseAPI()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		tree := newConnectionTree()
+		if !connectionsWatch {
+			err = db.Query(networkTreePrefix, func(key string, data []byte) error {
+				return tree.apply(dbMsgTypeOk, key, data)
+			})
+			if err != nil {
+				return err
+			}
+			return tree.print(false)
+		}
+
+		// redraw on changes, but at most once per interval
+		ticker := time.NewTicker(connectionsInterval)
+		defer ticker.Stop()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if tree.isChanged() {
+						_ = tree.print(true)
+					}
+				}
+			}
+		}()
+
+		return db.Subscribe(networkTreePrefix, func(msgType, key string, data []byte) error {
+			if msgType == dbMsgTypeDone {
+				return tree.print(true)
+			}
+			return tree.apply(msgType, key, data)
+		})
+	},
+}
+
+// connectionTree is a client side copy of the network:tree, keyed by the record paths below network:tree/.
+type connectionTree struct {
+	sync.Mutex
+
+	processes map[string]*process.Process
+	comms     map[string]*network.Communication
+	links     map[string]*network.Link
+	changed   bool
+}
+
+func newConnectionTree() *connectionTree {
+	return &connectionTree{
+		processes: make(map[string]*process.Process),
+		comms:     make(map[string]*network.Communication),
+		links:     make(map[string]*network.Link),
+	}
+}
+
+// apply applies a record or a deletion received from the database API to the tree.
+func (t *connectionTree) apply(msgType, key string, data []byte) error {
+	t.Lock()
+	defer t.Unlock()
+
+	path := strings.TrimPrefix(key, networkTreePrefix)
+	t.changed = true
+
+	if msgType == dbMsgTypeDel {
+		delete(t.processes, path)
+		delete(t.comms, path)
+		delete(t.links, path)
+		return nil
+	}
+
+	// network:tree/<pid>/<domain>/<link ID>
+	switch strings.Count(path, "/") {
+	case 0:
+		proc := &process.Process{}
+		t.processes[path] = proc
+		return unmarshalRecord(data, proc)
+	case 1:
+		comm := &network.Communication{}
+		t.comms[path] = comm
+		return unmarshalRecord(data, comm)
+	case 2:
+		link := &network.Link{}
+		t.links[path] = link
+		return unmarshalRecord(data, link)
+	default:
+		return nil
+	}
+}
+
+func (t *connectionTree) isChanged() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.changed
+}
+
+// print writes the tree to stdout, optionally clearing the screen first.
+func (t *connectionTree) print(clearScreen bool) error {
+	t.Lock()
+	defer t.Unlock()
+
+	t.changed = false
+
+	var b strings.Builder
+	if clearScreen {
+		// move cursor home and clear screen
+		b.WriteString("\033[H\033[2J")
+		fmt.Fprintf(&b, "%s connections at %s (Ctrl+C to quit)\n\n", logPrefix, time.Now().Format("15:04:05"))
+	}
+
+	// group links and communications by their parent
+	commLinks := make(map[string][]*network.Link)
+	for path, link := range t.links {
+		if connectionsActive && link.Ended != 0 {
+			continue
+		}
+		parent := path[:strings.LastIndex(path, "/")]
+		commLinks[parent] = append(commLinks[parent], link)
+	}
+	procComms := make(map[string][]string)
+	for path := range t.comms {
+		if connectionsActive && len(commLinks[path]) == 0 {
+			continue
+		}
+		parent := path[:strings.Index(path, "/")]
+		procComms[parent] = append(procComms[parent], path)
+	}
+
+	pids := make([]string, 0, len(procComms))
+	for pid := range procComms {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		a, _ := strconv.Atoi(pids[i])
+		b, _ := strconv.Atoi(pids[j])
+		return a < b
+	})
+
+	for _, pid := range pids {
+		proc, ok := t.processes[pid]
+		if ok {
+			fmt.Fprintf(&b, "%s (%s) %s\n", proc.Name, pid, proc.Path)
+		} else {
+			fmt.Fprintf(&b, "unknown process (%s)\n", pid)
+		}
+
+		commPaths := procComms[pid]
+		sort.Strings(commPaths)
+		for _, commPath := range commPaths {
+			comm := t.comms[commPath]
+			direction := "->"
+			if comm.Direction == network.Inbound {
+				direction = "<-"
+			}
+			fmt.Fprintf(&b, "  %s %s [%s] %s\n", direction, comm.Domain, comm.Verdict, comm.Reason)
+
+			links := commLinks[commPath]
+			sort.Slice(links, func(i, j int) bool {
+				return links[i].Started < links[j].Started
+			})
+			for _, link := range links {
+				state := "open"
+				if link.Ended != 0 {
+					state = "closed"
+				}
+				fmt.Fprintf(&b, "      %s [%s] %s, since %s\n", link.RemoteAddress, link.Verdict, state, time.Unix(link.Started, 0).Format("15:04:05"))
+			}
+		}
+	}
+	if len(pids) == 0 {
+		b.WriteString("no connections\n")
+	}
+
+	_, err := os.Stdout.WriteString(b.String())
+	return err
+}
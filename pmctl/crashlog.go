@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// crashLogLines is the amount of output lines kept for crash logs
+	crashLogLines = 200
+	// maxCrashLogs is the amount of crash logs kept per component
+	maxCrashLogs = 10
+	// maxLineLength limits lines without a line break
+	maxLineLength = 4096
+)
+
+// outputTail keeps the last lines written to it.
+type outputTail struct {
+	sync.Mutex
+	lines   []string
+	max     int
+	partial []byte
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{
+		max: max,
+	}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	data := append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		t.addLine(string(data[:i]))
+		data = data[i+1:]
+	}
+	if len(data) > maxLineLength {
+		t.addLine(string(data))
+		data = nil
+	}
+	t.partial = append([]byte(nil), data...)
+
+	return len(p), nil
+}
+
+// addLine adds a line, cut to maxLineLength, which limits the size of crash logs. The outputTail must be locked.
+func (t *outputTail) addLine(line string) {
+	if len(line) > maxLineLength {
+		line = line[:maxLineLength]
+	}
+	if len(t.lines) >= t.max {
+		t.lines = t.lines[1:]
+	}
+	t.lines = append(t.lines, strings.TrimSuffix(line, "\r"))
+}
+
+// Lines returns the kept lines, including an unfinished last line.
+func (t *outputTail) Lines() []string {
+	t.Lock()
+	defer t.Unlock()
+
+	lines := make([]string, 0, len(t.lines)+1)
+	if len(t.partial) > 0 && len(t.lines) >= t.max {
+		lines = append(lines, t.lines[1:]...)
+	} else {
+		lines = append(lines, t.lines...)
+	}
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+	}
+	return lines
+}
+
+// writeCrashLog saves the last output of a crashed component to the crash log directory and returns the path of the log.
+func writeCrashLog(identifier, version, reason string, ranFor time.Duration, output *outputTail) (string, error) {
+	logDir := filepath.Join(*databaseRootDir, "logs", "crash")
+	err := os.MkdirAll(logDir, 0700)
+	if err != nil {
+		return "", err
+	}
+	err = os.Chmod(logDir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(identifier), ".exe")
+	logPath := filepath.Join(logDir, fmt.Sprintf("%s_%s.log", name, time.Now().Format("2006-01-02_15-04-05")))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "component: %s\n", identifier)
+	fmt.Fprintf(&b, "version:   %s\n", version)
+	fmt.Fprintf(&b, "reason:    %s\n", reason)
+	fmt.Fprintf(&b, "ran for:   %s\n", ranFor.Round(time.Second))
+	fmt.Fprintf(&b, "\nlast %d lines of output:\n", crashLogLines)
+	for _, line := range output.Lines() {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	err = ioutil.WriteFile(logPath, b.Bytes(), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	cleanLogs(logDir, name, maxCrashLogs)
+	return logPath, nil
+}
+
+// cleanLogs removes the oldest logs of a component, keeping the given amount.
+func cleanLogs(logDir, name string, keep int) {
+	logs, err := filepath.Glob(filepath.Join(logDir, name+"_*.log"))
+	if err != nil || len(logs) <= keep {
+		return
+	}
+
+	// names sort by time
+	sort.Strings(logs)
+	for _, logPath := range logs[:len(logs)-keep] {
+		err = os.Remove(logPath)
+		if err != nil {
+			fmt.Printf("%s failed to remove old log %s: %s\n", logPrefix, logPath, err)
+		}
+	}
+}
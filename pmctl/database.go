@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message types of the portbase database API.
+const (
+	dbMsgTypeOk      = "ok"
+	dbMsgTypeError   = "error"
+	dbMsgTypeDone    = "done"
+	dbMsgTypeSuccess = "success"
+	dbMsgTypeUpd     = "upd"
+	dbMsgTypeNew     = "new"
+	dbMsgTypeDel     = "del"
+	dbMsgTypeWarning = "warning"
+
+	// dsdJSON is the format identifier of JSON encoded records.
+	dsdJSON = 'J'
+)
+
+var (
+	// errStopSubscription may be returned by a subscription handler to end the subscription without an error.
+	errStopSubscription = errors.New("subscription stopped")
+)
+
+// dbClient is a client for the database API of the Portmaster. It is not safe for concurrent use.
+type dbClient struct {
+	conn     *websocket.Conn
+	lastOpID int
+}
+
+// dbMessage is a message received from the database API.
+type dbMessage struct {
+	opID    string
+	msgType string
+	key     string
+	data    []byte
+}
+
+// connectDatabaseAPI connects to the database API of the running Portmaster.
+func connectDatabaseAPI() (*dbClient, error) {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+	}
+	conn, _, err := dialer.Dial(fmt.Sprintf("ws://%s/api/database/v1", *apiAddress), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact Portmaster (is it running?): %s", err)
+	}
+	return &dbClient{conn: conn}, nil
+}
+
+// withDatabaseAPI connects to the database API, runs fn and closes the connection again.
+func withDatabaseAPI(fn func(db *dbClient) error) error {
+	db, err := connectDatabaseAPI()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return fn(db)
+}
+
+// Close closes the connection to the database API.
+func (db *dbClient) Close() error {
+	return db.conn.Close()
+}
+
+// send sends a new request and returns its operation ID.
+func (db *dbClient) send(parts ...[]byte) (opID string, err error) {
+	db.lastOpID++
+	opID = strconv.Itoa(db.lastOpID)
+
+	msg := bytes.Join(append([][]byte{[]byte(opID)}, parts...), []byte("|"))
+	err = db.conn.WriteMessage(websocket.BinaryMessage, msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Portmaster: %s", err)
+	}
+	return opID, nil
+}
+
+// receive returns the next message for the given operation. Messages of other operations are discarded.
+func (db *dbClient) receive(opID string) (*dbMessage, error) {
+	for {
+		_, data, err := db.conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("lost connection to Portmaster: %s", err)
+		}
+
+		parts := bytes.SplitN(data, []byte("|"), 4)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("received malformed message from Portmaster: %q", data)
+		}
+		if string(parts[0]) != opID {
+			continue
+		}
+
+		msg := &dbMessage{
+			opID:    opID,
+			msgType: string(parts[1]),
+		}
+		switch msg.msgType {
+		case dbMsgTypeError, dbMsgTypeWarning:
+			// the message may contain the separator
+			msg.data = bytes.Join(parts[2:], []byte("|"))
+		default:
+			if len(parts) > 2 {
+				msg.key = string(parts[2])
+			}
+			if len(parts) > 3 {
+				msg.data = parts[3]
+			}
+		}
+		return msg, nil
+	}
+}
+
+// Get fetches the record with the given key and decodes it into v.
+func (db *dbClient) Get(key string, v interface{}) error {
+	opID, err := db.send([]byte("get"), []byte(key))
+	if err != nil {
+		return err
+	}
+
+	msg, err := db.receive(opID)
+	if err != nil {
+		return err
+	}
+	switch msg.msgType {
+	case dbMsgTypeOk:
+		return unmarshalRecord(msg.data, v)
+	case dbMsgTypeError:
+		return fmt.Errorf("failed to get %s: %s", key, msg.data)
+	default:
+		return fmt.Errorf("unexpected reply to get %s: %s", key, msg.msgType)
+	}
+}
+
+// Query runs the given query and calls fn with the key and data of every matching record.
+func (db *dbClient) Query(query string, fn func(key string, data []byte) error) error {
+	opID, err := db.send([]byte("query"), []byte(query))
+	if err != nil {
+		return err
+	}
+
+	for {
+		msg, err := db.receive(opID)
+		if err != nil {
+			return err
+		}
+		switch msg.msgType {
+		case dbMsgTypeOk:
+			err = fn(msg.key, msg.data)
+			if err != nil {
+				return err
+			}
+		case dbMsgTypeDone:
+			return nil
+		case dbMsgTypeWarning:
+			fmt.Printf("%s warning: %s\n", logPrefix, msg.data)
+		case dbMsgTypeError:
+			return fmt.Errorf("query %s failed: %s", query, msg.data)
+		}
+	}
+}
+
+// Subscribe runs the given query and then subscribes to changes of matching records. fn is called with the message type ("ok" for the initial records, "done" when they were all received, then "upd", "new" or "del"), the key and the data of every record. The subscription ends when fn returns an error, errStopSubscription ends it without one.
+func (db *dbClient) Subscribe(query string, fn func(msgType, key string, data []byte) error) error {
+	opID, err := db.send([]byte("qsub"), []byte(query))
+	if err != nil {
+		return err
+	}
+
+	for {
+		msg, err := db.receive(opID)
+		if err != nil {
+			return err
+		}
+		switch msg.msgType {
+		case dbMsgTypeOk, dbMsgTypeDone, dbMsgTypeUpd, dbMsgTypeNew, dbMsgTypeDel:
+			err = fn(msg.msgType, msg.key, msg.data)
+		case dbMsgTypeWarning:
+			fmt.Printf("%s warning: %s\n", logPrefix, msg.data)
+		case dbMsgTypeError:
+			err = fmt.Errorf("subscription to %s failed: %s", query, msg.data)
+		}
+		if err != nil {
+			_, _ = db.send([]byte("cancel"))
+			if err == errStopSubscription {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Create creates a new record with the given key from v. It fails if the record already exists.
+func (db *dbClient) Create(key string, v interface{}) error {
+	return db.put("create", key, v)
+}
+
+// Update saves v as the record with the given key, replacing an existing record.
+func (db *dbClient) Update(key string, v interface{}) error {
+	return db.put("update", key, v)
+}
+
+func (db *dbClient) put(cmd, key string, v interface{}) error {
+	data, err := marshalRecord(v)
+	if err != nil {
+		return err
+	}
+	opID, err := db.send([]byte(cmd), []byte(key), data)
+	if err != nil {
+		return err
+	}
+	return db.awaitSuccess(opID, cmd, key)
+}
+
+// Delete deletes the record with the given key.
+func (db *dbClient) Delete(key string) error {
+	opID, err := db.send([]byte("delete"), []byte(key))
+	if err != nil {
+		return err
+	}
+	return db.awaitSuccess(opID, "delete", key)
+}
+
+func (db *dbClient) awaitSuccess(opID, cmd, key string) error {
+	msg, err := db.receive(opID)
+	if err != nil {
+		return err
+	}
+	switch msg.msgType {
+	case dbMsgTypeSuccess:
+		return nil
+	case dbMsgTypeError:
+		return fmt.Errorf("failed to %s %s: %s", cmd, key, msg.data)
+	default:
+		return fmt.Errorf("unexpected reply to %s %s: %s", cmd, key, msg.msgType)
+	}
+}
+
+// unmarshalRecord decodes the JSON encoded record data into v. A json.RawMessage receives the plain JSON.
+func unmarshalRecord(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0] != dsdJSON {
+		return errors.New("received record in unsupported format")
+	}
+	return json.Unmarshal(data[1:], v)
+}
+
+// marshalRecord encodes v as a JSON record.
+func marshalRecord(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{dsdJSON}, data...), nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Safing/portbase/info"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// diagnoseCoreLogs is the amount of the latest core log files included
+	diagnoseCoreLogs = 3
+	// maxDiagnoseLogSize limits the size of included log files, keeping their end
+	maxDiagnoseLogSize = 5 * 1024 * 1024
+)
+
+var (
+	diagnoseOutput string
+
+	// redactions remove secrets from all collected data.
+	redactions = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		// credentials in URLs, eg. of proxies
+		{regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/\s:@"]+:[^/\s@"]+@`), "${1}REDACTED@"},
+		// secrets in query strings
+		{regexp.MustCompile(`(?i)([?&](?:token|key|secret|password|auth)[^=&\s"]*=)[^&\s"]+`), "${1}REDACTED"},
+		// secrets in key value pairs, eg. in logs or JSON
+		{regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|authorization)"?\s*[:=]\s*"?)[^\s",&]+`), "${1}REDACTED"},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(diagnoseCmd)
+	diagnoseCmd.Flags().StringVarP(&diagnoseOutput, "output", "o", "", "path of the diagnostics archive (default: portmaster-diagnostics-<time>.tar.gz)")
+}
+
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose",
+	Short: "Collect diagnostic information into an archive",
+	Long:  "Collect the state of the Portmaster and the system into an archive that can be attached to bug reports. This includes the firewall rules, nameserver and resolver state, module status, security level, threats, update versions and recent logs. Secrets, such as passwords in URLs, are redacted. Run as root to include all system information.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diagnoseOutput == "" {
+			diagnoseOutput = fmt.Sprintf("portmaster-diagnostics-%s.tar.gz", time.Now().Format("2006-01-02_15-04-05"))
+		}
+		f, err := os.OpenFile(diagnoseOutput, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("%s failed to create archive: %s", logPrefix, err)
+		}
+		defer f.Close()
+
+		d := newDiagnostics(f)
+		d.collect()
+		err = d.close()
+		if err != nil {
+			return fmt.Errorf("%s failed to write archive: %s", logPrefix, err)
+		}
+
+		if len(d.errors) > 0 {
+			fmt.Printf("%s %d items could not be collected, see errors.txt in the archive\n", logPrefix, len(d.errors))
+		}
+		fmt.Printf("%s saved diagnostics to %s\n", logPrefix, diagnoseOutput)
+		return nil
+	},
+}
+
+// diagnostics collects files into a gzip compressed tar archive. Collection errors are recorded instead of aborting, as diagnostics are needed most when things are broken.
+type diagnostics struct {
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+	created    time.Time
+	errors     []string
+	writeErr   error
+}
+
+func newDiagnostics(f *os.File) *diagnostics {
+	gzipWriter := gzip.NewWriter(f)
+	return &diagnostics{
+		gzipWriter: gzipWriter,
+		tarWriter:  tar.NewWriter(gzipWriter),
+		created:    time.Now(),
+	}
+}
+
+func (d *diagnostics) collect() {
+	// basics
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "created:  %s\n", d.created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "pmctl:    %s\n", info.GetInfo().Version)
+	fmt.Fprintf(&b, "database: %s\n", *databaseRootDir)
+	d.addFile("info.txt", b.Bytes())
+
+	// state of the running core
+	d.addRecord("core/status.json", systemStatusKey)
+	d.addRecord("core/updates.json", updateStatusKey)
+	d.addAPI("core/resolvers.json", "/api/intel/v1/resolvers")
+	d.addAPI("core/interception.json", "/api/firewall/v1/interception")
+	d.addAPI("core/modules.json", "/api/core/v1/modules")
+
+	// local update state
+	d.addLocalFile("updates/blacklist.json", filepath.Join(updateStoragePath, "blacklist.json"))
+	d.addUpdateListing()
+
+	// system
+	collectSystemDiagnostics(d)
+
+	// logs
+	coreLogs, _ := filepath.Glob(filepath.Join(logDir(), logName("core/portmaster")+"_*.log"))
+	// names sort by time
+	sort.Strings(coreLogs)
+	if len(coreLogs) > diagnoseCoreLogs {
+		coreLogs = coreLogs[len(coreLogs)-diagnoseCoreLogs:]
+	}
+	for _, coreLog := range coreLogs {
+		d.addLogFile("logs/"+filepath.Base(coreLog), coreLog)
+	}
+	crashLogs, _ := filepath.Glob(filepath.Join(*databaseRootDir, "logs", "crash", "*.log"))
+	for _, crashLog := range crashLogs {
+		d.addLocalFile("logs/crash/"+filepath.Base(crashLog), crashLog)
+	}
+
+	if len(d.errors) > 0 {
+		d.addFile("errors.txt", []byte(strings.Join(d.errors, "\n")+"\n"))
+	}
+}
+
+func (d *diagnostics) close() error {
+	if d.writeErr != nil {
+		return d.writeErr
+	}
+	err := d.tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return d.gzipWriter.Close()
+}
+
+// addFile adds a file with the given data to the archive. Secrets are redacted.
+func (d *diagnostics) addFile(name string, data []byte) {
+	if d.writeErr != nil {
+		return
+	}
+	data = redact(data)
+
+	err := d.tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: d.created,
+	})
+	if err == nil {
+		_, err = d.tarWriter.Write(data)
+	}
+	if err != nil {
+		d.writeErr = err
+	}
+}
+
+func (d *diagnostics) addError(name string, err error) {
+	d.errors = append(d.errors, fmt.Sprintf("%s: %s", name, err))
+}
+
+// addAPI adds the response of the Portmaster API to the archive.
+func (d *diagnostics) addAPI(name, path string) {
+	var data json.RawMessage
+	err := callAPI("GET", path, &data)
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	d.addJSON(name, data)
+}
+
+// addRecord adds the database record with the given key to the archive.
+func (d *diagnostics) addRecord(name, key string) {
+	var data json.RawMessage
+	err := withDatabaseAPI(func(db *dbClient) error {
+		return db.Get(key, &data)
+	})
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	d.addJSON(name, data)
+}
+
+// addJSON adds the indented JSON data to the archive.
+func (d *diagnostics) addJSON(name string, data []byte) {
+	var b bytes.Buffer
+	err := json.Indent(&b, data, "", "  ")
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	d.addFile(name, b.Bytes())
+}
+
+func (d *diagnostics) addLocalFile(name, filePath string) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.addError(name, err)
+		}
+		return
+	}
+	d.addFile(name, data)
+}
+
+// addLogFile adds the end of the log file to the archive.
+func (d *diagnostics) addLogFile(name, filePath string) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	if info.Size() > maxDiagnoseLogSize {
+		_, err = f.Seek(-maxDiagnoseLogSize, io.SeekEnd)
+		if err != nil {
+			d.addError(name, err)
+			return
+		}
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		d.addError(name, err)
+		return
+	}
+	d.addFile(name, data)
+}
+
+// addCommand adds the output of the command to the archive. If filter is given, only matching lines are kept.
+func (d *diagnostics) addCommand(name string, filter func(line string) bool, command string, args ...string) {
+	output, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		d.addError(name, fmt.Errorf("%s failed: %s: %s", command, err, bytes.TrimSpace(output)))
+		return
+	}
+
+	if filter != nil {
+		var filtered []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if filter(line) {
+				filtered = append(filtered, line)
+			}
+		}
+		output = []byte(strings.Join(filtered, "\n") + "\n")
+	}
+	d.addFile(name, output)
+}
+
+// addUpdateListing adds a listing of all files in the update storage.
+func (d *diagnostics) addUpdateListing() {
+	var b bytes.Buffer
+	err := filepath.Walk(updateStoragePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(updateStoragePath, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s\t%d\t%s\n", filepath.ToSlash(relPath), info.Size(), info.ModTime().Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		d.addError("updates/files.txt", err)
+	}
+	d.addFile("updates/files.txt", b.Bytes())
+}
+
+// redact removes secrets from the data.
+func redact(data []byte) []byte {
+	for _, r := range redactions {
+		data = r.re.ReplaceAll(data, []byte(r.repl))
+	}
+	return data
+}
@@ -0,0 +1,5 @@
+// +build !linux
+
+package main
+
+func collectSystemDiagnostics(d *diagnostics) {}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func collectSystemDiagnostics(d *diagnostics) {
+	// firewall rules of the Portmaster: its chains and the rules hooking them in
+	d.addCommand("system/iptables.txt", isPortmasterRule, "iptables-save")
+	d.addCommand("system/ip6tables.txt", isPortmasterRule, "ip6tables-save")
+	d.addLocalFile("system/nfnetlink_queue.txt", "/proc/net/netfilter/nfnetlink_queue")
+
+	// nameserver
+	d.addFile("system/port53.txt", []byte(findPortOwners(53)))
+	d.addLocalFile("system/resolv.conf", "/etc/resolv.conf")
+
+	// logs of the service
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		d.addCommand("logs/journal.txt", nil, "journalctl", "-u", serviceName, "-n", "2000", "--no-pager")
+	}
+}
+
+func isPortmasterRule(line string) bool {
+	return strings.HasPrefix(line, "*") ||
+		strings.Contains(line, "C17") ||
+		strings.Contains(line, "NFQUEUE") ||
+		hasPortmasterMark(line)
+}
+
+// hasPortmasterMark checks if the rule matches a mark of the Portmaster (1700-1799), which iptables-save prints in hex.
+func hasPortmasterMark(line string) bool {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		field := fields[i]
+		if field != "--mark" {
+			continue
+		}
+		mark, err := strconv.ParseUint(strings.Split(fields[i+1], "/")[0], 0, 32)
+		if err == nil && mark >= 1700 && mark <= 1799 {
+			return true
+		}
+	}
+	return false
+}
+
+// findPortOwners lists the sockets bound to the given port and the processes owning them.
+func findPortOwners(port uint16) string {
+	var b bytes.Buffer
+	inodes := make(map[string]string)
+	for _, protocol := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		data, err := ioutil.ReadFile(filepath.Join("/proc/net", protocol))
+		if err != nil {
+			fmt.Fprintf(&b, "failed to read /proc/net/%s: %s\n", protocol, err)
+			continue
+		}
+
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			ip, localPort, err := parseProcNetAddress(fields[1])
+			if err != nil || localPort != port {
+				continue
+			}
+			// only listening tcp sockets
+			if strings.HasPrefix(protocol, "tcp") && fields[3] != "0A" {
+				continue
+			}
+			inodes[fields[9]] = fmt.Sprintf("%s %s", protocol, net.JoinHostPort(ip.String(), strconv.Itoa(int(localPort))))
+		}
+	}
+	if len(inodes) == 0 {
+		b.WriteString("no sockets found\n")
+		return b.String()
+	}
+
+	// find processes by the inodes of their sockets
+	found := make(map[string]bool)
+	pids, _ := filepath.Glob("/proc/[0-9]*")
+	for _, pidDir := range pids {
+		fds, err := ioutil.ReadDir(filepath.Join(pidDir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(pidDir, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			socket, ok := inodes[inode]
+			if !ok {
+				continue
+			}
+			exe, _ := os.Readlink(filepath.Join(pidDir, "exe"))
+			fmt.Fprintf(&b, "%s: pid %s %s\n", socket, filepath.Base(pidDir), exe)
+			found[inode] = true
+		}
+	}
+	for inode, socket := range inodes {
+		if !found[inode] {
+			fmt.Fprintf(&b, "%s: unknown process (inode %s)\n", socket, inode)
+		}
+	}
+	return b.String()
+}
+
+// parseProcNetAddress parses an address of /proc/net/{tcp,udp}[6], eg. "0100007F:0035".
+func parseProcNetAddress(address string) (net.IP, uint16, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+	ipBytes, err := hex.DecodeString(parts[0])
+	if err != nil || (len(ipBytes) != 4 && len(ipBytes) != 16) {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid address %s", address)
+	}
+
+	// addresses are stored as 32 bit words in host byte order (little endian)
+	ip := make(net.IP, len(ipBytes))
+	for i := 0; i < len(ipBytes); i += 4 {
+		ip[i] = ipBytes[i+3]
+		ip[i+1] = ipBytes[i+2]
+		ip[i+2] = ipBytes[i+1]
+		ip[i+3] = ipBytes[i]
+	}
+	return ip, uint16(port), nil
+}
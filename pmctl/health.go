@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// the core needs some time to start all modules
+	healthCheckGracePeriod = 2 * time.Minute
+	healthCheckInterval    = 1 * time.Minute
+	// failed health checks after which the core is considered wedged
+	maxHealthCheckFailures = 3
+)
+
+// watchHealth checks the health endpoint of the core until stop is closed. It closes wedged when the core fails to respond repeatedly after it responded before.
+func watchHealth(stop <-chan struct{}, wedged chan<- struct{}) {
+	select {
+	case <-stop:
+		return
+	case <-time.After(healthCheckGracePeriod):
+	}
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	var failures int
+	// only count failures once the health endpoint responded, as the API may listen elsewhere or be disabled
+	var reachable bool
+	for {
+		err := callAPI("GET", "/api/core/v1/health", nil)
+		switch {
+		case err == nil:
+			reachable = true
+			failures = 0
+		case !reachable:
+			// not watching yet
+		default:
+			failures++
+			fmt.Printf("%s health check failed (%d/%d): %s\n", logPrefix, failures, maxHealthCheckFailures, err)
+			if failures >= maxHealthCheckFailures {
+				close(wedged)
+				return
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
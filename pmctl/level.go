@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(levelCmd)
+	levelCmd.AddCommand(levelSetCmd)
+}
+
+var levelCmd = &cobra.Command{
+	Use:   "level",
+	Short: "Manage the security level",
+}
+
+var levelSetCmd = &cobra.Command{
+	Use:   "set <auto|dynamic|secure|fortress>",
+	Short: "Set the selected security level",
+	Long:  "Set the selected security level. The level \"auto\" (or \"off\") lets the Portmaster select the level automatically, according to threats and the connected network.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, err := parseSecurityLevel(args[0])
+		if err != nil {
+			return err
+		}
+
+		// the status hook of the core applies the selected level when the status record is saved
+		err = withDatabaseAPI(func(db *dbClient) error {
+			var sysStatus map[string]interface{}
+			err := db.Get(systemStatusKey, &sysStatus)
+			if err != nil {
+				return err
+			}
+			sysStatus["SelectedSecurityLevel"] = level
+			return db.Update(systemStatusKey, sysStatus)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s selected security level set to %s\n", logPrefix, fmtSelectedLevel(level))
+		return nil
+	},
+}
+
+func parseSecurityLevel(name string) (uint8, error) {
+	switch strings.ToLower(name) {
+	case "auto", "off":
+		return status.SecurityLevelOff, nil
+	case "dynamic":
+		return status.SecurityLevelDynamic, nil
+	case "secure":
+		return status.SecurityLevelSecure, nil
+	case "fortress":
+		return status.SecurityLevelFortress, nil
+	default:
+		return 0, fmt.Errorf("%s unknown security level %s, use auto, dynamic, secure or fortress", logPrefix, name)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLogFiles is the amount of log files kept per component
+	maxLogFiles = 10
+	// maxLogFileSize limits the size of a log file, output is continued in a new log file
+	maxLogFileSize = 10 * 1024 * 1024 // 10 MB
+)
+
+// logDir returns the directory the output of components is logged to.
+func logDir() string {
+	return filepath.Join(*databaseRootDir, "logs")
+}
+
+// logName returns the name of the component used in its log file names, eg. "portmaster".
+func logName(identifier string) string {
+	return strings.TrimSuffix(filepath.Base(identifier), ".exe")
+}
+
+// createLogFile creates a new log file for the output of the component and removes the oldest log files of it. Log files may contain sensitive data and are only accessible by the owner.
+func createLogFile(identifier string) (*os.File, error) {
+	dir := logDir()
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	// restrict directories created by earlier versions
+	err = os.Chmod(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	name := logName(identifier)
+	// log files may be rotated more than once per second
+	logPath := filepath.Join(dir, fmt.Sprintf("%s_%s.log", name, time.Now().Format("2006-01-02_15-04-05.000")))
+	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanLogs(dir, name, maxLogFiles)
+	return f, nil
+}
+
+// logWriter writes the output of a component to its log files and continues in a new log file when the current one reached maxLogFileSize. It never fails, so that the output of the component is not interrupted.
+type logWriter struct {
+	sync.Mutex
+	identifier string
+	file       *os.File
+	written    int64
+}
+
+func newLogWriter(identifier string) (*logWriter, error) {
+	f, err := createLogFile(identifier)
+	if err != nil {
+		return nil, err
+	}
+	return &logWriter{
+		identifier: identifier,
+		file:       f,
+	}, nil
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.written > 0 && w.written+int64(len(p)) > maxLogFileSize {
+		w.rotate()
+	}
+	if w.file == nil {
+		return len(p), nil
+	}
+
+	// cut single writes that do not fit into a log file
+	data := p
+	if int64(len(data)) > maxLogFileSize {
+		data = data[:maxLogFileSize]
+	}
+	n, err := w.file.Write(data)
+	w.written += int64(n)
+	if err != nil {
+		fmt.Printf("%s failed to write log file: %s\n", logPrefix, err)
+		w.file.Close()
+		w.file = nil
+	}
+	return len(p), nil
+}
+
+// rotate closes the current log file and continues in a new one. The logWriter must be locked.
+func (w *logWriter) rotate() {
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.written = 0
+
+	var err error
+	w.file, err = createLogFile(w.identifier)
+	if err != nil {
+		fmt.Printf("%s failed to create log file: %s\n", logPrefix, err)
+		w.file = nil
+	}
+}
+
+// Close closes the current log file.
+func (w *logWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/Safing/portmaster/profile"
+	"github.com/Safing/portmaster/status"
+	"github.com/spf13/cobra"
+)
+
+var (
+	staleProfileDays     int
+	profileExportFile    string
+	profileImportReplace bool
+)
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	profilesCmd.AddCommand(profilesListCmd)
+	profilesCmd.AddCommand(profilesArchivedCmd)
+	profilesCmd.AddCommand(profilesStaleCmd)
+	profilesCmd.AddCommand(profilesMergeCmd)
+
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileShowCmd)
+	profileCmd.AddCommand(profileEditCmd)
+	profileCmd.AddCommand(profileExportCmd)
+	profileCmd.AddCommand(profileImportCmd)
+	profileCmd.AddCommand(profileArchiveCmd)
+	profileCmd.AddCommand(profileRestoreCmd)
+	profileCmd.AddCommand(profileDeleteCmd)
+
+	profilesStaleCmd.Flags().IntVar(&staleProfileDays, "days", 0, "consider profiles unused for this amount of days stale (default: as configured)")
+	profilesStaleCmd.Flags().Bool("archive", false, "archive all stale profiles")
+	profilesStaleCmd.Flags().Bool("delete", false, "delete all stale profiles")
+	profileExportCmd.Flags().StringVarP(&profileExportFile, "output", "o", "", "write the profile to this file instead of stdout")
+	profileImportCmd.Flags().BoolVar(&profileImportReplace, "replace", false, "replace an existing profile with the same ID")
+}
+
+type staleProfile struct {
+	ID          string
+	Name        string
+	LinkedPath  string
+	LastUsed    int64
+	PathMissing bool
+	Unused      bool
+}
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List and maintain all application profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listProfiles(profile.UserNamespace)
+	},
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listProfiles(profile.UserNamespace)
+	},
+}
+
+var profilesArchivedCmd = &cobra.Command{
+	Use:   "archived",
+	Short: "List all archived profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listProfiles(profile.ArchiveNamespace)
+	},
+}
+
+func listProfiles(namespace string) error {
+	var profiles []*profile.Profile
+	err := withDatabaseAPI(func(db *dbClient) error {
+		return db.Query(profile.MakeProfileKey(namespace, ""), func(key string, data []byte) error {
+			p := &profile.Profile{}
+			err := unmarshalRecord(data, p)
+			if err != nil {
+				return fmt.Errorf("%s failed to parse profile %s: %s", logPrefix, key, err)
+			}
+			profiles = append(profiles, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPATH\tLEVEL\tLAST USED")
+	for _, p := range profiles {
+		level := "-"
+		if p.SecurityLevel > 0 {
+			level = status.FmtSecurityLevel(p.SecurityLevel)
+		}
+		if p.Quarantined {
+			level = "quarantined"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ID, p.Name, p.LinkedPath, level, fmtDate(p.ApproxLastUsed))
+	}
+	return w.Flush()
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile <profile ID>",
+	Short: "Show and manage a single application profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  showProfile,
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show <profile ID>",
+	Short: "Show a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  showProfile,
+}
+
+func showProfile(cmd *cobra.Command, args []string) error {
+	p := &profile.Profile{}
+	err := withDatabaseAPI(func(db *dbClient) error {
+		return db.Get(profile.MakeProfileKey(profile.UserNamespace, args[0]), p)
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Profile:\t%s (%s)\n", p.Name, p.ID)
+	if p.Description != "" {
+		fmt.Fprintf(w, "Description:\t%s\n", p.Description)
+	}
+	if p.Homepage != "" {
+		fmt.Fprintf(w, "Homepage:\t%s\n", p.Homepage)
+	}
+	fmt.Fprintf(w, "Path:\t%s\n", p.LinkedPath)
+	if p.SecurityLevel > 0 {
+		fmt.Fprintf(w, "Security Level:\t%s\n", status.FmtSecurityLevel(p.SecurityLevel))
+	}
+	if p.Quarantined {
+		fmt.Fprintf(w, "Quarantined:\t%s\n", p.QuarantineReason)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", fmtDate(p.Created))
+	fmt.Fprintf(w, "Last Used:\t%s\n", fmtDate(p.ApproxLastUsed))
+	if len(p.Flags) > 0 {
+		fmt.Fprintf(w, "Flags:\t%s\n", p.Flags)
+	}
+	for i, fp := range p.Fingerprints {
+		label := ""
+		if i == 0 {
+			label = "Fingerprints:"
+		}
+		fmt.Fprintf(w, "%s\t%s %s: %s\n", label, fp.OS, fp.Type, fp.Value)
+	}
+	printEndpoints(w, "Endpoints:", p.Endpoints)
+	printEndpoints(w, "Service Endpoints:", p.ServiceEndpoints)
+	return w.Flush()
+}
+
+func printEndpoints(w *tabwriter.Writer, label string, endpoints profile.Endpoints) {
+	for _, ep := range endpoints {
+		action := "deny"
+		if ep.Permit {
+			action = "permit"
+		}
+		fmt.Fprintf(w, "%s\t%s %s\n", label, action, ep)
+		label = ""
+	}
+}
+
+func fmtDate(timestamp int64) string {
+	if timestamp == 0 {
+		return "never"
+	}
+	return time.Unix(timestamp, 0).Format("2006-01-02")
+}
+
+var profileExportCmd = &cobra.Command{
+	Use:   "export <profile ID>",
+	Short: "Export a profile as JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := getProfileJSON(args[0])
+		if err != nil {
+			return err
+		}
+		if profileExportFile == "" {
+			_, err = os.Stdout.Write(append(data, '\n'))
+			return err
+		}
+		return ioutil.WriteFile(profileExportFile, append(data, '\n'), 0600)
+	},
+}
+
+var profileImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a profile from a JSON file, use - for stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		p := profile.New()
+		err = json.Unmarshal(data, p)
+		if err != nil {
+			return fmt.Errorf("%s failed to parse profile: %s", logPrefix, err)
+		}
+		if p.ID == "" {
+			u, err := uuid.NewV4()
+			if err != nil {
+				return err
+			}
+			p.ID = u.String()
+		}
+
+		err = withDatabaseAPI(func(db *dbClient) error {
+			key := profile.MakeProfileKey(profile.UserNamespace, p.ID)
+			if profileImportReplace {
+				return db.Update(key, p)
+			}
+			return db.Create(key, p)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s imported profile %s (%s)\n", logPrefix, p.ID, p.Name)
+		return nil
+	},
+}
+
+var profileEditCmd = &cobra.Command{
+	Use:   "edit <profile ID>",
+	Short: "Edit a profile with $EDITOR",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := getProfileJSON(args[0])
+		if err != nil {
+			return err
+		}
+
+		tmpFile, err := ioutil.TempFile("", "pmctl-profile-*.json")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmpFile.Name())
+		_, err = tmpFile.Write(append(data, '\n'))
+		if err != nil {
+			return err
+		}
+		err = tmpFile.Close()
+		if err != nil {
+			return err
+		}
+
+		editor := os.Getenv("EDITOR")
+		if editor == "" {
+			editor = "vi"
+		}
+		editorCmd := exec.Command(editor, tmpFile.Name())
+		editorCmd.Stdin = os.Stdin
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		err = editorCmd.Run()
+		if err != nil {
+			return fmt.Errorf("%s editor failed: %s", logPrefix, err)
+		}
+
+		edited, err := ioutil.ReadFile(tmpFile.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(bytes.TrimSpace(edited), bytes.TrimSpace(data)) {
+			fmt.Printf("%s no changes\n", logPrefix)
+			return nil
+		}
+
+		p := &profile.Profile{}
+		err = json.Unmarshal(edited, p)
+		if err != nil {
+			return fmt.Errorf("%s failed to parse profile: %s", logPrefix, err)
+		}
+		if p.ID != args[0] {
+			return fmt.Errorf("%s profile ID must not be changed", logPrefix)
+		}
+
+		err = withDatabaseAPI(func(db *dbClient) error {
+			return db.Update(profile.MakeProfileKey(profile.UserNamespace, p.ID), p)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s saved profile %s\n", logPrefix, args[0])
+		return nil
+	},
+}
+
+// getProfileJSON returns the indented JSON of the user profile with the given ID.
+func getProfileJSON(id string) ([]byte, error) {
+	var data json.RawMessage
+	err := withDatabaseAPI(func(db *dbClient) error {
+		return db.Get(profile.MakeProfileKey(profile.UserNamespace, id), &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = json.Indent(&buf, data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var profilesStaleCmd = &cobra.Command{
+	Use:   "stale",
+	Short: "List, archive or delete profiles of missing or unused applications",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, _ := cmd.Flags().GetBool("archive")
+		deleteStale, _ := cmd.Flags().GetBool("delete")
+
+		if staleProfileDays < 0 {
+			return fmt.Errorf("%s --days must be greater than 0", logPrefix)
+		}
+
+		query := url.Values{}
+		if staleProfileDays > 0 {
+			query.Set("days", fmt.Sprintf("%d", staleProfileDays))
+		}
+
+		method := "GET"
+		path := "/api/profiles/v1/stale"
+		switch {
+		case archive && deleteStale:
+			return fmt.Errorf("%s please use either --archive or --delete", logPrefix)
+		case archive:
+			method = "POST"
+			path += "/clean"
+			query.Set("action", "archive")
+		case deleteStale:
+			method = "POST"
+			path += "/clean"
+			query.Set("action", "delete")
+		}
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+
+		var staleProfiles []*staleProfile
+		err := callAPI(method, path, &staleProfiles)
+		if err != nil {
+			return err
+		}
+
+		if len(staleProfiles) == 0 {
+			fmt.Printf("%s no stale profiles found\n", logPrefix)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPATH\tLAST USED\tREASON")
+		for _, p := range staleProfiles {
+			reason := "unused"
+			if p.PathMissing {
+				reason = "missing"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ID, p.Name, p.LinkedPath, fmtDate(p.LastUsed), reason)
+		}
+		w.Flush()
+
+		switch {
+		case archive:
+			fmt.Printf("%s archived %d profiles\n", logPrefix, len(staleProfiles))
+		case deleteStale:
+			fmt.Printf("%s deleted %d profiles\n", logPrefix, len(staleProfiles))
+		}
+		return nil
+	},
+}
+
+var profileArchiveCmd = &cobra.Command{
+	Use:   "archive <profile ID>",
+	Short: "Archive a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := callAPI("POST", fmt.Sprintf("/api/profiles/v1/user/%s/archive", url.PathEscape(args[0])), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s archived profile %s\n", logPrefix, args[0])
+		return nil
+	},
+}
+
+var profileRestoreCmd = &cobra.Command{
+	Use:   "restore <profile ID>",
+	Short: "Restore an archived profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := callAPI("POST", fmt.Sprintf("/api/profiles/v1/archive/%s/restore", url.PathEscape(args[0])), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s restored profile %s\n", logPrefix, args[0])
+		return nil
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete <profile ID>",
+	Short: "Delete a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := callAPI("DELETE", fmt.Sprintf("/api/profiles/v1/user/%s", url.PathEscape(args[0])), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s deleted profile %s\n", logPrefix, args[0])
+		return nil
+	},
+}
+
+var profilesMergeCmd = &cobra.Command{
+	Use:   "merge <target profile ID> <source profile ID>",
+	Short: "Merge the source profile into the target profile and archive the source",
+	Long:  "Merge the endpoints, fingerprints and flags of the source profile into the target profile, eg. after an application moved to a new location. Settings of the target profile take precedence. The source profile is archived afterwards.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := url.Values{}
+		query.Set("target", args[0])
+		query.Set("source", args[1])
+
+		err := callAPI("POST", "/api/profiles/v1/merge?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s merged profile %s into %s\n", logPrefix, args[1], args[0])
+		return nil
+	},
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptRulesFile     string
+	promptDefaultAction string
+
+	// answeredPrompts holds prompts that were answered, but may not yet be processed by the Portmaster.
+	answeredPrompts = make(map[string]bool)
+)
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+	promptCmd.Flags().StringVar(&promptRulesFile, "rules", "", "answer prompts automatically according to the rules in this file")
+	promptCmd.Flags().StringVar(&promptDefaultAction, "default", "", "answer all prompts not matched by a rule with this action (eg. deny), instead of asking")
+}
+
+const (
+	// promptNotificationsQuery matches the notifications of firewall prompts.
+	promptNotificationsQuery = "notifications:all/firewall-prompt-"
+)
+
+type prompt struct {
+	ID      string
+	Message string
+	Actions []*struct {
+		ID   string
+		Text string
+	}
+	Pid         int
+	ProcessPath string
+	Domain      string
+	RemoteIP    string
+	Inbound     bool
+	Expires     int64
+}
+
+// promptRule answers prompts of matching processes and destinations with an action.
+type promptRule struct {
+	action      string
+	processPath *regexp.Regexp
+	destination *regexp.Regexp
+}
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Answer firewall prompts in the terminal",
+	Long: `Answer firewall prompts in the terminal, for systems without the Portmaster App.
+
+Pending prompts are listed with numbered actions. Answer a prompt with "<prompt> <action>", eg. "1 2", or answer all pending prompts with "all <action>", eg. "all deny". If only one prompt is pending, the prompt number may be omitted.
+
+Actions may be given by number, by ID or as "permit", which selects the most specific permit action of a prompt.
+
+Rules for automatic answers are given one per line as "<action> <process path> <domain or IP>", where the process path and destination may contain wildcards: * matches any text, including / and ., and ? matches a single character. Lines starting with # are ignored. For example:
+
+  permit /usr/bin/apt-get *.debian.org
+  deny   *                *.doubleclick.net`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var rules []*promptRule
+		if promptRulesFile != "" {
+			var err error
+			rules, err = loadPromptRules(promptRulesFile)
+			if err != nil {
+				return err
+			}
+		}
+		return runPromptAgent(rules)
+	},
+}
+
+func runPromptAgent(rules []*promptRule) error {
+	// read answers in the background to not miss prompts
+	var lines chan string
+	if promptDefaultAction == "" {
+		lines = make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+	}
+
+	// get notified when prompts are added, answered or expire
+	db, err := connectDatabaseAPI()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	changes := make(chan struct{}, 1)
+	subErr := make(chan error, 1)
+	go func() {
+		subErr <- db.Subscribe(promptNotificationsQuery, func(msgType, key string, data []byte) error {
+			if msgType == dbMsgTypeOk {
+				// refresh once all existing prompts were received
+				return nil
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+	}()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	fmt.Printf("%s waiting for prompts (Ctrl+C to quit)\n", logPrefix)
+	var pending []*prompt
+	var shown string
+	for {
+		// refresh pending prompts and answer them automatically, if possible
+		var current []*prompt
+		err := callAPI("GET", "/api/firewall/v1/prompts", &current)
+		if err != nil {
+			fmt.Printf("%s %s\n", logPrefix, err)
+		} else {
+			forgetAnsweredPrompts(current)
+			pending = nil
+			for _, p := range current {
+				if answeredPrompts[p.ID] {
+					continue
+				}
+				if !autoAnswerPrompt(p, rules) {
+					pending = append(pending, p)
+				}
+			}
+		}
+
+		// show prompts that require an answer, when they change
+		if ids := promptIDs(pending); ids != shown {
+			shown = ids
+			if len(pending) > 0 {
+				printPrompts(pending)
+			}
+		}
+
+		select {
+		case <-signalCh:
+			return nil
+		case err := <-subErr:
+			return err
+		case <-changes:
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Printf("%s input closed, stopping\n", logPrefix)
+				return nil
+			}
+			err := answerPrompts(pending, line)
+			if err != nil {
+				fmt.Printf("%s %s\n", logPrefix, err)
+			}
+			// show remaining prompts again
+			shown = ""
+		}
+	}
+}
+
+// autoAnswerPrompt answers the prompt according to the rules or the default action and reports whether it was answered.
+func autoAnswerPrompt(p *prompt, rules []*promptRule) bool {
+	for _, rule := range rules {
+		if !rule.matches(p) {
+			continue
+		}
+		actionID, ok := p.resolveAction(rule.action)
+		if !ok {
+			continue
+		}
+		return respondToPrompt(p, actionID, "rule")
+	}
+
+	if promptDefaultAction != "" {
+		actionID, ok := p.resolveAction(promptDefaultAction)
+		if ok {
+			return respondToPrompt(p, actionID, "default")
+		}
+	}
+	return false
+}
+
+func respondToPrompt(p *prompt, actionID, source string) bool {
+	err := callAPI("POST", fmt.Sprintf("/api/firewall/v1/prompts/%s/respond?action=%s", url.PathEscape(p.ID), url.QueryEscape(actionID)), nil)
+	if err != nil {
+		fmt.Printf("%s failed to answer prompt %s: %s\n", logPrefix, p.ID, err)
+		return false
+	}
+	answeredPrompts[p.ID] = true
+	fmt.Printf("%s %s: %s (%s)\n", logPrefix, p.Message, actionID, source)
+	return true
+}
+
+func printPrompts(pending []*prompt) {
+	fmt.Printf("\n%s %d pending prompts:\n", logPrefix, len(pending))
+	for i, p := range pending {
+		fmt.Printf("  %d) %s (expires in %ds)\n", i+1, p.Message, p.Expires-time.Now().Unix())
+		options := make([]string, 0, len(p.Actions))
+		for j, action := range p.Actions {
+			options = append(options, fmt.Sprintf("[%d] %s", j+1, action.Text))
+		}
+		fmt.Printf("     %s\n", strings.Join(options, "  "))
+	}
+	if len(pending) == 1 {
+		fmt.Print("answer with <action> or all <action>: ")
+	} else {
+		fmt.Print("answer with <prompt> <action> or all <action>: ")
+	}
+}
+
+// answerPrompts answers pending prompts according to the given input line.
+func answerPrompts(pending []*prompt, line string) error {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return nil
+	case len(pending) == 0:
+		return fmt.Errorf("no pending prompts")
+	case len(fields) == 1 && len(pending) == 1:
+		fields = []string{"1", fields[0]}
+	case len(fields) != 2:
+		return fmt.Errorf("invalid answer %q", line)
+	}
+
+	if fields[0] == "all" {
+		var answered int
+		for _, p := range pending {
+			actionID, ok := p.resolveAction(fields[1])
+			if ok && respondToPrompt(p, actionID, "user") {
+				answered++
+			}
+		}
+		if answered == 0 {
+			return fmt.Errorf("action %s is not available for any pending prompt", fields[1])
+		}
+		return nil
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 1 || n > len(pending) {
+		return fmt.Errorf("invalid prompt number %s", fields[0])
+	}
+	p := pending[n-1]
+	actionID, ok := p.resolveAction(fields[1])
+	if !ok {
+		return fmt.Errorf("action %s is not available for prompt %d", fields[1], n)
+	}
+	respondToPrompt(p, actionID, "user")
+	return nil
+}
+
+// resolveAction returns the ID of the given action, which is an action number, an action ID or "permit".
+func (p *prompt) resolveAction(action string) (actionID string, ok bool) {
+	if n, err := strconv.Atoi(action); err == nil {
+		if n >= 1 && n <= len(p.Actions) {
+			return p.Actions[n-1].ID, true
+		}
+		return "", false
+	}
+
+	for _, a := range p.Actions {
+		switch {
+		case a.ID == action:
+			return a.ID, true
+		case action == "permit" && strings.HasPrefix(a.ID, "permit"):
+			// permit actions are ordered from the broadest to the most specific
+			actionID = a.ID
+		}
+	}
+	return actionID, actionID != ""
+}
+
+// forgetAnsweredPrompts removes answered prompts that are not pending anymore.
+func forgetAnsweredPrompts(current []*prompt) {
+	stillPending := make(map[string]bool)
+	for _, p := range current {
+		stillPending[p.ID] = true
+	}
+	for id := range answeredPrompts {
+		if !stillPending[id] {
+			delete(answeredPrompts, id)
+		}
+	}
+}
+
+func promptIDs(prompts []*prompt) string {
+	ids := make([]string, 0, len(prompts))
+	for _, p := range prompts {
+		ids = append(ids, p.ID)
+	}
+	return strings.Join(ids, "\n")
+}
+
+func loadPromptRules(filePath string) ([]*promptRule, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*promptRule
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected <action> <process path> <domain or IP>", filePath, i+1)
+		}
+		rules = append(rules, &promptRule{
+			action:      fields[0],
+			processPath: compilePattern(fields[1]),
+			destination: compilePattern(fields[2]),
+		})
+	}
+	return rules, nil
+}
+
+func (rule *promptRule) matches(p *prompt) bool {
+	if !rule.processPath.MatchString(p.ProcessPath) {
+		return false
+	}
+	return rule.destination.MatchString(strings.TrimSuffix(p.Domain, ".")) ||
+		(p.RemoteIP != "" && rule.destination.MatchString(p.RemoteIP))
+}
+
+// compilePattern compiles a wildcard pattern, where * matches any text and ? matches a single character. Unlike path.Match, * also matches across /, so that "/usr/*" matches all executables below /usr.
+func compilePattern(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return regexp.MustCompile("^" + expr + "$")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+const (
+	// exits within this time after the start are considered a crash
+	crashWindow = 1 * time.Minute
+	// crashes after which a component is rolled back
+	maxCrashes = 3
+)
+
+var (
+	// crashCounts holds the amount of consecutive crashes by file path
+	crashCounts = make(map[string]int)
+)
+
+// markHealthyAfterStart records the version of the component as healthy, once it ran longer than the crash window. The returned timer must be stopped when the component exits.
+func markHealthyAfterStart(identifier string, file *updates.File) *time.Timer {
+	return time.AfterFunc(crashWindow, func() {
+		err := updates.MarkVersionHealthy(identifier, file.Version())
+		if err != nil {
+			fmt.Printf("%s failed to record %s v%s as healthy: %s\n", logPrefix, identifier, file.Version(), err)
+		}
+	})
+}
+
+// checkForRollback checks if the component crashed right after starting. If it does so repeatedly, the version changed since the last healthy run and a previous version is available, the crashing version is blacklisted in order to fall back to the previous version. It returns whether the component was rolled back.
+func checkForRollback(identifier string, file *updates.File, exitCode int, ranFor time.Duration) (rolledBack bool) {
+	if exitCode == 0 || exitCode == restartExitCode || ranFor > crashWindow {
+		delete(crashCounts, file.Path())
+		return false
+	}
+
+	// only roll back new versions: if a version that ran fine before crashes, the cause lies elsewhere and older versions would crash too
+	lastHealthy, err := updates.LastHealthyVersion(identifier)
+	if err != nil {
+		fmt.Printf("%s failed to get last healthy version of %s: %s\n", logPrefix, identifier, err)
+		return false
+	}
+	if lastHealthy == "" || updates.CompareVersions(file.Version(), lastHealthy) <= 0 {
+		return false
+	}
+
+	// only handle crashes, if there is something to roll back to
+	fallback, ok := file.Fallback()
+	if !ok {
+		return false
+	}
+
+	// crashes are counted by versioned path, so only crashes of the new version count
+	crashCounts[file.Path()]++
+	crashes := crashCounts[file.Path()]
+	if crashes < maxCrashes {
+		fmt.Printf("%s %s v%s crashed after %s (%d/%d)\n", logPrefix, identifier, file.Version(), ranFor.Round(time.Second), crashes, maxCrashes)
+		return false
+	}
+
+	delete(crashCounts, file.Path())
+	err = file.Blacklist(fmt.Sprintf("crashed %d times within %s after starting (exit code %d)", crashes, crashWindow, exitCode))
+	if err != nil {
+		fmt.Printf("%s failed to roll back %s v%s: %s\n", logPrefix, identifier, file.Version(), err)
+		return false
+	}
+	fmt.Printf("%s %s v%s crashed %d times, rolling back to v%s (use \"pmctl update unblacklist %s %s\" to undo)\n", logPrefix, identifier, file.Version(), crashes, fallback, file.Identifier(), file.Version())
+	return true
+}
@@ -5,12 +5,31 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+const (
+	restartExitCode = 2357427 // Leet Speak for "restart"
+	// components exit with errorExitCode on errors that restarting does not fix, eg. invalid configuration
+	errorExitCode = 1
+	// pmctl exits with fatalExitCode if a component failed with such an error, so that it is not restarted by the service manager either
+	fatalExitCode = 78 // EX_CONFIG
+
+	// delays between restarts of crashing components
+	minRestartDelay = 1 * time.Second
+	maxRestartDelay = 5 * time.Minute
+	// components that ran for this long are restarted without delay
+	stableRunTime = 10 * time.Minute
+	// time components have to exit after being asked to
+	stopTimeout = 30 * time.Second
+)
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.AddCommand(runCore)
@@ -98,7 +117,13 @@ func run(identifier string, cmd *cobra.Command, filterDatabaseFlag bool) error {
 		identifier += ".exe"
 	}
 
+	// forward signals to the component, instead of leaving it behind
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signalCh)
+
 	// run
+	restartDelay := minRestartDelay
 	for {
 		file, err := getFile(identifier)
 		if err != nil {
@@ -120,69 +145,170 @@ func run(identifier string, cmd *cobra.Command, filterDatabaseFlag bool) error {
 		}
 
 		fmt.Printf("%s starting %s %s\n", logPrefix, file.Path(), strings.Join(args, " "))
-		// os.Exit(0)
+		healthyTimer := markHealthyAfterStart(identifier, file)
+		result, err := runComponent(identifier, file.Path(), args, signalCh)
+		healthyTimer.Stop()
+		if err != nil {
+			return err
+		}
 
-		// create command
-		exc := exec.Command(file.Path(), args...)
+		switch {
+		case result.stopped:
+			fmt.Printf("%s %s stopped\n", logPrefix, identifier)
+			return nil
+		case result.exitCode == 0 && !result.wedged:
+			fmt.Printf("%s %s completed successfully\n", logPrefix, identifier)
+			return nil
+		case result.exitCode == restartExitCode:
+			fmt.Printf("%s restarting %s\n", logPrefix, identifier)
+			restartDelay = minRestartDelay
+			continue
+		}
 
-		// consume stdout/stderr
-		stdout, err := exc.StdoutPipe()
-		if err != nil {
-			return fmt.Errorf("%s failed to connect stdout: %s", logPrefix, err)
+		// handle crash
+		var reason string
+		switch {
+		case result.wedged:
+			reason = "stopped responding to health checks"
+		case result.exitCode < 0:
+			reason = "was terminated by a signal"
+		default:
+			reason = fmt.Sprintf("exited with code %d", result.exitCode)
 		}
-		stderr, err := exc.StderrPipe()
+		fmt.Printf("%s %s %s after %s\n", logPrefix, identifier, reason, result.ranFor.Round(time.Second))
+		logPath, err := writeCrashLog(identifier, file.Version(), reason, result.ranFor, result.output)
 		if err != nil {
-			return fmt.Errorf("%s failed to connect stderr: %s", logPrefix, err)
+			fmt.Printf("%s failed to write crash log: %s\n", logPrefix, err)
+		} else {
+			fmt.Printf("%s saved crash log to %s\n", logPrefix, logPath)
 		}
 
-		// start
-		err = exc.Start()
-		if err != nil {
-			return fmt.Errorf("%s failed to start %s: %s", logPrefix, identifier, err)
+		if result.exitCode == errorExitCode && !result.wedged {
+			fmt.Printf("%s %s failed with an error that restarting does not fix, not restarting\n", logPrefix, identifier)
+			os.Exit(fatalExitCode)
 		}
 
-		// start output writers
-		go func() {
-			io.Copy(os.Stdout, stdout)
-		}()
-		go func() {
-			io.Copy(os.Stderr, stderr)
-		}()
+		if checkForRollback(identifier, file, result.exitCode, result.ranFor) {
+			// start the previous version right away
+			restartDelay = minRestartDelay
+			continue
+		}
 
-		// wait for completion
-		err = exc.Wait()
-		if err != nil {
-			exErr, ok := err.(*exec.ExitError)
-			if ok {
-				switch exErr.ProcessState.ExitCode() {
-				case 0:
-					// clean exit
-					fmt.Printf("%s clean exit of %s, but with error: %s\n", logPrefix, identifier, err)
-					os.Exit(1)
-				case 1:
-					// error exit
-					fmt.Printf("%s error during execution of %s: %s\n", logPrefix, identifier, err)
-					os.Exit(1)
-				case 2357427: // Leet Speak for "restart"
-					// restart request
-					fmt.Printf("%s restarting %s\n", logPrefix, identifier)
-					continue
-				default:
-					fmt.Printf("%s unexpected error during execution of %s: %s\n", logPrefix, identifier, err)
-					os.Exit(exErr.ProcessState.ExitCode())
+		// back off exponentially, unless the component ran stable for a while
+		if result.ranFor > stableRunTime {
+			restartDelay = minRestartDelay
+		}
+		fmt.Printf("%s restarting %s in %s\n", logPrefix, identifier, restartDelay)
+		select {
+		case <-signalCh:
+			fmt.Printf("%s not restarting %s, stopped\n", logPrefix, identifier)
+			return nil
+		case <-time.After(restartDelay):
+		}
+		restartDelay *= 2
+		if restartDelay > maxRestartDelay {
+			restartDelay = maxRestartDelay
+		}
+	}
+}
+
+type runResult struct {
+	exitCode int
+	ranFor   time.Duration
+	// stopped is set when the component was stopped by a signal to pmctl
+	stopped bool
+	// wedged is set when the component was stopped because it failed health checks
+	wedged bool
+	output *outputTail
+}
+
+// runComponent runs the component until it exits. Signals are forwarded to the component and the core is restarted if it stops responding.
+func runComponent(identifier, filePath string, args []string, signalCh <-chan os.Signal) (*runResult, error) {
+	result := &runResult{
+		output: newOutputTail(crashLogLines),
+	}
+
+	// keep the output in a log file, too
+	stdout := io.MultiWriter(os.Stdout, result.output)
+	stderr := io.MultiWriter(os.Stderr, result.output)
+	logFile, err := newLogWriter(identifier)
+	if err != nil {
+		fmt.Printf("%s failed to create log file: %s\n", logPrefix, err)
+	} else {
+		defer logFile.Close()
+		stdout = io.MultiWriter(stdout, logFile)
+		stderr = io.MultiWriter(stderr, logFile)
+	}
+
+	// create command
+	exc := exec.Command(filePath, args...)
+	exc.Stdout = stdout
+	exc.Stderr = stderr
+
+	// start
+	err = exc.Start()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to start %s: %s", logPrefix, identifier, err)
+	}
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- exc.Wait()
+	}()
+
+	// watch the core
+	stopHealthCheck := make(chan struct{})
+	defer close(stopHealthCheck)
+	wedged := make(chan struct{})
+	if strings.HasPrefix(identifier, "core/") {
+		go watchHealth(stopHealthCheck, wedged)
+	}
+
+	// wait for completion
+	var killTimer *time.Timer
+	for {
+		select {
+		case err = <-done:
+			if killTimer != nil {
+				killTimer.Stop()
+			}
+			result.ranFor = time.Since(started)
+			if err != nil {
+				exErr, ok := err.(*exec.ExitError)
+				if !ok {
+					return nil, fmt.Errorf("%s unexpected error type during execution of %s: %s", logPrefix, identifier, err)
 				}
-			} else {
-				fmt.Printf("%s unexpected error type during execution of %s: %s\n", logPrefix, identifier, err)
-				os.Exit(1)
+				result.exitCode = exErr.ProcessState.ExitCode()
 			}
+			return result, nil
+		case sig := <-signalCh:
+			if result.stopped {
+				continue
+			}
+			fmt.Printf("%s received %s, stopping %s\n", logPrefix, sig, identifier)
+			result.stopped = true
+			killTimer = stopProcess(exc.Process, sig)
+		case <-wedged:
+			fmt.Printf("%s %s is not responding, stopping\n", logPrefix, identifier)
+			result.wedged = true
+			killTimer = stopProcess(exc.Process, syscall.SIGTERM)
+			wedged = nil
 		}
-
-		// clean exit
-		break
 	}
+}
 
-	fmt.Printf("%s %s completed successfully\n", logPrefix, identifier)
-	return nil
+// stopProcess asks the process to exit and kills it, if it does not exit in time. The returned timer must be stopped when the process exits.
+func stopProcess(proc *os.Process, sig os.Signal) *time.Timer {
+	err := proc.Signal(sig)
+	if err != nil {
+		// signals are not supported on windows
+		_ = proc.Kill()
+		return nil
+	}
+	return time.AfterFunc(stopTimeout, func() {
+		fmt.Printf("%s process did not exit within %s, killing\n", logPrefix, stopTimeout)
+		_ = proc.Kill()
+	})
 }
 
 func windows() bool {
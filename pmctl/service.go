@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	serviceName = "portmaster"
+)
+
+var (
+	serviceUnitPath string
+	serviceStartNow bool
+	servicePrint    bool
+)
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+
+	serviceCmd.PersistentFlags().StringVar(&serviceUnitPath, "unit-path", "/etc/systemd/system/"+serviceName+".service", "path of the systemd unit file")
+	serviceInstallCmd.Flags().BoolVar(&serviceStartNow, "now", false, "start the service right away")
+	serviceInstallCmd.Flags().BoolVar(&servicePrint, "print", false, "only print the unit file")
+}
+
+// serviceUnit runs the core via pmctl, which supervises it. systemd only steps in if pmctl itself fails.
+var serviceUnit = template.Must(template.New("unit").Parse(`[Unit]
+Description=Portmaster Privacy App
+Documentation=https://safing.io
+Wants=nss-lookup.target
+Before=nss-lookup.target
+After=network-pre.target
+
+[Service]
+Type=simple
+ExecStart="{{.Pmctl}}" run core --db "{{.DatabaseDir}}"
+Restart=on-failure
+RestartSec=10
+# the core failed with an error that restarting does not fix, eg. invalid configuration
+RestartPreventExitStatus={{.FatalExitCode}}
+# pmctl forwards SIGTERM to the core and kills it after a timeout
+KillMode=mixed
+KillSignal=SIGTERM
+TimeoutStopSec=45
+# only what is needed for intercepting packets, serving DNS and attributing connections to processes
+CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_BIND_SERVICE CAP_NET_RAW CAP_SYS_PTRACE CAP_DAC_READ_SEARCH CAP_DAC_OVERRIDE CAP_CHOWN CAP_FOWNER
+NoNewPrivileges=true
+PrivateTmp=true
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage the Portmaster system service",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the Portmaster Core as a systemd service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unit, err := renderServiceUnit()
+		if err != nil {
+			return err
+		}
+		if servicePrint {
+			_, err = os.Stdout.Write(unit)
+			return err
+		}
+
+		err = checkSystemd()
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(serviceUnitPath, unit, 0644)
+		if err != nil {
+			return fmt.Errorf("%s failed to write unit file: %s", logPrefix, err)
+		}
+		fmt.Printf("%s installed %s\n", logPrefix, serviceUnitPath)
+
+		err = systemctl("daemon-reload")
+		if err != nil {
+			return err
+		}
+		if serviceStartNow {
+			err = systemctl("enable", "--now", serviceName)
+		} else {
+			err = systemctl("enable", serviceName)
+		}
+		if err != nil {
+			return err
+		}
+
+		if serviceStartNow {
+			fmt.Printf("%s enabled and started %s service\n", logPrefix, serviceName)
+		} else {
+			fmt.Printf("%s enabled %s service, start it with: systemctl start %s\n", logPrefix, serviceName, serviceName)
+		}
+		return nil
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the Portmaster systemd service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := checkSystemd()
+		if err != nil {
+			return err
+		}
+
+		err = systemctl("disable", "--now", serviceName)
+		if err != nil {
+			fmt.Printf("%s warning: %s\n", logPrefix, err)
+		}
+		err = os.Remove(serviceUnitPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%s failed to remove unit file: %s", logPrefix, err)
+		}
+		err = systemctl("daemon-reload")
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s removed %s service, the database at %s was kept\n", logPrefix, serviceName, *databaseRootDir)
+		return nil
+	},
+}
+
+func renderServiceUnit() ([]byte, error) {
+	pmctlPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to get path of pmctl: %s", logPrefix, err)
+	}
+	pmctlPath, err = filepath.EvalSymlinks(pmctlPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to get path of pmctl: %s", logPrefix, err)
+	}
+	databaseDir, err := filepath.Abs(*databaseRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to get path of database: %s", logPrefix, err)
+	}
+
+	var b bytes.Buffer
+	err = serviceUnit.Execute(&b, map[string]string{
+		"Pmctl":         pmctlPath,
+		"DatabaseDir":   databaseDir,
+		"FatalExitCode": strconv.Itoa(fatalExitCode),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func checkSystemd() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%s services are only supported with systemd on linux", logPrefix)
+	}
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return fmt.Errorf("%s systemd is not running on this system", logPrefix)
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s systemctl %s failed: %s: %s", logPrefix, args[0], err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/updates"
+	"github.com/spf13/cobra"
+)
+
+const (
+	systemStatusKey = "core:status/status"
+	updateStatusKey = "core:status/updates"
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the security level, threats and module status of the running Portmaster",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sysStatus := &status.SystemStatus{}
+		upStatus := &updates.VersionStatus{}
+		err := withDatabaseAPI(func(db *dbClient) error {
+			err := db.Get(systemStatusKey, sysStatus)
+			if err != nil {
+				return err
+			}
+			return db.Get(updateStatusKey, upStatus)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Security Level:   %s (selected: %s)\n", status.FmtSecurityLevel(sysStatus.ActiveSecurityLevel), fmtSelectedLevel(sysStatus.SelectedSecurityLevel))
+		if sysStatus.ThreatMitigationLevel > 0 {
+			fmt.Printf("Threat Level:     %s\n", status.FmtSecurityLevel(sysStatus.ThreatMitigationLevel))
+		}
+		if sysStatus.NetworkSecurityLevel > 0 {
+			fmt.Printf("Network Level:    %s\n", status.FmtSecurityLevel(sysStatus.NetworkSecurityLevel))
+		}
+		if sysStatus.PortmasterStatusMsg != "" {
+			fmt.Printf("Status:           %s\n", sysStatus.PortmasterStatusMsg)
+		}
+		if sysStatus.UpdateStatus != "" {
+			fmt.Printf("Updates:          %s\n", sysStatus.UpdateStatus)
+		}
+
+		// threats
+		fmt.Println()
+		if len(sysStatus.Threats) == 0 {
+			fmt.Println("No active threats.")
+		} else {
+			threats := make([]*status.Threat, 0, len(sysStatus.Threats))
+			for _, t := range sysStatus.Threats {
+				threats = append(threats, t)
+			}
+			sort.Slice(threats, func(i, j int) bool {
+				return threats[i].Started < threats[j].Started
+			})
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "THREAT\tNAME\tMITIGATION\tSINCE")
+			for _, t := range threats {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.ID, t.Name, status.FmtSecurityLevel(t.MitigationLevel), time.Unix(t.Started, 0).Format("2006-01-02 15:04:05"))
+			}
+			w.Flush()
+		}
+
+		// modules
+		fmt.Println()
+		if upStatus.Core != nil {
+			fmt.Printf("Core Version:     %s\n", upStatus.Core.Version)
+		}
+		identifiers := make([]string, 0, len(upStatus.Modules))
+		for identifier := range upStatus.Modules {
+			identifiers = append(identifiers, identifier)
+		}
+		sort.Strings(identifiers)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MODULE\tVERSION\tCHANNEL")
+		for _, identifier := range identifiers {
+			module := upStatus.Modules[identifier]
+			if module.LastVersionUsed == "" {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", identifier, module.LastVersionUsed, module.Channel)
+		}
+		return w.Flush()
+	},
+}
+
+func fmtSelectedLevel(level uint8) string {
+	if level == 0 {
+		return "automatic"
+	}
+	return status.FmtSecurityLevel(level)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/Safing/portmaster/updates"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.AddCommand(updateImportCmd)
+	updateCmd.AddCommand(updateUnblacklistCmd)
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Manage updates",
+}
+
+var updateImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Verify and install an offline update bundle",
+	Long:  "Verify and install an offline update bundle, as built by uptool. A running Portmaster also imports bundles placed in the import directory of the update storage.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := updates.CheckDir(updateStoragePath)
+		if err != nil {
+			return err
+		}
+
+		installed, err := updates.ImportBundle(args[0])
+		if err != nil {
+			return fmt.Errorf("%s failed to import bundle: %s", logPrefix, err)
+		}
+
+		fmt.Printf("%s imported %d new files, restart the Portmaster to apply updates\n", logPrefix, installed)
+		return nil
+	},
+}
+
+var updateUnblacklistCmd = &cobra.Command{
+	Use:   "unblacklist <identifier> [version]",
+	Short: "Allow a version that was rolled back to be used again",
+	Long:  "Remove a version of a component from the blacklist, eg. after it was rolled back because of crashes that were not caused by the update. If no version is given, all versions of the component are removed from the blacklist.",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var version string
+		if len(args) == 2 {
+			version = args[1]
+		}
+
+		removed, err := updates.UnblacklistVersion(args[0], version)
+		if err == nil && len(removed) == 0 {
+			// try platform specific identifier
+			identifier := path.Join(fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH), args[0])
+			if windows() && !strings.HasSuffix(identifier, ".exe") {
+				identifier += ".exe"
+			}
+			removed, err = updates.UnblacklistVersion(identifier, version)
+		}
+		if err != nil {
+			return fmt.Errorf("%s failed to update blacklist: %s", logPrefix, err)
+		}
+		if len(removed) == 0 {
+			fmt.Printf("%s no blacklisted versions of %s found\n", logPrefix, args[0])
+			return nil
+		}
+
+		fmt.Printf("%s removed %s v%s from the blacklist, restart the Portmaster to apply\n", logPrefix, args[0], strings.Join(removed, ", v"))
+		return nil
+	},
+}
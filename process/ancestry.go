@@ -0,0 +1,86 @@
+package process
+
+import (
+	"context"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/profile"
+)
+
+const (
+	// maxAncestryDepth limits how far up the process tree the parent chain is followed.
+	maxAncestryDepth = 32
+)
+
+// loadAncestry walks up the process tree and returns the parent chain of the process, starting with the direct parent. The process must be locked.
+func (p *Process) loadAncestry(ctx context.Context) profile.Ancestry {
+	var ancestry profile.Ancestry
+	seen := map[int]struct{}{
+		p.Pid: struct{}{},
+	}
+
+	pid := p.ParentPid
+	for i := 0; i < maxAncestryDepth; i++ {
+		// stop at the kernel and when looping
+		if pid <= 0 {
+			break
+		}
+		if _, ok := seen[pid]; ok {
+			break
+		}
+		seen[pid] = struct{}{}
+
+		parent, err := loadProcess(ctx, pid)
+		if err != nil {
+			log.Tracer(ctx).Tracef("process: could not load ancestor %d of %d: %s", pid, p.Pid, err)
+			break
+		}
+		ancestor, parentPid, ok := parent.describeAsAncestor(ctx)
+		if !ok {
+			break
+		}
+		ancestry = append(ancestry, ancestor)
+
+		pid = parentPid
+	}
+
+	return ancestry
+}
+
+// describeAsAncestor returns the process as an ancestor of another process, as well as its own parent. The profile of the ancestor is only looked up when an ancestry condition needs it.
+func (p *Process) describeAsAncestor(ctx context.Context) (ancestor *profile.Ancestor, parentPid int, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.Error != "" {
+		return nil, 0, false
+	}
+
+	ancestor = &profile.Ancestor{
+		Pid:              p.Pid,
+		Path:             p.Path,
+		ResolveProfileID: p.getAncestorProfileID,
+	}
+	return ancestor, p.ParentPid, true
+}
+
+// getAncestorProfileID returns the ID of the user profile of the process, when it is the ancestor of another process. The result is cached on the process, which is shared between all its children. The parent is locked while matching its profile, as matching may record executable hashes.
+func (p *Process) getAncestorProfileID() string {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.ancestorProfileIDLoaded {
+		return p.ancestorProfileID
+	}
+
+	ancestorProfiles, err := getUserProfilesByPath(p.Path)
+	if err != nil {
+		log.Tracef("process: could not get profile of ancestor %s: %s", p.Path, err)
+		return ""
+	}
+	if ancestorProfile := selectProfile(p, ancestorProfiles); ancestorProfile != nil {
+		p.ancestorProfileID = ancestorProfile.ID
+	}
+	p.ancestorProfileIDLoaded = true
+	return p.ancestorProfileID
+}
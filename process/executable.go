@@ -4,13 +4,17 @@ package process
 
 import (
 	"crypto"
+	_ "crypto/md5" // register hash functions
+	_ "crypto/sha1"
+	_ "crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
 	"os"
 )
 
-// GetExecHash returns the hash of the executable with the given algorithm.
+// GetExecHash returns the hash of the executable with the given algorithm. The process must be locked.
 func (p *Process) GetExecHash(algorithm string) (string, error) {
 	sum, ok := p.ExecHashes[algorithm]
 	if ok {
@@ -25,12 +29,15 @@ func (p *Process) GetExecHash(algorithm string) (string, error) {
 		hasher = crypto.SHA1.New()
 	case "sha256":
 		hasher = crypto.SHA256.New()
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algorithm)
 	}
 
 	file, err := os.Open(p.Path)
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 
 	_, err = io.Copy(hasher, file)
 	if err != nil {
@@ -38,6 +45,9 @@ func (p *Process) GetExecHash(algorithm string) (string, error) {
 	}
 
 	sum = hex.EncodeToString(hasher.Sum(nil))
+	if p.ExecHashes == nil {
+		p.ExecHashes = make(map[string]string)
+	}
 	p.ExecHashes[algorithm] = sum
 	return sum, nil
 }
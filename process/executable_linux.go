@@ -0,0 +1,17 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// getFileID returns an identifier for the file that changes whenever the file is replaced or modified.
+func getFileID(path string, stat os.FileInfo) string {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Sprintf("%s-%d-%d", path, stat.Size(), stat.ModTime().UnixNano())
+	}
+	// the change time cannot be set by users, unlike the modification time
+	return fmt.Sprintf("%d-%d-%d-%d.%d", sys.Dev, sys.Ino, stat.ModTime().UnixNano(), sys.Ctim.Sec, sys.Ctim.Nsec)
+}
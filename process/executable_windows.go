@@ -0,0 +1,11 @@
+package process
+
+import (
+	"fmt"
+	"os"
+)
+
+// getFileID returns an identifier for the file that changes whenever the file is replaced or modified.
+func getFileID(path string, stat os.FileInfo) string {
+	return fmt.Sprintf("%s-%d-%d", path, stat.Size(), stat.ModTime().UnixNano())
+}
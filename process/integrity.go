@@ -0,0 +1,255 @@
+package process
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portbase/notifications"
+	"github.com/Safing/portmaster/profile"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	// execInfoCache caches executable information by file identity (inode and modification time), so that executables only need to be hashed when they change.
+	execInfoCache     = make(map[string]*profile.ExecutableInfo)
+	execInfoCacheLock sync.Mutex
+
+	execChangePromptTTL = 24 * time.Hour
+)
+
+// GetExecutableInfo returns the size, modification time and hash of the executable at the given path.
+func GetExecutableInfo(path string) (*profile.ExecutableInfo, error) {
+	info, fileID, stat, err := getCachedExecutableInfo(path)
+	if err != nil || info != nil {
+		return info, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return nil, err
+	}
+
+	info = &profile.ExecutableInfo{
+		SHA256:  hex.EncodeToString(hasher.Sum(nil)),
+		Size:    stat.Size(),
+		ModTime: stat.ModTime().Unix(),
+	}
+
+	execInfoCacheLock.Lock()
+	execInfoCache[fileID] = info
+	execInfoCacheLock.Unlock()
+
+	return info, nil
+}
+
+// getCachedExecutableInfo returns the cached executable information, if the executable was not replaced or modified since it was hashed.
+func getCachedExecutableInfo(path string) (info *profile.ExecutableInfo, fileID string, stat os.FileInfo, err error) {
+	stat, err = os.Stat(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	fileID = getFileID(path, stat)
+
+	execInfoCacheLock.Lock()
+	defer execInfoCacheLock.Unlock()
+	return execInfoCache[fileID], fileID, stat, nil
+}
+
+// checkExecutableIntegrity checks whether the executable of the process still matches the executable recorded on the given user profile and returns whether the profile was changed and needs to be saved. If the executable was not hashed yet, it is hashed in the background and the profile is denied network access until then. The process must be locked.
+func (p *Process) checkExecutableIntegrity(ctx context.Context, userProfile *profile.Profile) (changed bool) {
+	execInfo, _, stat, err := getCachedExecutableInfo(p.Path)
+	if err != nil {
+		log.Tracer(ctx).Warningf("process: failed to get executable info of %s: %s", p.Path, err)
+		return false
+	}
+	if execInfo != nil {
+		return p.applyExecutableInfo(ctx, userProfile, execInfo)
+	}
+
+	// do not hash on the packet path, but do not let a changed executable use the network until it was checked
+	// the cache is empty after a restart, so executables that still have the recorded size and modification time are not held back
+	userProfile.Lock()
+	verifying := userProfile.Executable != nil && !userProfile.Executable.MatchesFile(stat.Size(), stat.ModTime().Unix())
+	if verifying {
+		userProfile.StartVerifyingExecutable()
+	}
+	userProfile.Unlock()
+
+	go func() {
+		if verifying {
+			defer func() {
+				userProfile.Lock()
+				userProfile.FinishVerifyingExecutable()
+				userProfile.Unlock()
+			}()
+		}
+
+		execInfo, err := GetExecutableInfo(p.Path)
+		if err != nil {
+			log.Warningf("process: failed to get executable info of %s: %s", p.Path, err)
+			return
+		}
+
+		p.Lock()
+		changed := p.applyExecutableInfo(context.Background(), userProfile, execInfo)
+		p.Unlock()
+
+		if changed {
+			err := userProfile.Save(profile.UserNamespace)
+			if err != nil {
+				log.Warningf("process: failed to save profile %s: %s", userProfile.ID, err)
+			}
+		}
+	}()
+	return false
+}
+
+// applyExecutableInfo compares the executable information with the one recorded on the user profile and acts on changes. It returns whether the profile was changed and needs to be saved. The process must be locked.
+func (p *Process) applyExecutableInfo(ctx context.Context, userProfile *profile.Profile, execInfo *profile.ExecutableInfo) (changed bool) {
+	if p.ExecHashes == nil {
+		p.ExecHashes = make(map[string]string)
+	}
+	p.ExecHashes["sha256"] = execInfo.SHA256
+
+	userProfile.Lock()
+	defer userProfile.Unlock()
+
+	// record executable of new profiles and profiles that were created without it
+	if userProfile.Executable == nil {
+		userProfile.RecordExecutable(&profile.ExecutableInfo{
+			SHA256:  execInfo.SHA256,
+			Size:    execInfo.Size,
+			ModTime: execInfo.ModTime,
+		})
+		return true
+	}
+
+	// check if still the same executable
+	if userProfile.Executable.Matches(execInfo) {
+		return false
+	}
+	if userProfile.Quarantined {
+		// ask again, if the previous prompt was not answered
+		if userProfile.AwaitingConfirmation {
+			go notifyExecutableChange(userProfile, execInfo, p.Path, profile.ExecChangePrompt)
+		}
+		return false
+	}
+
+	log.Tracer(ctx).Warningf("process: executable of profile %s changed: %s", userProfile, p.Path)
+	log.Warningf("process: executable %s of profile %s changed (sha256 %s -> %s)", p.Path, userProfile.ID, userProfile.Executable.SHA256, execInfo.SHA256)
+
+	switch profile.GetExecutableChangeAction(status.ActiveSecurityLevel()) {
+	case profile.ExecChangeQuarantine:
+		userProfile.Quarantine(fmt.Sprintf("executable %s changed", p.Path))
+		go notifyExecutableChange(userProfile, execInfo, p.Path, profile.ExecChangeQuarantine)
+		return true
+	case profile.ExecChangePrompt:
+		// deny network access until the user decided
+		userProfile.QuarantineUntilConfirmed(fmt.Sprintf("executable %s changed, waiting for confirmation", p.Path))
+		go notifyExecutableChange(userProfile, execInfo, p.Path, profile.ExecChangePrompt)
+		return true
+	default:
+		userProfile.RecordExecutable(&profile.ExecutableInfo{
+			SHA256:  execInfo.SHA256,
+			Size:    execInfo.Size,
+			ModTime: execInfo.ModTime,
+		})
+		go notifyExecutableChange(userProfile, execInfo, p.Path, profile.ExecChangeKeep)
+		return true
+	}
+}
+
+func notifyExecutableChange(userProfile *profile.Profile, execInfo *profile.ExecutableInfo, path string, action uint8) {
+	nID := fmt.Sprintf("process-executable-changed-%s", userProfile.ID)
+
+	// only ever have one notification per profile
+	if notifications.Get(nID) != nil {
+		return
+	}
+
+	n := &notifications.Notification{
+		ID:      nID,
+		Expires: time.Now().Add(execChangePromptTTL).Unix(),
+	}
+
+	switch action {
+	case profile.ExecChangeQuarantine:
+		n.Type = notifications.Warning
+		n.Message = fmt.Sprintf("The executable %s has changed. Its profile %s was quarantined and may not access the network until you release it.", path, userProfile.Name)
+		n.AvailableActions = []*notifications.Action{
+			&notifications.Action{
+				ID:   "release",
+				Text: "Release",
+			},
+			&notifications.Action{
+				ID:   "keep-quarantined",
+				Text: "Keep quarantined",
+			},
+		}
+	case profile.ExecChangePrompt:
+		n.Type = notifications.Prompt
+		n.Message = fmt.Sprintf("The executable %s has changed and may not access the network until you decide. Do you want it to keep the permissions of profile %s?", path, userProfile.Name)
+		n.AvailableActions = []*notifications.Action{
+			&notifications.Action{
+				ID:   "release",
+				Text: "Keep permissions",
+			},
+			&notifications.Action{
+				ID:   "quarantine",
+				Text: "Quarantine",
+			},
+		}
+	default:
+		n.Type = notifications.Info
+		n.Message = fmt.Sprintf("The executable %s has changed. It keeps the permissions of profile %s.", path, userProfile.Name)
+		n.Init().Save()
+		return
+	}
+
+	n.Init().Save()
+
+	select {
+	case response := <-n.Response():
+		n.Cancel()
+
+		userProfile.Lock()
+		switch response {
+		case "release":
+			log.Infof("process: user accepted changed executable %s of profile %s", path, userProfile.ID)
+			userProfile.RecordExecutable(&profile.ExecutableInfo{
+				SHA256:  execInfo.SHA256,
+				Size:    execInfo.Size,
+				ModTime: execInfo.ModTime,
+			})
+		case "quarantine":
+			log.Infof("process: user quarantined profile %s after executable %s changed", userProfile.ID, path)
+			userProfile.Quarantine(fmt.Sprintf("executable %s changed", path))
+		default:
+			userProfile.Unlock()
+			return
+		}
+		userProfile.Unlock()
+
+		err := userProfile.Save(profile.UserNamespace)
+		if err != nil {
+			log.Warningf("process: failed to save profile %s: %s", userProfile.ID, err)
+		}
+	case <-time.After(execChangePromptTTL):
+		n.Cancel()
+	}
+}
@@ -3,6 +3,7 @@ package process
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/Safing/portbase/database"
 	"github.com/Safing/portbase/database/query"
@@ -27,23 +28,11 @@ func (p *Process) FindProfiles(ctx context.Context) error {
 	}
 
 	// User Profile
-	it, err := profileDB.Query(query.New(profile.MakeProfileKey(profile.UserNamespace, "")).Where(query.Where("LinkedPath", query.SameAs, p.Path)))
+	userProfiles, err := getUserProfilesByPath(p.Path)
 	if err != nil {
 		return err
 	}
-
-	var userProfile *profile.Profile
-	for r := range it.Next {
-		it.Cancel()
-		userProfile, err = profile.EnsureProfile(r)
-		if err != nil {
-			return err
-		}
-		break
-	}
-	if it.Err() != nil {
-		return it.Err()
-	}
+	userProfile := selectProfile(p, userProfiles)
 
 	// create new profile if it does not exist.
 	if userProfile == nil {
@@ -53,7 +42,10 @@ func (p *Process) FindProfiles(ctx context.Context) error {
 		userProfile.LinkedPath = p.Path
 	}
 
-	if userProfile.MarkUsed() {
+	// check if the executable changed since it was last accepted
+	changed := p.checkExecutableIntegrity(ctx, userProfile)
+
+	if userProfile.MarkUsed() || changed {
 		userProfile.Save(profile.UserNamespace)
 	}
 
@@ -67,27 +59,69 @@ func (p *Process) FindProfiles(ctx context.Context) error {
 	// 6. link stamp profile to user profile
 	// FIXME: implement!
 
+	// Ancestry
+	p.Ancestry = p.loadAncestry(ctx)
+
 	p.UserProfileKey = userProfile.Key()
 	p.profileSet = profile.NewSet(ctx, fmt.Sprintf("%d-%s", p.Pid, p.Path), userProfile, nil)
+	p.profileSet.SetAncestry(p.Ancestry)
 	go p.Save()
 
 	return nil
 }
 
+// getUserProfilesByPath returns all user profiles linked to the given path.
+func getUserProfilesByPath(path string) ([]*profile.Profile, error) {
+	it, err := profileDB.Query(query.New(profile.MakeProfileKey(profile.UserNamespace, "")).Where(query.Where("LinkedPath", query.SameAs, path)))
+	if err != nil {
+		return nil, err
+	}
+
+	var userProfiles []*profile.Profile
+	for r := range it.Next {
+		userProfile, err := profile.EnsureProfile(r)
+		if err != nil {
+			it.Cancel()
+			return nil, err
+		}
+		userProfiles = append(userProfiles, userProfile)
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return userProfiles, nil
+}
+
+// selectProfile returns the profile that matches the process best, or nil if none matches. Profiles are all linked to the path of the process, so a profile without matching fingerprints is still a match with a score of 0, only profiles scoring -1 are never selected.
 func selectProfile(p *Process, profs []*profile.Profile) (selectedProfile *profile.Profile) {
-	var highestScore int
+	highestScore := -1
 	for _, prof := range profs {
 		score := matchProfile(p, prof)
 		if score > highestScore {
+			highestScore = score
 			selectedProfile = prof
 		}
 	}
 	return
 }
 
+// matchProfile returns the sum of the weights of all matching fingerprints. Profiles with command line fingerprints only match if at least one of them matches, else -1 is returned.
 func matchProfile(p *Process, prof *profile.Profile) (score int) {
+	var hasCmdLineFP, cmdLineMatched bool
 	for _, fp := range prof.Fingerprints {
-		score += matchFingerprint(p, fp)
+		fpScore := matchFingerprint(p, fp)
+		if fp.Type == profile.CmdLineFingerprintType && fp.MatchesOS() {
+			hasCmdLineFP = true
+			if fpScore > 0 {
+				cmdLineMatched = true
+			}
+		}
+		score += fpScore
+	}
+
+	if hasCmdLineFP && !cmdLineMatched {
+		return -1
 	}
 	return
 }
@@ -100,14 +134,18 @@ func matchFingerprint(p *Process, fp *profile.Fingerprint) (score int) {
 	switch fp.Type {
 	case "full_path":
 		if p.Path == fp.Value {
+			return profile.GetFingerprintWeight(fp.Type)
 		}
-		return profile.GetFingerprintWeight(fp.Type)
 	case "partial_path":
 		// FIXME: if full_path matches, do not match partial paths
 		return profile.GetFingerprintWeight(fp.Type)
+	case profile.CmdLineFingerprintType:
+		if fp.MatchesCmdLine(p.CmdLine, p.CmdArgs) {
+			return profile.GetFingerprintWeight(fp.Type)
+		}
 	case "md5_sum", "sha1_sum", "sha256_sum":
 		// FIXME: one sum is enough, check sums in a grouped form, start with the best
-		sum, err := p.GetExecHash(fp.Type)
+		sum, err := p.GetExecHash(strings.TrimSuffix(fp.Type, "_sum"))
 		if err != nil {
 			log.Errorf("process: failed to get hash of executable: %s", err)
 		} else if sum == fp.Value {
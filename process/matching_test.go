@@ -0,0 +1,66 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/Safing/portmaster/profile"
+)
+
+func testProfile(id string, fingerprints ...*profile.Fingerprint) *profile.Profile {
+	prof := profile.New()
+	prof.ID = id
+	for _, fp := range fingerprints {
+		prof.AddFingerprint(fp)
+	}
+	return prof
+}
+
+func TestSelectProfile(t *testing.T) {
+	p := &Process{
+		Path:    "/usr/bin/java",
+		CmdLine: "java -jar /opt/app/a.jar",
+	}
+
+	plain := testProfile("plain")
+	fullPath := testProfile("full-path", &profile.Fingerprint{Type: "full_path", Value: "/usr/bin/java"})
+	otherPath := testProfile("other-path", &profile.Fingerprint{Type: "full_path", Value: "/usr/bin/other"})
+	appA := testProfile("app-a", &profile.Fingerprint{Type: profile.CmdLineFingerprintType, Value: "java -jar *a.jar"})
+	appB := testProfile("app-b", &profile.Fingerprint{Type: profile.CmdLineFingerprintType, Value: "java -jar *b.jar"})
+
+	// scores
+	if score := matchProfile(p, plain); score != 0 {
+		t.Errorf("profile without fingerprints should score 0, got %d", score)
+	}
+	if score := matchProfile(p, otherPath); score != 0 {
+		t.Errorf("profile with non-matching path should score 0, got %d", score)
+	}
+	if score := matchProfile(p, appB); score != -1 {
+		t.Errorf("profile with non-matching command line should not match, got %d", score)
+	}
+
+	testCases := []struct {
+		profiles []*profile.Profile
+		expected string
+	}{
+		// a profile without matching fingerprints is still selected, if it is the only one linked to the path
+		{[]*profile.Profile{plain}, "plain"},
+		{[]*profile.Profile{plain, fullPath}, "full-path"},
+		{[]*profile.Profile{fullPath, plain}, "full-path"},
+		// the best match wins, regardless of the order
+		{[]*profile.Profile{fullPath, appA}, "app-a"},
+		{[]*profile.Profile{appA, fullPath}, "app-a"},
+		// profiles for other command lines are never selected
+		{[]*profile.Profile{appB, plain}, "plain"},
+		{[]*profile.Profile{appB}, ""},
+		{nil, ""},
+	}
+	for i, tc := range testCases {
+		var selected string
+		if prof := selectProfile(p, tc.profiles); prof != nil {
+			selected = prof.ID
+		}
+		if selected != tc.expected {
+			t.Errorf("case %d: expected profile %q to be selected, got %q", i, tc.expected, selected)
+		}
+	}
+}
@@ -35,10 +35,17 @@ type Process struct {
 	Path      string
 	Cwd       string
 	CmdLine   string
+	CmdArgs   []string
 	FirstArg  string
 
 	ExecName   string
 	ExecHashes map[string]string
+
+	// Ancestry is the parent chain of the process, starting with the direct parent.
+	Ancestry profile.Ancestry
+	// ancestorProfileID caches the user profile ID of the process for ancestry conditions of its children.
+	ancestorProfileID       string
+	ancestorProfileIDLoaded bool
 	// ExecOwner ...
 	// ExecSignature ...
 
@@ -314,6 +321,10 @@ func loadProcess(ctx context.Context, pid int) (*Process, error) {
 		if err != nil {
 			return failedToLoad(new, fmt.Errorf("failed to get Cmdline for p%d: %s", pid, err))
 		}
+		new.CmdArgs, err = pInfo.CmdlineSlice()
+		if err != nil {
+			return failedToLoad(new, fmt.Errorf("failed to get Cmdline arguments for p%d: %s", pid, err))
+		}
 
 		// Name
 		new.Name, err = pInfo.Name()
@@ -31,6 +31,29 @@ func DeactivateProfileSet(set *Set) {
 	log.Tracef("profile: deactivated profile set %s", set.id)
 }
 
+// isActiveProfile returns whether the user profile with the given ID is used by an active profile set.
+func isActiveProfile(ID string) bool {
+	activeProfileSetsLock.RLock()
+	defer activeProfileSetsLock.RUnlock()
+
+	for _, activeSet := range activeProfileSets {
+		activeSet.Lock()
+		userProfile := activeSet.profiles[0]
+		activeSet.Unlock()
+
+		if userProfile != nil {
+			userProfile.Lock()
+			active := userProfile.ID == ID
+			userProfile.Unlock()
+			if active {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func updateActiveProfile(profile *Profile, userProfile bool) {
 	activeProfileSetsLock.RLock()
 	defer activeProfileSetsLock.RUnlock()
@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"fmt"
+)
+
+// Ancestor describes a process in the parent chain of a process.
+type Ancestor struct {
+	Pid       int
+	Path      string
+	ProfileID string
+	// ResolveProfileID returns the ID of the user profile of the ancestor, if ProfileID is not set. As it may need to query the database, it is only called when an ancestry condition requires a profile ID.
+	ResolveProfileID func() string `json:"-"`
+}
+
+// getProfileID returns the ID of the user profile of the ancestor.
+func (ancestor *Ancestor) getProfileID() string {
+	if ancestor.ProfileID == "" && ancestor.ResolveProfileID != nil {
+		return ancestor.ResolveProfileID()
+	}
+	return ancestor.ProfileID
+}
+
+// Ancestry is the parent chain of a process, starting with the direct parent.
+type Ancestry []*Ancestor
+
+// Contains returns whether the given executable path or profile ID is part of the ancestry. Empty values are ignored. If _parentOnly_ is set, only the direct parent is checked.
+func (a Ancestry) Contains(path, profileID string, parentOnly bool) bool {
+	for i, ancestor := range a {
+		if parentOnly && i > 0 {
+			return false
+		}
+		if ancestor == nil {
+			continue
+		}
+
+		if path != "" && ancestor.Path == path {
+			return true
+		}
+		if profileID != "" && ancestor.getProfileID() == profileID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Ancestry) String() string {
+	s := ""
+	for i, ancestor := range a {
+		if ancestor == nil {
+			continue
+		}
+		if i > 0 {
+			s += " < "
+		}
+		s += fmt.Sprintf("%s:%d", ancestor.Path, ancestor.Pid)
+	}
+	return s
+}
+
+func (ep EndpointPermission) hasAncestryCondition() bool {
+	return ep.AncestorPath != "" || ep.AncestorProfile != ""
+}
+
+// matchesAncestry returns whether the ancestry condition of the EndpointPermission is fulfilled. Permissions without ancestry condition always match.
+func (ep EndpointPermission) matchesAncestry(ancestry Ancestry) bool {
+	if !ep.hasAncestryCondition() {
+		return true
+	}
+	return ancestry.Contains(ep.AncestorPath, ep.AncestorProfile, ep.ParentOnly)
+}
@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portmaster/internal/apiutil"
+)
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/profiles/v1/stale", handleListStaleProfiles).Methods("GET")
+	api.RegisterHandleFunc("/api/profiles/v1/stale/clean", apiutil.Protect(handleCleanStaleProfiles)).Methods("POST")
+	api.RegisterHandleFunc("/api/profiles/v1/merge", apiutil.Protect(handleMergeProfiles)).Methods("POST")
+	api.RegisterHandleFunc("/api/profiles/v1/user/{id:[a-zA-Z0-9-]+}/archive", apiutil.Protect(handleArchiveProfile)).Methods("POST")
+	api.RegisterHandleFunc("/api/profiles/v1/user/{id:[a-zA-Z0-9-]+}", apiutil.Protect(handleDeleteProfile)).Methods("DELETE")
+	api.RegisterHandleFunc("/api/profiles/v1/archive/{id:[a-zA-Z0-9-]+}/restore", apiutil.Protect(handleRestoreProfile)).Methods("POST")
+
+	return nil
+}
+
+func getUnusedDays(r *http.Request) (int, error) {
+	param := r.URL.Query().Get("days")
+	if param == "" {
+		days := int(staleProfileDays())
+		if days <= 0 {
+			return 0, errors.New("stale profile check is disabled, please specify days")
+		}
+		return days, nil
+	}
+
+	days, err := strconv.Atoi(param)
+	if err != nil || days <= 0 {
+		return 0, errors.New("invalid days parameter")
+	}
+	return days, nil
+}
+
+func handleListStaleProfiles(w http.ResponseWriter, r *http.Request) {
+	days, err := getUnusedDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	staleProfiles, err := FindStaleProfiles(days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apiutil.WriteJSON(w, staleProfiles)
+}
+
+func handleCleanStaleProfiles(w http.ResponseWriter, r *http.Request) {
+	days, err := getUnusedDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.URL.Query().Get("action")
+	switch action {
+	case StaleActionArchive, StaleActionDelete:
+	default:
+		http.Error(w, "action must be archive or delete", http.StatusBadRequest)
+		return
+	}
+
+	staleProfiles, err := CleanStaleProfiles(days, action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apiutil.WriteJSON(w, staleProfiles)
+}
+
+func handleMergeProfiles(w http.ResponseWriter, r *http.Request) {
+	targetID := r.URL.Query().Get("target")
+	sourceID := r.URL.Query().Get("source")
+	if targetID == "" || sourceID == "" {
+		http.Error(w, "target and source are required", http.StatusBadRequest)
+		return
+	}
+
+	merged, err := MergeUserProfiles(targetID, sourceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	merged.Lock()
+	defer merged.Unlock()
+	apiutil.WriteJSON(w, merged)
+}
+
+func handleArchiveProfile(w http.ResponseWriter, r *http.Request) {
+	err := ArchiveUserProfile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	err := DeleteUserProfile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleRestoreProfile(w http.ResponseWriter, r *http.Request) {
+	err := RestoreUserProfile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	promptOnExecutableChange     status.SecurityLevelOption
+	quarantineOnExecutableChange status.SecurityLevelOption
+
+	staleProfileDays     config.IntOption
+	archiveStaleProfiles config.BoolOption
+)
+
+func registerConfig() error {
+	err := config.Register(&config.Option{
+		Name:            "Prompt on Executable Change",
+		Key:             "profile/promptOnExecutableChange",
+		Description:     "Ask the user what to do, when the executable linked to a profile changed since the profile was created. If turned off, the change is accepted and the user is only notified.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		ExternalOptType: "security level",
+		DefaultValue:    6,
+		ValidationRegex: "^(7|6|4)$",
+	})
+	if err != nil {
+		return err
+	}
+	promptOnExecutableChange = status.ConfigIsActiveConcurrent("profile/promptOnExecutableChange")
+
+	err = config.Register(&config.Option{
+		Name:            "Quarantine on Executable Change",
+		Key:             "profile/quarantineOnExecutableChange",
+		Description:     "Deny all network access of a profile, when the executable linked to it changed since the profile was created. The user may release the profile again.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		ExternalOptType: "security level",
+		DefaultValue:    4,
+		ValidationRegex: "^(7|6|4)$",
+	})
+	if err != nil {
+		return err
+	}
+	quarantineOnExecutableChange = status.ConfigIsActiveConcurrent("profile/quarantineOnExecutableChange")
+
+	err = config.Register(&config.Option{
+		Name:            "Stale Profile Threshold",
+		Key:             "profile/staleProfileDays",
+		Description:     "Profiles that were not used for this amount of days are considered stale, as are profiles whose executable does not exist anymore. Set to 0 to disable the check.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    staleProfileDefaultDays,
+		ValidationRegex: "^[0-9]{1,4}$",
+	})
+	if err != nil {
+		return err
+	}
+	staleProfileDays = config.Concurrent.GetAsInt("profile/staleProfileDays", int64(staleProfileDefaultDays))
+
+	err = config.Register(&config.Option{
+		Name:           "Archive Stale Profiles",
+		Key:            "profile/archiveStaleProfiles",
+		Description:    "Automatically archive stale profiles once a day. Archived profiles are not used anymore, but may be restored.",
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		OptType:        config.OptTypeBool,
+		DefaultValue:   false,
+	})
+	if err != nil {
+		return err
+	}
+	archiveStaleProfiles = config.Concurrent.GetAsBool("profile/archiveStaleProfiles", false)
+
+	return nil
+}
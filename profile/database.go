@@ -9,12 +9,14 @@ import (
 //                      /global
 // core:profiles/stamp/12334-1235-1234-5123-1234
 // core:profiles/identifier/base64
+// core:profiles/archive/12345-1234-125-1234-1235
 
 // Namespaces
 const (
 	UserNamespace    = "user"
 	StampNamespace   = "stamp"
 	SpecialNamespace = "special"
+	ArchiveNamespace = "archive"
 )
 
 var (
@@ -21,6 +21,11 @@ type EndpointPermission struct {
 	StartPort uint16
 	EndPort   uint16
 
+	// Ancestry conditions: if set, the permission only matches if the process was launched by the given executable or by a process of the given profile, either anywhere in the parent chain or, with ParentOnly, as the direct parent.
+	AncestorPath    string
+	AncestorProfile string
+	ParentOnly      bool
+
 	Permit  bool
 	Created int64
 }
@@ -60,14 +65,14 @@ func (e Endpoints) IsSet() bool {
 	return false
 }
 
-// CheckDomain checks the if the given endpoint matches a EndpointPermission in the list.
-func (e Endpoints) CheckDomain(domain string) (result EPResult, reason string) {
+// CheckDomain checks the if the given endpoint matches a EndpointPermission in the list. Permissions with an ancestry condition are only considered if the given ancestry fulfills it.
+func (e Endpoints) CheckDomain(domain string, ancestry Ancestry) (result EPResult, reason string) {
 	if domain == "" {
 		return Denied, "internal error"
 	}
 
 	for _, entry := range e {
-		if entry != nil {
+		if entry != nil && entry.matchesAncestry(ancestry) {
 			if result, reason = entry.MatchesDomain(domain); result != NoMatch {
 				return
 			}
@@ -77,8 +82,8 @@ func (e Endpoints) CheckDomain(domain string) (result EPResult, reason string) {
 	return NoMatch, ""
 }
 
-// CheckIP checks the if the given endpoint matches a EndpointPermission in the list. If _checkReverseIP_ and no domain is given, the IP will be resolved to a domain, if necessary.
-func (e Endpoints) CheckIP(domain string, ip net.IP, protocol uint8, port uint16, checkReverseIP bool, securityLevel uint8) (result EPResult, reason string) {
+// CheckIP checks the if the given endpoint matches a EndpointPermission in the list. If _checkReverseIP_ and no domain is given, the IP will be resolved to a domain, if necessary. Permissions with an ancestry condition are only considered if the given ancestry fulfills it.
+func (e Endpoints) CheckIP(domain string, ip net.IP, protocol uint8, port uint16, checkReverseIP bool, securityLevel uint8, ancestry Ancestry) (result EPResult, reason string) {
 	if ip == nil {
 		return Denied, "internal error"
 	}
@@ -103,7 +108,7 @@ func (e Endpoints) CheckIP(domain string, ip net.IP, protocol uint8, port uint16
 	}
 
 	for _, entry := range e {
-		if entry != nil {
+		if entry != nil && entry.matchesAncestry(ancestry) {
 			if result, reason := entry.MatchesIP(domain, ip, protocol, port, cachedGetDomainOfIP); result != NoMatch {
 				return result, reason
 			}
@@ -302,6 +307,19 @@ func (ep EndpointPermission) String() string {
 		s += "*"
 	}
 
+	if ep.hasAncestryCondition() {
+		if ep.ParentOnly {
+			s += " parent="
+		} else {
+			s += " ancestor="
+		}
+		if ep.AncestorPath != "" {
+			s += ep.AncestorPath
+		} else {
+			s += "profile:" + ep.AncestorProfile
+		}
+	}
+
 	return s
 }
 
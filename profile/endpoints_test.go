@@ -189,3 +189,71 @@ func TestEPString(t *testing.T) {
 		t.Errorf("unexpected result: %s", noEndpoints.String())
 	}
 }
+
+func TestEndpointAncestry(t *testing.T) {
+	endpoints := Endpoints{
+		&EndpointPermission{
+			Type:         EptDomain,
+			Value:        "example.com.",
+			AncestorPath: "/usr/bin/ci-agent",
+			Permit:       true,
+		},
+		&EndpointPermission{
+			Type:            EptAny,
+			AncestorProfile: "unit-test-shell",
+			ParentOnly:      true,
+			Permit:          true,
+		},
+		&EndpointPermission{
+			Type:   EptAny,
+			Permit: false,
+		},
+	}
+
+	ciAncestry := Ancestry{
+		&Ancestor{Pid: 20, Path: "/bin/sh"},
+		&Ancestor{Pid: 10, Path: "/usr/bin/ci-agent"},
+	}
+	shellAncestry := Ancestry{
+		&Ancestor{Pid: 30, Path: "/bin/bash", ProfileID: "unit-test-shell"},
+	}
+	deepShellAncestry := Ancestry{
+		&Ancestor{Pid: 31, Path: "/usr/bin/make"},
+		&Ancestor{Pid: 30, Path: "/bin/bash", ProfileID: "unit-test-shell"},
+	}
+
+	testCases := []struct {
+		ancestry Ancestry
+		expected EPResult
+	}{
+		{nil, Denied},
+		{ciAncestry, Permitted},
+		{shellAncestry, Permitted},
+		{deepShellAncestry, Denied},
+	}
+	for _, tc := range testCases {
+		result, _ := endpoints.CheckDomain("example.com.", tc.ancestry)
+		if result != tc.expected {
+			t.Errorf("unexpected result for ancestry %s: result=%s, expected=%s", tc.ancestry, result, tc.expected)
+		}
+	}
+
+	if endpoints.String() != "[Domain:example.com. */* ancestor=/usr/bin/ci-agent, Any */* parent=profile:unit-test-shell, Any */*]" {
+		t.Errorf("unexpected result: %s", endpoints.String())
+	}
+
+	// profile IDs of ancestors are only resolved for profile conditions
+	var resolved int
+	lazyAncestry := Ancestry{
+		&Ancestor{Pid: 30, Path: "/bin/bash", ResolveProfileID: func() string {
+			resolved++
+			return "unit-test-shell"
+		}},
+	}
+	if result, _ := endpoints[:1].CheckDomain("example.com.", lazyAncestry); result != NoMatch || resolved != 0 {
+		t.Errorf("path conditions should not resolve profiles: result=%s, resolved=%d", result, resolved)
+	}
+	if result, _ := endpoints.CheckDomain("example.com.", lazyAncestry); result != Permitted || resolved != 1 {
+		t.Errorf("profile conditions should resolve profiles: result=%s, resolved=%d", result, resolved)
+	}
+}
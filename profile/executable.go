@@ -0,0 +1,88 @@
+package profile
+
+import (
+	"time"
+)
+
+// ExecutableInfo describes the executable a user profile is linked to. It is used to detect when the executable changes.
+type ExecutableInfo struct {
+	SHA256   string
+	Size     int64
+	ModTime  int64
+	Recorded int64
+}
+
+// Actions to take when the executable of a profile changed.
+const (
+	ExecChangeKeep uint8 = iota
+	ExecChangePrompt
+	ExecChangeQuarantine
+)
+
+// Matches returns whether the given ExecutableInfo describes the same executable.
+func (ei *ExecutableInfo) Matches(other *ExecutableInfo) bool {
+	if ei == nil || other == nil {
+		return false
+	}
+	return ei.SHA256 == other.SHA256 && ei.Size == other.Size
+}
+
+// MatchesFile returns whether the given file size and modification time are the ones recorded for the executable.
+func (ei *ExecutableInfo) MatchesFile(size, modTime int64) bool {
+	if ei == nil {
+		return false
+	}
+	return ei.Size == size && ei.ModTime == modTime
+}
+
+// GetExecutableChangeAction returns what should be done with a profile whose executable changed in the given security level.
+func GetExecutableChangeAction(securityLevel uint8) uint8 {
+	switch {
+	case quarantineOnExecutableChange(securityLevel):
+		return ExecChangeQuarantine
+	case promptOnExecutableChange(securityLevel):
+		return ExecChangePrompt
+	default:
+		return ExecChangeKeep
+	}
+}
+
+// RecordExecutable sets the given executable information as the known good executable of the profile and releases it from quarantine. The profile must be locked.
+func (profile *Profile) RecordExecutable(info *ExecutableInfo) {
+	if info.Recorded == 0 {
+		info.Recorded = time.Now().Unix()
+	}
+	profile.Executable = info
+	profile.Quarantined = false
+	profile.QuarantineReason = ""
+	profile.AwaitingConfirmation = false
+}
+
+// Quarantine denies all network access of the profile until it is released. The profile must be locked.
+func (profile *Profile) Quarantine(reason string) {
+	profile.Quarantined = true
+	profile.QuarantineReason = reason
+	profile.AwaitingConfirmation = false
+}
+
+// QuarantineUntilConfirmed denies all network access of the profile until the user confirmed or rejected the change. The profile must be locked.
+func (profile *Profile) QuarantineUntilConfirmed(reason string) {
+	profile.Quarantine(reason)
+	profile.AwaitingConfirmation = true
+}
+
+// StartVerifyingExecutable marks the executable of the profile as being verified. The profile is denied network access until all started verifications are finished. The profile must be locked.
+func (profile *Profile) StartVerifyingExecutable() {
+	profile.verifyingExecutable++
+}
+
+// FinishVerifyingExecutable marks a verification of the executable of the profile as finished. Once no verification is left, decisions made during verification are invalidated. The profile must be locked.
+func (profile *Profile) FinishVerifyingExecutable() {
+	if profile.verifyingExecutable <= 0 {
+		return
+	}
+	profile.verifyingExecutable--
+	if profile.verifyingExecutable == 0 {
+		increaseUpdateVersion()
+	}
+}
@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"testing"
+)
+
+func TestExecutableInfo(t *testing.T) {
+	recorded := &ExecutableInfo{
+		SHA256: "a2c1b9a4f2b7d8fca1a3c8a5e4f3c1e8b6d2a4f0c9e7b5a3d1f8e6c4b2a0f9e7",
+		Size:   1024,
+	}
+
+	if !recorded.Matches(&ExecutableInfo{SHA256: recorded.SHA256, Size: 1024, ModTime: 1}) {
+		t.Error("executable with same hash and size should match")
+	}
+	if recorded.Matches(&ExecutableInfo{SHA256: recorded.SHA256, Size: 2048}) {
+		t.Error("executable with different size should not match")
+	}
+	if recorded.Matches(&ExecutableInfo{SHA256: "00", Size: 1024}) {
+		t.Error("executable with different hash should not match")
+	}
+	if recorded.Matches(nil) {
+		t.Error("nil executable should not match")
+	}
+
+	profile := New()
+	profile.Quarantine("executable changed")
+	if !profile.Quarantined || profile.QuarantineReason != "executable changed" {
+		t.Error("profile should be quarantined")
+	}
+	profile.RecordExecutable(recorded)
+	if profile.Quarantined || profile.QuarantineReason != "" {
+		t.Error("recording an executable should release the profile")
+	}
+	if profile.Executable.Recorded == 0 {
+		t.Error("recording time should be set")
+	}
+
+	profile.QuarantineUntilConfirmed("executable changed, waiting for confirmation")
+	if !profile.Quarantined || !profile.AwaitingConfirmation {
+		t.Error("profile should be quarantined until confirmed")
+	}
+	profile.Quarantine("executable changed")
+	if !profile.Quarantined || profile.AwaitingConfirmation {
+		t.Error("rejecting the change should keep the profile quarantined without asking again")
+	}
+	profile.RecordExecutable(recorded)
+
+	set := &Set{profiles: [4]*Profile{profile, nil, nil, nil}}
+	profile.StartVerifyingExecutable()
+	profile.StartVerifyingExecutable()
+	if quarantined, _ := set.Quarantined(); !quarantined {
+		t.Error("profile should be denied network access while its executable is verified")
+	}
+	updateVersion := GetUpdateVersion()
+	profile.FinishVerifyingExecutable()
+	if quarantined, _ := set.Quarantined(); !quarantined {
+		t.Error("profile should be denied network access until all verifications are finished")
+	}
+	profile.FinishVerifyingExecutable()
+	if quarantined, _ := set.Quarantined(); quarantined {
+		t.Error("profile should not be quarantined after verification")
+	}
+	if GetUpdateVersion() == updateVersion {
+		t.Error("finishing verification should invalidate previous decisions")
+	}
+
+	if !recorded.MatchesFile(recorded.Size, recorded.ModTime) || recorded.MatchesFile(recorded.Size, recorded.ModTime+1) {
+		t.Error("file identity should be compared by size and modification time")
+	}
+}
@@ -6,6 +6,7 @@ var (
 	fingerprintWeights = map[string]int{
 		"full_path":    2,
 		"partial_path": 1,
+		"cmdline":      3,
 		"md5_sum":      4,
 		"sha1_sum":     5,
 		"sha256_sum":   6,
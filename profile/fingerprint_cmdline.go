@@ -0,0 +1,175 @@
+package profile
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Command line fingerprints distinguish between different workloads of the same executable, eg. `java -jar a.jar` and `java -jar b.jar`.
+//
+// Value syntax:
+//   <glob>         glob matched against the whole command line
+//   re:<regex>     regular expression matched against the whole command line
+//   <n>:<glob>     glob matched against the n-th argument (0 is the executable, -1 the last argument)
+//   <n>:re:<regex> regular expression matched against the n-th argument
+//   *:<glob>       glob matched against any argument
+//   *:re:<regex>   regular expression matched against any argument
+//
+// Globs support `*` (any characters, including path separators) and `?` (one character). Globs and regular expressions must match the complete command line or argument.
+
+const (
+	// CmdLineFingerprintType is the fingerprint type for command line fingerprints.
+	CmdLineFingerprintType = "cmdline"
+
+	cmdLineAllArgs   = "*"
+	cmdLineRegexMark = "re:"
+)
+
+var (
+	cmdLinePatternCache     = make(map[string]*cmdLinePattern)
+	cmdLinePatternCacheLock sync.Mutex
+)
+
+type cmdLinePattern struct {
+	// wholeCmdLine is set when the pattern applies to the whole command line.
+	wholeCmdLine bool
+	// anyArg is set when the pattern applies to any argument.
+	anyArg bool
+	// position is the argument position the pattern applies to, negative values count from the end.
+	position int
+
+	regex *regexp.Regexp
+}
+
+// ValidateCmdLineFingerprint checks whether the given value is a valid command line fingerprint value.
+func ValidateCmdLineFingerprint(value string) error {
+	_, err := getCmdLinePattern(value)
+	return err
+}
+
+// MatchesCmdLine returns whether the command line fingerprint matches the given command line arguments. _cmdLine_ is used for matching the whole command line, _args_ for position aware matching. If _args_ is empty, it is derived from _cmdLine_ by splitting on spaces.
+func (fp *Fingerprint) MatchesCmdLine(cmdLine string, args []string) bool {
+	if fp.Type != CmdLineFingerprintType {
+		return false
+	}
+
+	pattern, err := getCmdLinePattern(fp.Value)
+	if err != nil {
+		return false
+	}
+
+	if pattern.wholeCmdLine {
+		return pattern.regex.MatchString(cmdLine)
+	}
+
+	if len(args) == 0 {
+		args = strings.Fields(cmdLine)
+	}
+
+	if pattern.anyArg {
+		for _, arg := range args {
+			if pattern.regex.MatchString(arg) {
+				return true
+			}
+		}
+		return false
+	}
+
+	position := pattern.position
+	if position < 0 {
+		position += len(args)
+	}
+	if position < 0 || position >= len(args) {
+		return false
+	}
+	return pattern.regex.MatchString(args[position])
+}
+
+func getCmdLinePattern(value string) (*cmdLinePattern, error) {
+	cmdLinePatternCacheLock.Lock()
+	defer cmdLinePatternCacheLock.Unlock()
+
+	pattern, ok := cmdLinePatternCache[value]
+	if ok {
+		return pattern, nil
+	}
+
+	pattern, err := parseCmdLinePattern(value)
+	if err != nil {
+		return nil, err
+	}
+	cmdLinePatternCache[value] = pattern
+	return pattern, nil
+}
+
+func parseCmdLinePattern(value string) (*cmdLinePattern, error) {
+	if value == "" {
+		return nil, errors.New("empty command line fingerprint")
+	}
+
+	pattern := &cmdLinePattern{}
+	expression := value
+
+	// check for argument position
+	splitted := strings.SplitN(value, ":", 2)
+	switch {
+	case len(splitted) == 2 && splitted[0] == cmdLineAllArgs:
+		pattern.anyArg = true
+		expression = splitted[1]
+	case len(splitted) == 2 && isArgPosition(splitted[0]):
+		position, err := strconv.Atoi(splitted[0])
+		if err != nil {
+			return nil, err
+		}
+		pattern.position = position
+		expression = splitted[1]
+	default:
+		pattern.wholeCmdLine = true
+	}
+
+	// compile expression
+	var err error
+	if strings.HasPrefix(expression, cmdLineRegexMark) {
+		pattern.regex, err = regexp.Compile("^(?:" + strings.TrimPrefix(expression, cmdLineRegexMark) + ")$")
+	} else {
+		pattern.regex, err = regexp.Compile(globToRegex(expression))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pattern, nil
+}
+
+func isArgPosition(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, c := range glob {
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
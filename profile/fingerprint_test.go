@@ -0,0 +1,46 @@
+package profile
+
+import (
+	"testing"
+)
+
+func TestCmdLineFingerprint(t *testing.T) {
+	testCases := []struct {
+		value    string
+		cmdLine  string
+		args     []string
+		expected bool
+	}{
+		{"java -jar *a.jar", "java -jar /opt/app/a.jar", nil, true},
+		{"java -jar *a.jar", "java -jar /opt/app/b.jar", nil, false},
+		{"re:java .*-jar \\S+/a\\.jar", "java -Xmx1g -jar /opt/app/a.jar", nil, true},
+		{"2:*a.jar", "java -jar /opt/app/a.jar", []string{"java", "-jar", "/opt/app/a.jar"}, true},
+		{"1:*a.jar", "java -jar /opt/app/a.jar", []string{"java", "-jar", "/opt/app/a.jar"}, false},
+		{"-1:/opt/app?/*", "electron /opt/app1/main.js", nil, true},
+		{"-1:/opt/app?/*", "electron /opt/app1/main.js --flag", nil, false},
+		{"5:anything", "electron /opt/app1/main.js", nil, false},
+		{"*:--enable-?", "app --enable-x --other", nil, true},
+		{"*:re:--profile=(work|home)", "app --profile=home", nil, true},
+		{"*:re:--profile=(work|home)", "app --profile=school", nil, false},
+		{"0:re:(", "app", nil, false},
+	}
+
+	for _, tc := range testCases {
+		fp := &Fingerprint{
+			Type:  CmdLineFingerprintType,
+			Value: tc.value,
+		}
+		if fp.MatchesCmdLine(tc.cmdLine, tc.args) != tc.expected {
+			t.Errorf("unexpected result for fingerprint %s on %s: expected %v", tc.value, tc.cmdLine, tc.expected)
+		}
+	}
+
+	if ValidateCmdLineFingerprint("re:(") == nil {
+		t.Error("invalid regex should fail validation")
+	}
+
+	weight := GetFingerprintWeight(CmdLineFingerprintType)
+	if weight <= GetFingerprintWeight("partial_path") || weight >= GetFingerprintWeight("md5_sum") {
+		t.Errorf("unexpected weight of command line fingerprint: %d", weight)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/Safing/portbase/database/query"
 	"github.com/Safing/portbase/database/record"
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 
 	"github.com/Safing/portmaster/profile"
 )
@@ -23,7 +23,7 @@ var (
 )
 
 func init() {
-	modules.Register("profile:index", nil, start, stop, "profile", "database")
+	modstatus.Register("profile:index", nil, start, stop, "profile", "database")
 }
 
 func start() (err error) {
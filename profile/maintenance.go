@@ -0,0 +1,371 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/database/query"
+	"github.com/Safing/portbase/log"
+)
+
+var (
+	maintenanceInterval     = 24 * time.Hour
+	firstMaintenanceDelay   = 10 * time.Minute
+	staleProfileDefaultDays = 90
+)
+
+// ErrInvalidUnusedDays is returned when stale profiles are requested for less than one unused day, which would make every inactive profile stale.
+var ErrInvalidUnusedDays = errors.New("unused days must be greater than 0")
+
+// StaleProfile describes a user profile that is not needed anymore, either because its executable does not exist anymore or because it was not used for a long time.
+type StaleProfile struct {
+	ID          string
+	Name        string
+	LinkedPath  string
+	LastUsed    int64
+	PathMissing bool
+	Unused      bool
+}
+
+// Profile Maintenance Actions
+const (
+	StaleActionReport  = "report"
+	StaleActionArchive = "archive"
+	StaleActionDelete  = "delete"
+)
+
+func maintenanceWorker() {
+	select {
+	case <-shutdownSignal:
+		return
+	case <-time.After(firstMaintenanceDelay):
+	}
+
+	for {
+		action := StaleActionReport
+		if archiveStaleProfiles() {
+			action = StaleActionArchive
+		}
+		// the check is disabled with 0 days
+		days := int(staleProfileDays())
+		if days > 0 {
+			staleProfiles, err := CleanStaleProfiles(days, action)
+			if err != nil {
+				log.Warningf("profile: failed to clean stale profiles: %s", err)
+			} else if len(staleProfiles) > 0 {
+				log.Infof("profile: found %d stale profiles (action: %s)", len(staleProfiles), action)
+			}
+		}
+
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(maintenanceInterval):
+		}
+	}
+}
+
+// FindStaleProfiles returns all user profiles whose linked executable does not exist anymore or that were not used for the given amount of days. Profiles that are currently in use are never stale.
+func FindStaleProfiles(unusedDays int) ([]*StaleProfile, error) {
+	if unusedDays <= 0 {
+		return nil, ErrInvalidUnusedDays
+	}
+
+	it, err := profileDB.Query(query.New(MakeProfileKey(UserNamespace, "")))
+	if err != nil {
+		return nil, err
+	}
+
+	unusedThreshold := time.Now().Add(-time.Duration(unusedDays) * 24 * time.Hour).Unix()
+	var staleProfiles []*StaleProfile
+	for r := range it.Next {
+		profile, err := EnsureProfile(r)
+		if err != nil {
+			log.Warningf("profile: failed to parse profile %s: %s", r.Key(), err)
+			continue
+		}
+
+		if isActiveProfile(profile.ID) {
+			continue
+		}
+
+		stale := checkStaleProfile(profile, unusedThreshold, pathExists)
+		if stale != nil {
+			staleProfiles = append(staleProfiles, stale)
+		}
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return staleProfiles, nil
+}
+
+func checkStaleProfile(profile *Profile, unusedThreshold int64, exists func(string) bool) *StaleProfile {
+	profile.Lock()
+	defer profile.Unlock()
+
+	lastUsed := profile.ApproxLastUsed
+	if lastUsed == 0 {
+		lastUsed = profile.Created
+	}
+
+	stale := &StaleProfile{
+		ID:         profile.ID,
+		Name:       profile.Name,
+		LinkedPath: profile.LinkedPath,
+		LastUsed:   lastUsed,
+		Unused:     lastUsed < unusedThreshold,
+	}
+	if profile.LinkedPath != "" && !exists(profile.LinkedPath) {
+		stale.PathMissing = true
+	}
+
+	if !stale.PathMissing && !stale.Unused {
+		return nil
+	}
+	return stale
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+// CleanStaleProfiles finds all stale profiles and archives or deletes them, according to the given action. It returns the stale profiles found.
+func CleanStaleProfiles(unusedDays int, action string) ([]*StaleProfile, error) {
+	if unusedDays <= 0 {
+		return nil, ErrInvalidUnusedDays
+	}
+
+	var actionFn func(string) error
+	switch action {
+	case StaleActionReport:
+	case StaleActionArchive:
+		actionFn = ArchiveUserProfile
+	case StaleActionDelete:
+		actionFn = DeleteUserProfile
+	default:
+		return nil, fmt.Errorf("unknown action: %s", action)
+	}
+
+	staleProfiles, err := FindStaleProfiles(unusedDays)
+	if err != nil {
+		return nil, err
+	}
+	if actionFn == nil {
+		return staleProfiles, nil
+	}
+
+	for _, stale := range staleProfiles {
+		err := actionFn(stale.ID)
+		if err != nil {
+			return staleProfiles, fmt.Errorf("failed to %s profile %s: %s", action, stale.ID, err)
+		}
+		log.Infof("profile: %s stale profile %s (%s)", pastTense(action), stale.ID, stale.LinkedPath)
+	}
+
+	return staleProfiles, nil
+}
+
+func pastTense(action string) string {
+	switch action {
+	case StaleActionArchive:
+		return "archived"
+	case StaleActionDelete:
+		return "deleted"
+	default:
+		return action
+	}
+}
+
+// DeleteUserProfile deletes the user profile with the given ID.
+func DeleteUserProfile(ID string) error {
+	if isActiveProfile(ID) {
+		return errors.New("profile is in use")
+	}
+
+	profile, err := GetUserProfile(ID)
+	if err != nil {
+		return err
+	}
+
+	profile.Lock()
+	profile.Meta().Delete()
+	profile.Unlock()
+	return profileDB.Put(profile)
+}
+
+// ArchiveUserProfile moves the user profile with the given ID to the archive, where it is not used for matching processes anymore.
+func ArchiveUserProfile(ID string) error {
+	if isActiveProfile(ID) {
+		return errors.New("profile is in use")
+	}
+
+	profile, err := GetUserProfile(ID)
+	if err != nil {
+		return err
+	}
+
+	// save to archive
+	profile.Lock()
+	profile.SetKey(MakeProfileKey(ArchiveNamespace, profile.ID))
+	profile.Unlock()
+	err = profileDB.Put(profile)
+	if err != nil {
+		return err
+	}
+
+	return DeleteUserProfile(ID)
+}
+
+// RestoreUserProfile moves the archived profile with the given ID back to the user profiles.
+func RestoreUserProfile(ID string) error {
+	switch _, err := GetUserProfile(ID); err {
+	case nil:
+		return errors.New("a user profile with this ID already exists")
+	case database.ErrNotFound:
+	default:
+		return err
+	}
+
+	profile, err := getProfile(ArchiveNamespace, ID)
+	if err != nil {
+		return err
+	}
+
+	// save to user profiles
+	profile.Lock()
+	profile.SetKey(MakeProfileKey(UserNamespace, profile.ID))
+	// do not archive the profile again right away
+	profile.ApproxLastUsed = time.Now().Unix()
+	profile.Unlock()
+	err = profileDB.Put(profile)
+	if err != nil {
+		return err
+	}
+
+	// remove from archive
+	archived, err := getProfile(ArchiveNamespace, ID)
+	if err != nil {
+		return err
+	}
+	archived.Lock()
+	archived.Meta().Delete()
+	archived.Unlock()
+	return profileDB.Put(archived)
+}
+
+// MergeUserProfiles merges the source profile into the target profile, eg. after an application moved to a new location. Endpoints, fingerprints and flags of the source are added to the target, if the target does not define them itself. The source profile is archived afterwards.
+func MergeUserProfiles(targetID, sourceID string) (*Profile, error) {
+	if targetID == sourceID {
+		return nil, errors.New("cannot merge profile with itself")
+	}
+
+	target, err := GetUserProfile(targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target profile: %s", err)
+	}
+	source, err := GetUserProfile(sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source profile: %s", err)
+	}
+
+	target.Lock()
+	source.Lock()
+	mergeProfiles(target, source)
+	source.Unlock()
+	target.Unlock()
+
+	err = target.Save(UserNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save target profile: %s", err)
+	}
+
+	// the source may still be in use, only archive it if it is not
+	if isActiveProfile(sourceID) {
+		log.Infof("profile: merged %s into %s, not archiving %s as it is in use", sourceID, targetID, sourceID)
+		return target, nil
+	}
+	err = ArchiveUserProfile(sourceID)
+	if err != nil {
+		return target, fmt.Errorf("merged, but failed to archive source profile: %s", err)
+	}
+
+	log.Infof("profile: merged %s into %s", sourceID, targetID)
+	return target, nil
+}
+
+// mergeProfiles merges source into target. Both profiles must be locked.
+func mergeProfiles(target, source *Profile) {
+	// endpoints of the target take precedence, as they are checked first
+	target.Endpoints = mergeEndpoints(target.Endpoints, source.Endpoints)
+	target.ServiceEndpoints = mergeEndpoints(target.ServiceEndpoints, source.ServiceEndpoints)
+
+	// fingerprints
+	for _, fp := range source.Fingerprints {
+		if fp == nil || hasFingerprint(target.Fingerprints, fp) {
+			continue
+		}
+		target.Fingerprints = append(target.Fingerprints, fp)
+	}
+
+	// flags
+	if target.Flags == nil && len(source.Flags) > 0 {
+		target.Flags = make(Flags)
+	}
+	for flag, levels := range source.Flags {
+		if _, ok := target.Flags[flag]; !ok {
+			target.Flags[flag] = levels
+		}
+	}
+
+	// security level
+	if source.SecurityLevel > target.SecurityLevel {
+		target.SecurityLevel = source.SecurityLevel
+	}
+
+	// usage
+	if source.Created > 0 && source.Created < target.Created {
+		target.Created = source.Created
+	}
+	if source.ApproxLastUsed > target.ApproxLastUsed {
+		target.ApproxLastUsed = source.ApproxLastUsed
+	}
+}
+
+func mergeEndpoints(target, source Endpoints) Endpoints {
+	existing := make(map[string]struct{})
+	for _, ep := range target {
+		if ep != nil {
+			existing[ep.String()] = struct{}{}
+		}
+	}
+
+	for _, ep := range source {
+		if ep == nil {
+			continue
+		}
+		if _, ok := existing[ep.String()]; ok {
+			continue
+		}
+		existing[ep.String()] = struct{}{}
+		target = append(target, ep)
+	}
+	return target
+}
+
+func hasFingerprint(fps []*Fingerprint, fp *Fingerprint) bool {
+	for _, existing := range fps {
+		if existing != nil &&
+			existing.OS == fp.OS &&
+			existing.Type == fp.Type &&
+			existing.Value == fp.Value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package profile
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStaleProfile(t *testing.T) {
+	threshold := time.Now().Add(-90 * 24 * time.Hour).Unix()
+	exists := func(path string) bool {
+		return path == "/usr/bin/existing"
+	}
+
+	recent := &Profile{ID: "recent", LinkedPath: "/usr/bin/existing", ApproxLastUsed: time.Now().Unix()}
+	if checkStaleProfile(recent, threshold, exists) != nil {
+		t.Error("recently used profile should not be stale")
+	}
+
+	old := &Profile{ID: "old", LinkedPath: "/usr/bin/existing", Created: time.Now().Add(-100 * 24 * time.Hour).Unix()}
+	stale := checkStaleProfile(old, threshold, exists)
+	if stale == nil || !stale.Unused || stale.PathMissing {
+		t.Errorf("unused profile should be stale: %+v", stale)
+	}
+
+	missing := &Profile{ID: "missing", LinkedPath: "/usr/bin/removed", ApproxLastUsed: time.Now().Unix()}
+	stale = checkStaleProfile(missing, threshold, exists)
+	if stale == nil || stale.Unused || !stale.PathMissing {
+		t.Errorf("profile with missing executable should be stale: %+v", stale)
+	}
+}
+
+func TestInvalidUnusedDays(t *testing.T) {
+	for _, days := range []int{0, -1} {
+		if _, err := FindStaleProfiles(days); err != ErrInvalidUnusedDays {
+			t.Errorf("finding stale profiles with %d days should fail, got %v", days, err)
+		}
+		if _, err := CleanStaleProfiles(days, StaleActionDelete); err != ErrInvalidUnusedDays {
+			t.Errorf("cleaning stale profiles with %d days should fail, got %v", days, err)
+		}
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	target := &Profile{
+		ID:      "target",
+		Created: 200,
+		Flags: Flags{
+			Internet: 7,
+		},
+		Endpoints: Endpoints{
+			&EndpointPermission{Type: EptDomain, Value: "example.com.", Permit: true},
+		},
+		Fingerprints: []*Fingerprint{
+			&Fingerprint{OS: "lin", Type: "full_path", Value: "/opt/app/new"},
+		},
+	}
+	source := &Profile{
+		ID:             "source",
+		Created:        100,
+		ApproxLastUsed: 300,
+		SecurityLevel:  2,
+		Flags: Flags{
+			Internet: 1,
+			LAN:      7,
+		},
+		Endpoints: Endpoints{
+			&EndpointPermission{Type: EptDomain, Value: "example.com.", Permit: false},
+			&EndpointPermission{Type: EptDomain, Value: "example.org.", Permit: true},
+		},
+		ServiceEndpoints: Endpoints{
+			&EndpointPermission{Type: EptAny, Protocol: 6, StartPort: 8080, EndPort: 8080, Permit: true},
+		},
+		Fingerprints: []*Fingerprint{
+			&Fingerprint{OS: "lin", Type: "full_path", Value: "/opt/app/new"},
+			&Fingerprint{OS: "lin", Type: "full_path", Value: "/opt/app/old"},
+		},
+	}
+
+	mergeProfiles(target, source)
+
+	if target.Endpoints.String() != "[Domain:example.com. */*, Domain:example.org. */*]" || !target.Endpoints[0].Permit {
+		t.Errorf("unexpected endpoints: %s", target.Endpoints)
+	}
+	if len(target.ServiceEndpoints) != 1 {
+		t.Errorf("unexpected service endpoints: %s", target.ServiceEndpoints)
+	}
+	if len(target.Fingerprints) != 2 {
+		t.Errorf("unexpected fingerprint count: %d", len(target.Fingerprints))
+	}
+	if target.Flags[Internet] != 7 || target.Flags[LAN] != 7 {
+		t.Errorf("unexpected flags: %s", target.Flags)
+	}
+	if target.SecurityLevel != 2 || target.Created != 100 || target.ApproxLastUsed != 300 {
+		t.Errorf("unexpected metadata: sl=%d created=%d lastUsed=%d", target.SecurityLevel, target.Created, target.ApproxLastUsed)
+	}
+}
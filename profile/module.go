@@ -1,7 +1,7 @@
 package profile
 
 import (
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 
 	// module dependencies
 	_ "github.com/Safing/portmaster/core"
@@ -12,7 +12,15 @@ var (
 )
 
 func init() {
-	modules.Register("profile", nil, start, stop, "core")
+	modstatus.Register("profile", prep, start, stop, "core", "api")
+}
+
+func prep() error {
+	err := registerConfig()
+	if err != nil {
+		return err
+	}
+	return registerAPI()
 }
 
 func start() error {
@@ -20,6 +28,9 @@ func start() error {
 	if err != nil {
 		return err
 	}
+
+	go maintenanceWorker()
+
 	return initUpdateListener()
 }
 
@@ -33,6 +33,16 @@ type Profile struct {
 	StampProfileID       string
 	StampProfileAssigned int64
 
+	// Executable holds information about the executable at LinkedPath, as it was when it was last accepted.
+	Executable *ExecutableInfo
+	// A quarantined profile is denied all network access, eg. because its executable changed unexpectedly.
+	Quarantined      bool
+	QuarantineReason string
+	// AwaitingConfirmation is set while the profile is quarantined until the user decides about a changed executable.
+	AwaitingConfirmation bool
+	// verifyingExecutable counts the checks that are currently hashing a possibly changed executable.
+	verifyingExecutable int
+
 	// Fingerprints
 	Fingerprints []*Fingerprint
 
@@ -23,8 +23,13 @@ type Set struct {
 	// Stamp
 	// Default
 
+	// network is an optional overlay selected by the connected network, it takes precedence over all other profiles.
+	network *Profile
+
 	combinedSecurityLevel uint8
 	independent           bool
+
+	ancestry Ancestry
 }
 
 // NewSet returns a new profile set with given the profiles.
@@ -48,6 +53,32 @@ func (set *Set) UserProfile() *Profile {
 	return set.profiles[0]
 }
 
+// SetAncestry sets the parent chain of the process the profile set belongs to. It is used to evaluate ancestry conditions of endpoint permissions.
+func (set *Set) SetAncestry(ancestry Ancestry) {
+	set.Lock()
+	defer set.Unlock()
+
+	set.ancestry = ancestry
+}
+
+// Quarantined returns whether the user profile is quarantined and why.
+func (set *Set) Quarantined() (quarantined bool, reason string) {
+	set.Lock()
+	defer set.Unlock()
+
+	userProfile := set.profiles[0]
+	if userProfile == nil {
+		return false, ""
+	}
+
+	userProfile.Lock()
+	defer userProfile.Unlock()
+	if userProfile.verifyingExecutable > 0 && !userProfile.Quarantined {
+		return true, "verifying changed executable"
+	}
+	return userProfile.Quarantined, userProfile.QuarantineReason
+}
+
 // Update gets the new global and default profile and updates the independence status. It must be called when reusing a profile set for a series of calls.
 func (set *Set) Update(securityLevel uint8) {
 	set.Lock()
@@ -58,6 +89,7 @@ func (set *Set) Update(securityLevel uint8) {
 	// update profiles
 	set.profiles[1] = globalProfile
 	set.profiles[3] = fallbackProfile
+	set.network = networkProfile
 
 	// update security level
 	profileSecurityLevel := set.getSecurityLevel()
@@ -107,6 +139,12 @@ func (set *Set) CheckFlag(flag uint8) (active bool) {
 	set.Lock()
 	defer set.Unlock()
 
+	if set.network != nil {
+		if active, ok := set.network.Flags.Check(flag, set.combinedSecurityLevel); ok {
+			return active
+		}
+	}
+
 	for i, profile := range set.profiles {
 		if i == 2 && set.independent {
 			continue
@@ -128,13 +166,19 @@ func (set *Set) CheckEndpointDomain(domain string) (result EPResult, reason stri
 	set.Lock()
 	defer set.Unlock()
 
+	if set.network != nil {
+		if result, reason = set.network.Endpoints.CheckDomain(domain, set.ancestry); result != NoMatch {
+			return
+		}
+	}
+
 	for i, profile := range set.profiles {
 		if i == 2 && set.independent {
 			continue
 		}
 
 		if profile != nil {
-			if result, reason = profile.Endpoints.CheckDomain(domain); result != NoMatch {
+			if result, reason = profile.Endpoints.CheckDomain(domain, set.ancestry); result != NoMatch {
 				return
 			}
 		}
@@ -148,6 +192,17 @@ func (set *Set) CheckEndpointIP(domain string, ip net.IP, protocol uint8, port u
 	set.Lock()
 	defer set.Unlock()
 
+	if set.network != nil {
+		if inbound {
+			result, reason = set.network.ServiceEndpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel, set.ancestry)
+		} else {
+			result, reason = set.network.Endpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel, set.ancestry)
+		}
+		if result != NoMatch {
+			return
+		}
+	}
+
 	for i, profile := range set.profiles {
 		if i == 2 && set.independent {
 			continue
@@ -155,11 +210,11 @@ func (set *Set) CheckEndpointIP(domain string, ip net.IP, protocol uint8, port u
 
 		if profile != nil {
 			if inbound {
-				if result, reason = profile.ServiceEndpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel); result != NoMatch {
+				if result, reason = profile.ServiceEndpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel, set.ancestry); result != NoMatch {
 					return
 				}
 			} else {
-				if result, reason = profile.Endpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel); result != NoMatch {
+				if result, reason = profile.Endpoints.CheckIP(domain, ip, protocol, port, inbound, set.combinedSecurityLevel, set.ancestry); result != NoMatch {
 					return
 				}
 			}
@@ -175,6 +230,10 @@ func (set *Set) getSecurityLevel() uint8 {
 		return 0
 	}
 
+	if set.network != nil && set.network.SecurityLevel > 0 {
+		return set.network.SecurityLevel
+	}
+
 	for i, profile := range set.profiles {
 		if i == 2 {
 			// Stamp profiles do not have the SecurityLevel setting
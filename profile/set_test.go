@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -140,7 +141,7 @@ func testEndpointIP(t *testing.T, set *Set, domain string, ip net.IP, protocol u
 
 func TestProfileSet(t *testing.T) {
 
-	set := NewSet("[pid]-/path/to/bin", testUserProfile, testStampProfile)
+	set := NewSet(context.Background(), "[pid]-/path/to/bin", testUserProfile, testStampProfile)
 
 	set.Update(status.SecurityLevelDynamic)
 	testFlag(t, set, Whitelist, false)
@@ -170,3 +171,40 @@ func TestProfileSet(t *testing.T) {
 	testEndpointIP(t, set, "", net.ParseIP("10.2.3.4"), 6, 80, false, NoMatch)
 	testEndpointDomain(t, set, "bad2.example.com.", Undeterminable)
 }
+
+func TestNetworkProfile(t *testing.T) {
+	set := NewSet(context.Background(), "[pid]-/path/to/other/bin", testUserProfile, testStampProfile)
+	defer DeactivateProfileSet(set)
+
+	specialProfileLock.Lock()
+	networkProfile = &Profile{
+		ID:            "unit-test-network",
+		Name:          "Unit Test Network Profile",
+		SecurityLevel: status.SecurityLevelSecure,
+		Flags: map[uint8]uint8{
+			LAN: status.SecurityLevelFortress,
+		},
+		Endpoints: []*EndpointPermission{
+			&EndpointPermission{
+				Type:    EptDomain,
+				Value:   "example.com.",
+				Permit:  false,
+				Created: time.Now().Unix(),
+			},
+		},
+	}
+	specialProfileLock.Unlock()
+	defer func() {
+		specialProfileLock.Lock()
+		networkProfile = nil
+		specialProfileLock.Unlock()
+	}()
+
+	set.Update(status.SecurityLevelDynamic)
+	if set.SecurityLevel() != status.SecurityLevelSecure {
+		t.Errorf("network profile security level not applied: %s", status.FmtSecurityLevel(set.SecurityLevel()))
+	}
+	testFlag(t, set, LAN, false)
+	testEndpointDomain(t, set, "example.com.", Denied)
+	testEndpointDomain(t, set, "good.bad.example.com.", Permitted)
+}
@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/Safing/portbase/database"
@@ -9,6 +10,8 @@ import (
 var (
 	globalProfile   *Profile
 	fallbackProfile *Profile
+	// networkProfile is an optional overlay selected by the connected network.
+	networkProfile *Profile
 
 	specialProfileLock sync.RWMutex
 )
@@ -45,6 +48,36 @@ func getSpecialProfile(ID string) (*Profile, error) {
 	return getProfile(SpecialNamespace, ID)
 }
 
+// SetNetworkProfile activates the special profile with the given ID as an overlay for all profile sets, eg. to tighten settings in public networks. An empty ID removes the overlay.
+func SetNetworkProfile(ID string) error {
+	var profile *Profile
+	if ID != "" {
+		var err error
+		profile, err = getSpecialProfile(ID)
+		if err != nil {
+			return fmt.Errorf("failed to load network profile %s: %s", ID, err)
+		}
+	}
+
+	specialProfileLock.Lock()
+	networkProfile = profile
+	specialProfileLock.Unlock()
+
+	increaseUpdateVersion()
+	return nil
+}
+
+// updateNetworkProfile replaces the active network profile, if it has the same ID.
+func updateNetworkProfile(profile *Profile) {
+	specialProfileLock.Lock()
+	defer specialProfileLock.Unlock()
+
+	if networkProfile != nil && networkProfile.ID == profile.ID {
+		networkProfile = profile
+		increaseUpdateVersion()
+	}
+}
+
 func ensureServiceEndpointsDenyAll(p *Profile) (changed bool) {
 	for _, ep := range p.ServiceEndpoints {
 		if ep != nil {
@@ -67,3 +100,26 @@ func ensureServiceEndpointsDenyAll(p *Profile) (changed bool) {
 	})
 	return true
 }
+
+// IsPermittedByGlobalProfile returns whether the given domain is explicitly permitted by a domain endpoint in the global profile. Catch-all endpoints are not considered.
+func IsPermittedByGlobalProfile(domain string) bool {
+	specialProfileLock.RLock()
+	defer specialProfileLock.RUnlock()
+
+	if globalProfile == nil {
+		return false
+	}
+
+	globalProfile.Lock()
+	defer globalProfile.Unlock()
+
+	for _, ep := range globalProfile.Endpoints {
+		if ep == nil || ep.Type != EptDomain {
+			continue
+		}
+		if result, _ := ep.MatchesDomain(domain); result != NoMatch {
+			return result == Permitted
+		}
+	}
+	return false
+}
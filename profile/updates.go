@@ -63,6 +63,8 @@ func updateListener(sub *database.Subscription) {
 			default:
 
 				switch {
+				case strings.HasPrefix(profile.Key(), MakeProfileKey(SpecialNamespace, "")):
+					updateNetworkProfile(profile)
 				case strings.HasPrefix(profile.Key(), MakeProfileKey(UserNamespace, "")):
 					updateActiveProfile(profile, true /* User Profile */)
 				case strings.HasPrefix(profile.Key(), MakeProfileKey(StampNamespace, "")):
@@ -0,0 +1,30 @@
+package status
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Safing/portbase/api"
+	"github.com/Safing/portmaster/internal/apiutil"
+)
+
+func registerAPI() error {
+	api.RegisterHandleFunc("/api/status/v1/threats/{id:.+}/dismiss", apiutil.Protect(handleDismissThreat)).Methods("POST")
+	return nil
+}
+
+func handleDismissThreat(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	reason := r.URL.Query().Get("reason")
+
+	err := DismissThreat(id, "api:"+r.RemoteAddr, reason)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusOK)
+	case ErrThreatNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,125 @@
+package status
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/database/record"
+	"github.com/Safing/portbase/log"
+)
+
+const (
+	threatHistoryDBKey = "core:status/threathistory"
+
+	maxThreatHistoryEntries = 100
+	maxThreatAuditEntries   = 100
+)
+
+var (
+	history         *ThreatHistory
+	historySaveLock sync.Mutex
+)
+
+func init() {
+	history = &ThreatHistory{}
+	history.SetKey(threatHistoryDBKey)
+}
+
+// ThreatHistory holds ended threats and an audit log of user actions regarding threats.
+type ThreatHistory struct {
+	record.Base
+	sync.Mutex
+
+	Threats []*Threat
+	Audit   []*ThreatAuditEntry
+}
+
+// ThreatAuditEntry records an action a user took regarding a threat.
+type ThreatAuditEntry struct {
+	Time       int64
+	ThreatID   string
+	ThreatName string
+	Action     string
+	Actor      string
+	Reason     string
+}
+
+// Save saves the ThreatHistory to the database
+func (h *ThreatHistory) Save() {
+	historySaveLock.Lock()
+	defer historySaveLock.Unlock()
+
+	// save a copy taken under lock, as the history is modified concurrently
+	h.Lock()
+	snapshot := &ThreatHistory{
+		Threats: append([]*Threat(nil), h.Threats...),
+		Audit:   append([]*ThreatAuditEntry(nil), h.Audit...),
+	}
+	h.Unlock()
+	snapshot.SetKey(threatHistoryDBKey)
+
+	err := statusDB.Put(snapshot)
+	if err != nil {
+		log.Errorf("status: could not save threat history to database: %s", err)
+	}
+}
+
+// EnsureThreatHistory ensures that the given record is of type ThreatHistory and unwraps it, if needed.
+func EnsureThreatHistory(r record.Record) (*ThreatHistory, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &ThreatHistory{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*ThreatHistory)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *ThreatHistory, but %T", r)
+	}
+	return new, nil
+}
+
+func loadThreatHistory() {
+	r, err := statusDB.Get(threatHistoryDBKey)
+	switch err {
+	case nil:
+		loadedHistory, err := EnsureThreatHistory(r)
+		if err != nil {
+			log.Warningf("status: failed to unwrap threat history: %s", err)
+			return
+		}
+		history = loadedHistory
+	case database.ErrNotFound:
+	default:
+		log.Warningf("status: failed to load threat history: %s", err)
+	}
+}
+
+func addThreatToHistory(threat *Threat) {
+	history.Lock()
+	history.Threats = append(history.Threats, threat)
+	if len(history.Threats) > maxThreatHistoryEntries {
+		history.Threats = history.Threats[len(history.Threats)-maxThreatHistoryEntries:]
+	}
+	history.Unlock()
+
+	go history.Save()
+}
+
+func addThreatAuditEntry(entry *ThreatAuditEntry) {
+	history.Lock()
+	history.Audit = append(history.Audit, entry)
+	if len(history.Audit) > maxThreatAuditEntries {
+		history.Audit = history.Audit[len(history.Audit)-maxThreatAuditEntries:]
+	}
+	history.Unlock()
+
+	go history.Save()
+}
@@ -1,9 +1,11 @@
 package status
 
 import (
+	"time"
+
 	"github.com/Safing/portbase/database"
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 
 	// module dependencies
 	_ "github.com/Safing/portmaster/core"
@@ -14,7 +16,11 @@ var (
 )
 
 func init() {
-	modules.Register("status", nil, start, stop, "core")
+	modstatus.Register("status", prep, start, stop, "core", "api")
+}
+
+func prep() error {
+	return registerAPI()
 }
 
 func start() error {
@@ -42,6 +48,26 @@ func start() error {
 	status.Lock()
 	defer status.Unlock()
 
+	if status.Threats == nil {
+		status.Threats = make(map[string]*Threat)
+	}
+	if status.DismissedThreats == nil {
+		status.DismissedThreats = make(map[string]int64)
+	}
+	for id, dismissedUntil := range status.DismissedThreats {
+		if dismissedUntil <= time.Now().Unix() {
+			delete(status.DismissedThreats, id)
+		}
+	}
+
+	// loaded threats must be confirmed again by their modules
+	for _, threat := range status.Threats {
+		if threat.Expires == 0 {
+			threat.Expires = time.Now().Add(DefaultThreatTTL).Unix()
+		}
+	}
+	loadThreatHistory()
+
 	// load status into atomic getters
 	atomicUpdateSelectedSecurityLevel(status.SelectedSecurityLevel)
 	atomicUpdatePortmasterStatus(status.PortmasterStatus)
@@ -52,6 +78,7 @@ func start() error {
 	status.autopilot()
 
 	go status.Save()
+	go threatLifecycleWorker()
 
 	return initStatusHook()
 }
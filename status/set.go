@@ -16,15 +16,16 @@ func (s *SystemStatus) autopilot() {
 	}
 
 	// update active security level
-	switch s.ThreatMitigationLevel {
+	level := max(s.ThreatMitigationLevel, s.NetworkSecurityLevel)
+	switch level {
 	case SecurityLevelOff:
 		s.ActiveSecurityLevel = SecurityLevelDynamic
 		atomicUpdateActiveSecurityLevel(SecurityLevelDynamic)
 	case SecurityLevelDynamic, SecurityLevelSecure, SecurityLevelFortress:
-		s.ActiveSecurityLevel = s.ThreatMitigationLevel
-		atomicUpdateActiveSecurityLevel(s.ThreatMitigationLevel)
+		s.ActiveSecurityLevel = level
+		atomicUpdateActiveSecurityLevel(level)
 	default:
-		log.Errorf("status: threat mitigation level is set to invalid value: %d", s.ThreatMitigationLevel)
+		log.Errorf("status: threat mitigation or network security level is set to invalid value: %d", level)
 	}
 }
 
@@ -45,6 +46,25 @@ func setSelectedSecurityLevel(level uint8) {
 	}
 }
 
+// SetNetworkSecurityLevel sets the minimum security level required by the currently connected network. It is applied when the user did not select a security level.
+func SetNetworkSecurityLevel(level uint8) {
+	switch level {
+	case SecurityLevelOff, SecurityLevelDynamic, SecurityLevelSecure, SecurityLevelFortress:
+		status.Lock()
+		defer status.Unlock()
+
+		if status.NetworkSecurityLevel == level {
+			return
+		}
+		status.NetworkSecurityLevel = level
+		status.autopilot()
+
+		go status.Save()
+	default:
+		log.Errorf("status: tried to set network security level to invalid value: %d", level)
+	}
+}
+
 // SetPortmasterStatus sets the current Portmaster status.
 func SetPortmasterStatus(pmStatus uint8, msg string) {
 	switch pmStatus {
@@ -62,6 +82,21 @@ func SetPortmasterStatus(pmStatus uint8, msg string) {
 	}
 }
 
+// ClearPortmasterStatus resets the Portmaster status to ok, if it is still set with the given message. This way a status can be reverted without clearing a status that was set by something else in the meantime.
+func ClearPortmasterStatus(msg string) {
+	status.Lock()
+	defer status.Unlock()
+
+	if status.PortmasterStatusMsg != msg {
+		return
+	}
+	status.PortmasterStatus = StatusOk
+	status.PortmasterStatusMsg = ""
+	atomicUpdatePortmasterStatus(StatusOk)
+
+	go status.Save()
+}
+
 // SetGate17Status sets the current Gate17 status.
 func SetGate17Status(g17Status uint8, msg string) {
 	switch g17Status {
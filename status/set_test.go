@@ -11,3 +11,15 @@ func TestSet(t *testing.T) {
 	SetGate17Status(0, "")
 
 }
+
+func TestClearPortmasterStatus(t *testing.T) {
+	SetPortmasterStatus(StatusWarning, "first warning")
+	ClearPortmasterStatus("other warning")
+	if PortmasterStatus() != StatusWarning {
+		t.Error("status set by someone else must not be cleared")
+	}
+	ClearPortmasterStatus("first warning")
+	if PortmasterStatus() != StatusOk {
+		t.Error("status should be cleared")
+	}
+}
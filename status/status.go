@@ -14,7 +14,8 @@ var (
 
 func init() {
 	status = &SystemStatus{
-		Threats: make(map[string]*Threat),
+		Threats:          make(map[string]*Threat),
+		DismissedThreats: make(map[string]int64),
 	}
 	status.SetKey(statusDBKey)
 }
@@ -36,6 +37,13 @@ type SystemStatus struct {
 	ThreatMitigationLevel uint8
 	Threats               map[string]*Threat
 
+	// NetworkSecurityLevel is the minimum security level required by the currently connected network.
+	NetworkSecurityLevel uint8
+
+	decayingThreats []*Threat
+	// DismissedThreats maps the IDs of threats dismissed by the user to when they may be reported again.
+	DismissedThreats map[string]int64
+
 	UpdateStatus string
 }
 
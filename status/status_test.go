@@ -32,3 +32,29 @@ func TestStatus(t *testing.T) {
 	}
 
 }
+
+func TestNetworkSecurityLevel(t *testing.T) {
+	setSelectedSecurityLevel(SecurityLevelOff)
+	status.Lock()
+	status.Threats = make(map[string]*Threat)
+	status.decayingThreats = nil
+	status.updateThreatMitigationLevel()
+	status.Unlock()
+
+	SetNetworkSecurityLevel(SecurityLevelSecure)
+	if ActiveSecurityLevel() != SecurityLevelSecure {
+		t.Errorf("network security level not applied, active level is %s", FmtSecurityLevel(ActiveSecurityLevel()))
+	}
+
+	// user selection overrules the network
+	setSelectedSecurityLevel(SecurityLevelDynamic)
+	if ActiveSecurityLevel() != SecurityLevelDynamic {
+		t.Errorf("selected security level not applied, active level is %s", FmtSecurityLevel(ActiveSecurityLevel()))
+	}
+
+	setSelectedSecurityLevel(SecurityLevelOff)
+	SetNetworkSecurityLevel(SecurityLevelOff)
+	if ActiveSecurityLevel() != SecurityLevelDynamic {
+		t.Errorf("expected fallback to dynamic, active level is %s", FmtSecurityLevel(ActiveSecurityLevel()))
+	}
+}
@@ -1,8 +1,33 @@
 package status
 
 import (
+	"errors"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/Safing/portbase/log"
+)
+
+// Threat End Reasons
+const (
+	ThreatResolved  = "resolved"
+	ThreatExpired   = "expired"
+	ThreatDismissed = "dismissed"
+)
+
+var (
+	// DefaultThreatTTL is the time after which a threat expires, if it is not refreshed by the reporting module.
+	DefaultThreatTTL = 1 * time.Hour
+
+	// threatDecayStep is the time after which the mitigation level of an ended threat is lowered by one level.
+	threatDecayStep = 10 * time.Minute
+
+	// dismissedThreatTTL defines for how long a dismissed threat is not reported again, unless it is deleted by the reporting module.
+	dismissedThreatTTL = 24 * time.Hour
+
+	// ErrThreatNotFound is returned when a threat does not exist.
+	ErrThreatNotFound = errors.New("threat not found")
 )
 
 // Threat describes a detected threat.
@@ -14,13 +39,50 @@ type Threat struct {
 	MitigationLevel uint8       // Recommended Security Level to switch to for mitigation
 	Started         int64
 	Ended           int64
+	Expires         int64  // The threat is ended automatically at this time, unless refreshed.
+	EndReason       string // Why the threat ended: resolved, expired or dismissed.
 }
 
-// AddOrUpdateThreat adds or updates a new threat in the system status.
+// decayedMitigationLevel returns the mitigation level of an ended threat, which is lowered step by step after the threat ended.
+func (t *Threat) decayedMitigationLevel(now int64) uint8 {
+	if t.Ended == 0 || t.EndReason == ThreatDismissed {
+		return SecurityLevelOff
+	}
+	steps := uint((now - t.Ended) / int64(threatDecayStep/time.Second))
+	// every step moves the level down: Fortress -> Secure -> Dynamic -> Off
+	if steps >= 3 {
+		return SecurityLevelOff
+	}
+	return t.MitigationLevel >> (steps + 1)
+}
+
+// AddOrUpdateThreat adds or updates a new threat in the system status. If the threat does not define when it expires, it expires after the DefaultThreatTTL.
 func AddOrUpdateThreat(new *Threat) {
 	status.Lock()
 	defer status.Unlock()
 
+	now := time.Now()
+
+	// respect dismissal by user
+	if dismissedUntil, ok := status.DismissedThreats[new.ID]; ok {
+		if dismissedUntil > now.Unix() {
+			log.Tracef("status: ignoring update of dismissed threat %s", new.ID)
+			return
+		}
+		delete(status.DismissedThreats, new.ID)
+	}
+
+	// keep start time of existing threat
+	if existing, ok := status.Threats[new.ID]; ok && new.Started == 0 {
+		new.Started = existing.Started
+	}
+	if new.Started == 0 {
+		new.Started = now.Unix()
+	}
+	if new.Expires == 0 {
+		new.Expires = now.Add(DefaultThreatTTL).Unix()
+	}
+
 	status.Threats[new.ID] = new
 	status.updateThreatMitigationLevel()
 	status.autopilot()
@@ -28,18 +90,82 @@ func AddOrUpdateThreat(new *Threat) {
 	go status.Save()
 }
 
-// DeleteThreat deletes a threat from the system status.
+// RefreshThreat extends the lifetime of an existing threat by the given TTL, or DefaultThreatTTL if 0. It returns false if the threat does not exist (anymore).
+func RefreshThreat(id string, ttl time.Duration) (ok bool) {
+	status.Lock()
+	defer status.Unlock()
+
+	threat, ok := status.Threats[id]
+	if !ok {
+		return false
+	}
+
+	if ttl == 0 {
+		ttl = DefaultThreatTTL
+	}
+	threat.Expires = time.Now().Add(ttl).Unix()
+	return true
+}
+
+// DeleteThreat deletes a threat from the system status. This marks the threat as resolved.
 func DeleteThreat(id string) {
 	status.Lock()
 	defer status.Unlock()
 
-	delete(status.Threats, id)
+	// the situation was resolved, a new occurrence must be reported again
+	delete(status.DismissedThreats, id)
+
+	threat, ok := status.Threats[id]
+	if !ok {
+		return
+	}
+	status.endThreat(threat, ThreatResolved, time.Now().Unix())
+
 	status.updateThreatMitigationLevel()
 	status.autopilot()
 
 	go status.Save()
 }
 
+// DismissThreat ends a threat on behalf of the user and records it in the audit log. Its mitigation level is removed immediately and it will not be reported again until the reporting module resolves it.
+func DismissThreat(id, actor, reason string) error {
+	status.Lock()
+	defer status.Unlock()
+
+	threat, ok := status.Threats[id]
+	if !ok {
+		return ErrThreatNotFound
+	}
+
+	now := time.Now().Unix()
+	status.DismissedThreats[id] = time.Now().Add(dismissedThreatTTL).Unix()
+	status.endThreat(threat, ThreatDismissed, now)
+	addThreatAuditEntry(&ThreatAuditEntry{
+		Time:       now,
+		ThreatID:   threat.ID,
+		ThreatName: threat.Name,
+		Action:     ThreatDismissed,
+		Actor:      actor,
+		Reason:     reason,
+	})
+	log.Infof("status: threat %s was dismissed by %s: %s", id, actor, reason)
+
+	status.updateThreatMitigationLevel()
+	status.autopilot()
+
+	go status.Save()
+	return nil
+}
+
+// IsThreatDismissed returns whether the threat with the given ID was dismissed by the user and is currently not reported again.
+func IsThreatDismissed(id string) bool {
+	status.Lock()
+	defer status.Unlock()
+
+	dismissedUntil, ok := status.DismissedThreats[id]
+	return ok && dismissedUntil > time.Now().Unix()
+}
+
 // GetThreats returns all threats who's IDs are prefixed by the given string, and also a locker for editing them.
 func GetThreats(idPrefix string) ([]*Threat, sync.Locker) {
 	status.Lock()
@@ -55,6 +181,48 @@ func GetThreats(idPrefix string) ([]*Threat, sync.Locker) {
 	return exportedThreats, &status.Mutex
 }
 
+// endThreat removes the threat from the active threats, and adds it to the history and the decaying threats. The status must be locked.
+func (s *SystemStatus) endThreat(threat *Threat, reason string, now int64) {
+	delete(s.Threats, threat.ID)
+
+	threat.Ended = now
+	threat.EndReason = reason
+	addThreatToHistory(threat)
+
+	if reason != ThreatDismissed && threat.MitigationLevel > SecurityLevelDynamic {
+		s.decayingThreats = append(s.decayingThreats, threat)
+	}
+}
+
+// checkThreatLifecycle ends expired threats and lets ended threats decay. It returns whether the status changed. The status must be locked.
+func (s *SystemStatus) checkThreatLifecycle(now int64) (changed bool) {
+	for _, threat := range s.Threats {
+		if threat.Expires > 0 && threat.Expires < now {
+			log.Infof("status: threat %s expired", threat.ID)
+			s.endThreat(threat, ThreatExpired, now)
+			changed = true
+		}
+	}
+
+	// remove fully decayed threats
+	remaining := s.decayingThreats[:0]
+	for _, threat := range s.decayingThreats {
+		if threat.decayedMitigationLevel(now) > SecurityLevelOff {
+			remaining = append(remaining, threat)
+		}
+	}
+	s.decayingThreats = remaining
+
+	previousLevel := s.ThreatMitigationLevel
+	s.updateThreatMitigationLevel()
+	if s.ThreatMitigationLevel != previousLevel {
+		s.autopilot()
+		changed = true
+	}
+
+	return changed
+}
+
 func (s *SystemStatus) updateThreatMitigationLevel() {
 	// get highest mitigationLevel
 	var mitigationLevel uint8
@@ -67,6 +235,32 @@ func (s *SystemStatus) updateThreatMitigationLevel() {
 		}
 	}
 
+	// ended threats keep a lowered mitigation level for some time
+	now := time.Now().Unix()
+	for _, threat := range s.decayingThreats {
+		decayedLevel := threat.decayedMitigationLevel(now)
+		if decayedLevel > mitigationLevel {
+			mitigationLevel = decayedLevel
+		}
+	}
+
 	// set new ThreatMitigationLevel
 	s.ThreatMitigationLevel = mitigationLevel
 }
+
+func threatLifecycleWorker() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(10 * time.Second):
+			status.Lock()
+			changed := status.checkThreatLifecycle(time.Now().Unix())
+			status.Unlock()
+
+			if changed {
+				go status.Save()
+			}
+		}
+	}
+}
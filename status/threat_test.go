@@ -0,0 +1,128 @@
+package status
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestThreatLifecycle(t *testing.T) {
+	setSelectedSecurityLevel(SecurityLevelOff)
+	now := time.Now().Unix()
+
+	// expiry
+	AddOrUpdateThreat(&Threat{
+		ID:              "test-expiring",
+		MitigationLevel: SecurityLevelFortress,
+	})
+	if ActiveSecurityLevel() != SecurityLevelFortress {
+		t.Fatalf("unexpected active security level: %d", ActiveSecurityLevel())
+	}
+	if !RefreshThreat("test-expiring", time.Minute) {
+		t.Fatal("refreshing an active threat should succeed")
+	}
+
+	status.Lock()
+	status.checkThreatLifecycle(now + 120)
+	_, stillActive := status.Threats["test-expiring"]
+	status.Unlock()
+	if stillActive {
+		t.Fatal("threat should have expired")
+	}
+	if RefreshThreat("test-expiring", time.Minute) {
+		t.Fatal("refreshing an ended threat should fail")
+	}
+
+	// decay
+	decayStep := int64(threatDecayStep / time.Second)
+	testDecay := func(at int64, expected uint8) {
+		status.Lock()
+		defer status.Unlock()
+		status.checkThreatLifecycle(at)
+		for _, threat := range status.decayingThreats {
+			if level := threat.decayedMitigationLevel(at); level != expected {
+				t.Errorf("unexpected decayed level after %ds: %d, expected %d", at-now, level, expected)
+			}
+		}
+	}
+	testDecay(now+120, SecurityLevelSecure)
+	testDecay(now+120+decayStep, SecurityLevelDynamic)
+	testDecay(now+120+2*decayStep, SecurityLevelOff)
+	status.Lock()
+	decaying := len(status.decayingThreats)
+	status.Unlock()
+	if decaying != 0 {
+		t.Errorf("fully decayed threats should be removed, %d left", decaying)
+	}
+
+	// history
+	history.Lock()
+	lastEnded := history.Threats[len(history.Threats)-1]
+	history.Unlock()
+	if lastEnded.ID != "test-expiring" || lastEnded.EndReason != ThreatExpired || lastEnded.Ended == 0 {
+		t.Errorf("unexpected history entry: %+v", lastEnded)
+	}
+}
+
+func TestDismissThreat(t *testing.T) {
+	setSelectedSecurityLevel(SecurityLevelOff)
+
+	AddOrUpdateThreat(&Threat{
+		ID:              "test-dismiss",
+		MitigationLevel: SecurityLevelSecure,
+	})
+	if err := DismissThreat("test-dismiss", "unit-test", "false positive"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DismissThreat("test-dismiss", "unit-test", "again"); err != ErrThreatNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// dismissed threats do not decay and are not reported again
+	AddOrUpdateThreat(&Threat{
+		ID:              "test-dismiss",
+		MitigationLevel: SecurityLevelSecure,
+	})
+	threats, _ := GetThreats("test-dismiss")
+	if len(threats) != 0 {
+		t.Error("dismissed threat should not be reported again")
+	}
+	if !IsThreatDismissed("test-dismiss") {
+		t.Error("threat should be dismissed")
+	}
+
+	// the dismissal is saved with the status
+	status.Lock()
+	data, err := json.Marshal(status)
+	status.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &SystemStatus{}
+	err = json.Unmarshal(data, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.DismissedThreats["test-dismiss"]; !ok {
+		t.Error("dismissal should be saved with the status")
+	}
+
+	// resolving the threat lifts the dismissal
+	DeleteThreat("test-dismiss")
+	AddOrUpdateThreat(&Threat{
+		ID:              "test-dismiss",
+		MitigationLevel: SecurityLevelSecure,
+	})
+	threats, _ = GetThreats("test-dismiss")
+	if len(threats) != 1 {
+		t.Error("threat should be reported again after it was resolved")
+	}
+	DeleteThreat("test-dismiss")
+
+	history.Lock()
+	lastAudit := history.Audit[len(history.Audit)-1]
+	history.Unlock()
+	if lastAudit.ThreatID != "test-dismiss" || lastAudit.Action != ThreatDismissed || lastAudit.Actor != "unit-test" || lastAudit.Reason != "false positive" {
+		t.Errorf("unexpected audit entry: %+v", lastAudit)
+	}
+}
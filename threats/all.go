@@ -2,5 +2,8 @@ package threats
 
 import (
 	_ "github.com/Safing/portmaster/threats/arp"
+	_ "github.com/Safing/portmaster/threats/beacon"
+	_ "github.com/Safing/portmaster/threats/dga"
+	_ "github.com/Safing/portmaster/threats/dnstunnel"
 	_ "github.com/Safing/portmaster/threats/portscan"
 )
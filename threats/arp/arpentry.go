@@ -1,7 +1,64 @@
 package arp
 
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// arpFlagComplete is set on entries with a resolved MAC address.
+	arpFlagComplete = 0x2
+
+	emptyMAC = "00:00:00:00:00:00"
+)
+
 type arpEntry struct {
 	IP        string
+	Flags     string
 	MAC       string
 	Interface string
 }
+
+// complete returns whether the entry holds a resolved MAC address.
+func (e *arpEntry) complete() bool {
+	if e.MAC == "" || e.MAC == emptyMAC {
+		return false
+	}
+	if e.Flags == "" {
+		return true
+	}
+
+	var flags uint64
+	_, err := fmt.Sscanf(e.Flags, "0x%x", &flags)
+	if err != nil {
+		return true
+	}
+	return flags&arpFlagComplete > 0
+}
+
+// parseArpTable parses an ARP table in the format of /proc/net/arp.
+func parseArpTable(arpData io.Reader) (table []*arpEntry, err error) {
+	// file scanner
+	scanner := bufio.NewScanner(arpData)
+	scanner.Split(bufio.ScanLines)
+
+	// parse
+	scanner.Scan() // skip first line
+	for scanner.Scan() {
+		line := strings.Fields(scanner.Text())
+		if len(line) < 6 {
+			continue
+		}
+
+		table = append(table, &arpEntry{
+			IP:        line[0],
+			Flags:     line[2],
+			MAC:       strings.ToLower(line[3]),
+			Interface: line[5],
+		})
+	}
+
+	return table, scanner.Err()
+}
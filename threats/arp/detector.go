@@ -0,0 +1,216 @@
+package arp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+const (
+	threatIDPrefix           = "arp-"
+	gatewayChangedIDPrefix   = threatIDPrefix + "gateway-changed-"
+	duplicateMACThreatPrefix = threatIDPrefix + "duplicate-mac-"
+)
+
+var (
+	// bindingTTL defines how long a binding is remembered after it was last seen.
+	bindingTTL = 24 * time.Hour
+	// threatTTL defines how long a reported threat lives without being confirmed by another check.
+	threatTTL = 5 * time.Minute
+	// gatewayRebindDelay defines how long a new MAC address of a gateway must stay unchanged until it is accepted, eg. after the router was replaced.
+	gatewayRebindDelay = 1 * time.Hour
+)
+
+// binding is an IP to MAC binding observed in the ARP table.
+type binding struct {
+	MAC         string
+	PreviousMAC string
+	FirstSeen   int64
+	LastSeen    int64
+	Changes     int
+
+	// NewMAC is the MAC address a gateway changed to and NewMACSince is when it was first seen, without changing since.
+	NewMAC      string
+	NewMACSince int64
+}
+
+// detector tracks IP to MAC bindings and detects signs of ARP spoofing.
+type detector struct {
+	sync.Mutex
+
+	bindings map[string]*binding
+	threats  map[string]*status.Threat
+
+	reporter reporter.Reporter
+}
+
+func newDetector() *detector {
+	return &detector{
+		bindings: make(map[string]*binding),
+		threats:  make(map[string]*status.Threat),
+		reporter: reporter.Status,
+	}
+}
+
+// reset forgets all bindings and clears all threats, eg. when the network changed.
+func (d *detector) reset() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.bindings = make(map[string]*binding)
+	for id := range d.threats {
+		d.reporter.Delete(id)
+	}
+	d.threats = make(map[string]*status.Threat)
+}
+
+// check updates the bindings with the given ARP table and raises or clears threats accordingly.
+func (d *detector) check(table []*arpEntry, gateways []string, now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	isGateway := make(map[string]bool)
+	for _, gw := range gateways {
+		isGateway[gw] = true
+	}
+
+	current := make(map[string]*status.Threat)
+	macs := make(map[string][]string)
+
+	for _, entry := range table {
+		if !entry.complete() {
+			continue
+		}
+		macs[entry.MAC] = append(macs[entry.MAC], entry.IP)
+
+		b, ok := d.bindings[entry.IP]
+		if !ok {
+			d.bindings[entry.IP] = &binding{
+				MAC:       entry.MAC,
+				FirstSeen: now.Unix(),
+				LastSeen:  now.Unix(),
+			}
+			continue
+		}
+
+		if b.MAC == entry.MAC {
+			b.LastSeen = now.Unix()
+			b.NewMAC = ""
+			b.NewMACSince = 0
+			continue
+		}
+
+		// binding changed
+		if isGateway[entry.IP] {
+			threatID := gatewayChangedIDPrefix + entry.IP
+			if b.NewMAC != entry.MAC {
+				b.NewMAC = entry.MAC
+				b.NewMACSince = now.Unix()
+			}
+
+			// keep the established binding of gateways, so that we notice when it returns to normal, unless the new MAC address is stable or the user dismissed the warning
+			if now.Sub(time.Unix(b.NewMACSince, 0)) < gatewayRebindDelay && !d.reporter.Dismissed(threatID) {
+				threat := &status.Threat{
+					ID:   threatID,
+					Name: "Gateway MAC Address Changed",
+					Description: fmt.Sprintf(
+						"The MAC address of your gateway %s changed from %s to %s. Another device in your network might be intercepting your traffic (ARP spoofing).",
+						entry.IP, b.MAC, entry.MAC,
+					),
+					AdditionalData: map[string]string{
+						"IP":          entry.IP,
+						"Interface":   entry.Interface,
+						"ExpectedMAC": b.MAC,
+						"CurrentMAC":  entry.MAC,
+					},
+					MitigationLevel: status.SecurityLevelSecure,
+				}
+				current[threat.ID] = threat
+				continue
+			}
+			log.Infof("threats/arp: accepting new MAC address %s of gateway %s (was %s)", entry.MAC, entry.IP, b.MAC)
+		}
+
+		b.PreviousMAC = b.MAC
+		b.MAC = entry.MAC
+		b.LastSeen = now.Unix()
+		b.Changes++
+		b.NewMAC = ""
+		b.NewMACSince = 0
+	}
+
+	// check for MACs claiming multiple IPs
+	for mac, ips := range macs {
+		if len(ips) < 2 {
+			continue
+		}
+		sort.Strings(ips)
+
+		mitigationLevel := status.SecurityLevelDynamic
+		for _, ip := range ips {
+			if isGateway[ip] {
+				mitigationLevel = status.SecurityLevelSecure
+				break
+			}
+		}
+
+		threat := &status.Threat{
+			ID:   duplicateMACThreatPrefix + mac,
+			Name: "Duplicate MAC Address",
+			Description: fmt.Sprintf(
+				"The device with the MAC address %s claims the IP addresses %s. It might be impersonating other devices in your network (ARP spoofing).",
+				mac, strings.Join(ips, ", "),
+			),
+			AdditionalData: map[string]string{
+				"MAC": mac,
+				"IPs": strings.Join(ips, ","),
+			},
+			MitigationLevel: mitigationLevel,
+		}
+		current[threat.ID] = threat
+	}
+
+	// forget old bindings
+	for ip, b := range d.bindings {
+		if !isGateway[ip] && now.Sub(time.Unix(b.LastSeen, 0)) > bindingTTL {
+			delete(d.bindings, ip)
+		}
+	}
+
+	d.updateThreats(current, now)
+}
+
+// updateThreats reports new and changed threats and clears threats that are not present anymore. The detector must be locked.
+func (d *detector) updateThreats(current map[string]*status.Threat, now time.Time) {
+	for id, threat := range current {
+		existing, ok := d.threats[id]
+		if ok {
+			// confirm unchanged threats
+			if existing.Description == threat.Description &&
+				existing.MitigationLevel == threat.MitigationLevel &&
+				d.reporter.Refresh(id, threatTTL) {
+				continue
+			}
+			threat.Started = existing.Started
+		} else {
+			threat.Started = now.Unix()
+		}
+
+		threat.Expires = now.Add(threatTTL).Unix()
+		d.threats[id] = threat
+		d.reporter.Add(threat)
+	}
+
+	for id := range d.threats {
+		if _, ok := current[id]; !ok {
+			delete(d.threats, id)
+			d.reporter.Delete(id)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package arp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+func loadFixture(t *testing.T, name string) []*arpEntry {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	table, err := parseArpTable(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func newTestDetector() (*detector, map[string]*status.Threat) {
+	recorder := reporter.NewRecorder()
+	d := newDetector()
+	d.reporter = recorder
+	return d, recorder.Threats
+}
+
+func TestParseArpTable(t *testing.T) {
+	table := loadFixture(t, "normal.txt")
+	if len(table) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(table))
+	}
+	if table[0].IP != "192.168.1.1" || table[0].MAC != "a0:b1:c2:d3:e4:f5" || table[0].Interface != "wlan0" {
+		t.Errorf("unexpected entry: %+v", table[0])
+	}
+	if !table[0].complete() || table[2].complete() {
+		t.Error("unexpected completeness of entries")
+	}
+}
+
+func TestGatewayMACChange(t *testing.T) {
+	d, reported := newTestDetector()
+	gateways := []string{"192.168.1.1"}
+	now := time.Now()
+
+	d.check(loadFixture(t, "normal.txt"), gateways, now)
+	if len(reported) != 0 {
+		t.Fatalf("expected no threats, got %d", len(reported))
+	}
+
+	d.check(loadFixture(t, "spoofed.txt"), gateways, now.Add(10*time.Second))
+	changed, ok := reported[gatewayChangedIDPrefix+"192.168.1.1"]
+	if !ok {
+		t.Fatal("expected gateway change to be reported")
+	}
+	if changed.MitigationLevel != status.SecurityLevelSecure {
+		t.Errorf("unexpected mitigation level: %d", changed.MitigationLevel)
+	}
+	duplicate, ok := reported[duplicateMACThreatPrefix+"de:ad:be:ef:00:01"]
+	if !ok {
+		t.Fatal("expected duplicate MAC to be reported")
+	}
+	if duplicate.MitigationLevel != status.SecurityLevelSecure {
+		t.Errorf("unexpected mitigation level: %d", duplicate.MitigationLevel)
+	}
+
+	// still spoofed, threat must persist with the same start time
+	started := changed.Started
+	d.check(loadFixture(t, "spoofed.txt"), gateways, now.Add(20*time.Second))
+	if reported[gatewayChangedIDPrefix+"192.168.1.1"].Started != started {
+		t.Error("start time of persisting threat changed")
+	}
+
+	// binding returns to normal
+	d.check(loadFixture(t, "normal.txt"), gateways, now.Add(30*time.Second))
+	if len(reported) != 0 {
+		t.Fatalf("expected threats to be cleared, got %d", len(reported))
+	}
+}
+
+func TestGatewayReplaced(t *testing.T) {
+	gateways := []string{"192.168.1.1"}
+	threatID := gatewayChangedIDPrefix + "192.168.1.1"
+	oldRouter := []*arpEntry{{IP: "192.168.1.1", MAC: "a0:b1:c2:d3:e4:f5", Interface: "wlan0"}}
+	newRouter := []*arpEntry{{IP: "192.168.1.1", MAC: "00:11:22:33:44:55", Interface: "wlan0"}}
+	now := time.Now()
+
+	// the new MAC address is accepted after it stayed unchanged for a while
+	d, reported := newTestDetector()
+	d.check(oldRouter, gateways, now)
+	d.check(newRouter, gateways, now.Add(time.Minute))
+	if _, ok := reported[threatID]; !ok {
+		t.Fatal("expected gateway change to be reported")
+	}
+	d.check(newRouter, gateways, now.Add(time.Minute+gatewayRebindDelay))
+	if len(reported) != 0 {
+		t.Errorf("expected stable new gateway MAC to be accepted, got %d threats", len(reported))
+	}
+	d.check(oldRouter, gateways, now.Add(2*time.Minute+gatewayRebindDelay))
+	if _, ok := reported[threatID]; !ok {
+		t.Error("expected change from the accepted gateway MAC to be reported")
+	}
+
+	// the new MAC address is accepted when the user dismissed the threat
+	d, reported = newTestDetector()
+	recorder := d.reporter.(*reporter.Recorder)
+	d.check(oldRouter, gateways, now)
+	d.check(newRouter, gateways, now.Add(time.Minute))
+	recorder.Dismiss(threatID)
+	d.check(newRouter, gateways, now.Add(2*time.Minute))
+	if len(reported) != 0 || recorder.Dismissed(threatID) {
+		t.Errorf("expected dismissed gateway change to be resolved, got %d threats", len(reported))
+	}
+	d.check(oldRouter, gateways, now.Add(3*time.Minute))
+	if _, ok := reported[threatID]; !ok {
+		t.Error("expected change from the accepted gateway MAC to be reported")
+	}
+}
+
+func TestDuplicateMAC(t *testing.T) {
+	d, reported := newTestDetector()
+	gateways := []string{"192.168.1.1"}
+	now := time.Now()
+
+	d.check(loadFixture(t, "duplicate.txt"), gateways, now)
+	duplicate, ok := reported[duplicateMACThreatPrefix+"10:20:30:40:50:60"]
+	if !ok {
+		t.Fatal("expected duplicate MAC to be reported")
+	}
+	if duplicate.MitigationLevel != status.SecurityLevelDynamic {
+		t.Errorf("unexpected mitigation level: %d", duplicate.MitigationLevel)
+	}
+
+	d.check(loadFixture(t, "normal.txt"), gateways, now.Add(10*time.Second))
+	if len(reported) != 0 {
+		t.Fatalf("expected threats to be cleared, got %d", len(reported))
+	}
+
+	// network change
+	d.check(loadFixture(t, "duplicate.txt"), gateways, now.Add(20*time.Second))
+	d.reset()
+	if len(reported) != 0 {
+		t.Fatalf("expected threats to be cleared on reset, got %d", len(reported))
+	}
+}
@@ -0,0 +1,65 @@
+package arp
+
+import (
+	"time"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/network/environment"
+
+	// module dependencies
+	_ "github.com/Safing/portmaster/status"
+)
+
+var (
+	checkInterval = 10 * time.Second
+
+	arpDetector    = newDetector()
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("threats:arp", nil, start, stop, "status")
+}
+
+func start() error {
+	go checker()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	arpDetector.reset()
+	return nil
+}
+
+func checker() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-environment.NetworkChanged():
+			log.Debugf("threats/arp: network changed, resetting known bindings")
+			arpDetector.reset()
+		case <-time.After(checkInterval):
+			table, err := getArpTable()
+			if err != nil {
+				continue
+			}
+			arpDetector.check(table, getGateways(), time.Now())
+		}
+	}
+}
+
+func getGateways() []string {
+	var gateways []string
+	for _, gw := range environment.Gateways() {
+		if gw == nil {
+			continue
+		}
+		if ip4 := gw.To4(); ip4 != nil {
+			gateways = append(gateways, ip4.String())
+		}
+	}
+	return gateways
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package arp
+
+import (
+	"errors"
+)
+
+func getArpTable() (table []*arpEntry, err error) {
+	return nil, errors.New("reading the ARP table is not supported on this platform")
+}
+
+func clearArpTable() error {
+	return nil
+}
@@ -1,9 +1,7 @@
 package arp
 
 import (
-	"bufio"
 	"os"
-	"strings"
 
 	"github.com/Safing/portbase/log"
 )
@@ -21,26 +19,7 @@ func getArpTable() (table []*arpEntry, err error) {
 	}
 	defer arpData.Close()
 
-	// file scanner
-	scanner := bufio.NewScanner(arpData)
-	scanner.Split(bufio.ScanLines)
-
-	// parse
-	scanner.Scan() // skip first line
-	for scanner.Scan() {
-		line := strings.Fields(scanner.Text())
-		if len(line) < 6 {
-			continue
-		}
-
-		table = append(table, &arpEntry{
-			IP:        line[0],
-			MAC:       line[3],
-			Interface: line[5],
-		})
-	}
-
-	return table, nil
+	return parseArpTable(arpData)
 }
 
 func clearArpTable() error {
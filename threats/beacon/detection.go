@@ -0,0 +1,297 @@
+package beacon
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+const (
+	threatIDPrefix = "beacon-"
+)
+
+var (
+	// window defines how far back connections are considered.
+	window = 24 * time.Hour
+	// burstGap merges connections that were started within this duration into one event, as many programs open several connections at once.
+	burstGap int64 = 2 // seconds
+
+	minIntervals            = 6
+	fullConfidenceIntervals = 24
+	minInterval             = 10 * time.Second
+	maxInterval             = 6 * time.Hour
+
+	// maxJitter is the relative deviation of intervals at which a series is not regarded as periodic anymore.
+	maxJitter = 0.25
+	// timestampResolution is the resolution of link start times, which is subtracted from the measured deviation.
+	timestampResolution = 1.0 // seconds
+
+	scoreThreshold     = 60
+	highScoreThreshold = 90
+
+	// missedBeacons defines after how many missed intervals a beacon is regarded as stopped.
+	missedBeacons = 3
+	// threatRefreshTTL defines how long a reported threat lives without being confirmed by the next check.
+	threatRefreshTTL = 5 * time.Minute
+
+	maxEventsPerSeries = 1024
+	maxSeries          = 4096
+)
+
+// Beacon describes a process that was detected connecting to a destination in regular intervals.
+type Beacon struct {
+	Pid         int
+	ProcessName string
+	ProcessPath string
+	Destination string
+
+	Interval    int64 // seconds
+	Jitter      float64
+	Score       int
+	Connections int
+	FirstSeen   int64
+	LastSeen    int64
+}
+
+// connection is a single outbound connection as seen by the detector.
+type connection struct {
+	linkID      string
+	pid         int
+	processName string
+	processPath string
+	destination string
+	started     int64 // unix
+}
+
+type series struct {
+	pid         int
+	processName string
+	processPath string
+	destination string
+
+	events []int64 // unix, sorted
+
+	beacon          *Beacon
+	reported        bool
+	reportedEvents  int
+	reportedHighLvl bool
+}
+
+type detector struct {
+	sync.Mutex
+
+	series    map[string]*series
+	seenLinks map[string]int64 // key: link ID, value: start
+
+	isAllowed func(destination string) bool
+	reporter  reporter.Reporter
+}
+
+func newDetector() *detector {
+	return &detector{
+		series:    make(map[string]*series),
+		seenLinks: make(map[string]int64),
+		isAllowed: isAllowed,
+		reporter:  reporter.Status,
+	}
+}
+
+func seriesKey(processPath, destination string) string {
+	return processPath + " -> " + destination
+}
+
+// observe records an outbound connection. Connections that were already observed are ignored.
+func (d *detector) observe(conn *connection) {
+	if conn.started == 0 || conn.destination == "" {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if _, ok := d.seenLinks[conn.linkID]; ok {
+		return
+	}
+	d.seenLinks[conn.linkID] = conn.started
+
+	if d.isAllowed != nil && d.isAllowed(conn.destination) {
+		return
+	}
+
+	key := seriesKey(conn.processPath, conn.destination)
+	s, ok := d.series[key]
+	if !ok {
+		if len(d.series) >= maxSeries {
+			return
+		}
+		s = &series{
+			processPath: conn.processPath,
+			destination: conn.destination,
+		}
+		d.series[key] = s
+	}
+	// always name the latest process instance
+	s.pid = conn.pid
+	s.processName = conn.processName
+
+	// insert sorted, links are not observed in order
+	i := sort.Search(len(s.events), func(i int) bool {
+		return s.events[i] >= conn.started
+	})
+	s.events = append(s.events, 0)
+	copy(s.events[i+1:], s.events[i:])
+	s.events[i] = conn.started
+
+	if len(s.events) > maxEventsPerSeries {
+		s.events = s.events[len(s.events)-maxEventsPerSeries:]
+	}
+}
+
+// check analyzes all series, publishes detected beacons as threats and removes stale data.
+func (d *detector) check(now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	windowStart := now.Add(-window).Unix()
+	for linkID, started := range d.seenLinks {
+		if started < windowStart {
+			delete(d.seenLinks, linkID)
+		}
+	}
+
+	for key, s := range d.series {
+		s.analyze(now)
+
+		switch {
+		case s.beacon != nil:
+			d.report(s, now)
+		case s.reported:
+			d.reporter.Delete(s.threatID())
+			s.reported = false
+		}
+
+		if len(s.events) == 0 {
+			delete(d.series, key)
+		}
+	}
+}
+
+// analyze prunes old events and checks whether the series is a beacon.
+func (s *series) analyze(now time.Time) {
+	windowStart := now.Add(-window).Unix()
+	firstValid := 0
+	for firstValid < len(s.events) && s.events[firstValid] < windowStart {
+		firstValid++
+	}
+	s.events = s.events[firstValid:]
+	s.beacon = nil
+
+	// merge bursts
+	var starts []int64
+	for _, started := range s.events {
+		if len(starts) > 0 && started-starts[len(starts)-1] <= burstGap {
+			continue
+		}
+		starts = append(starts, started)
+	}
+	if len(starts) < minIntervals+1 {
+		return
+	}
+
+	intervals := make([]float64, 0, len(starts)-1)
+	var sum float64
+	for i := 1; i < len(starts); i++ {
+		interval := float64(starts[i] - starts[i-1])
+		intervals = append(intervals, interval)
+		sum += interval
+	}
+	mean := sum / float64(len(intervals))
+	if mean < minInterval.Seconds() || mean > maxInterval.Seconds() {
+		return
+	}
+
+	// check if the beacon stopped
+	lastSeen := starts[len(starts)-1]
+	if float64(now.Unix()-lastSeen) > mean*float64(missedBeacons) {
+		return
+	}
+
+	var variance float64
+	for _, interval := range intervals {
+		variance += (interval - mean) * (interval - mean)
+	}
+	deviation := math.Sqrt(variance / float64(len(intervals)))
+	jitter := math.Max(0, deviation-timestampResolution) / mean
+
+	score := beaconScore(jitter, len(intervals))
+	if score < scoreThreshold {
+		return
+	}
+
+	s.beacon = &Beacon{
+		Pid:         s.pid,
+		ProcessName: s.processName,
+		ProcessPath: s.processPath,
+		Destination: s.destination,
+		Interval:    int64(math.Round(mean)),
+		Jitter:      math.Round(jitter*1000) / 1000,
+		Score:       score,
+		Connections: len(s.events),
+		FirstSeen:   starts[0],
+		LastSeen:    lastSeen,
+	}
+}
+
+// beaconScore rates how likely a connection series is a beacon from 0 to 100. Regular intervals score high, more samples increase the confidence.
+func beaconScore(jitter float64, intervals int) int {
+	regularity := 1 - jitter/maxJitter
+	if regularity <= 0 {
+		return 0
+	}
+	confidence := math.Min(1, float64(intervals)/float64(fullConfidenceIntervals))
+	return int(100 * regularity * (0.5 + 0.5*confidence))
+}
+
+// report publishes or confirms the threat of a beacon. The detector must be locked.
+func (d *detector) report(s *series, now time.Time) {
+	highLvl := s.beacon.Score >= highScoreThreshold
+	if s.reported &&
+		s.reportedEvents == len(s.events) &&
+		s.reportedHighLvl == highLvl &&
+		d.reporter.Refresh(s.threatID(), threatRefreshTTL) {
+		return
+	}
+
+	mitigationLevel := status.SecurityLevelDynamic
+	if highLvl {
+		mitigationLevel = status.SecurityLevelSecure
+	}
+
+	d.reporter.Add(&status.Threat{
+		ID:   s.threatID(),
+		Name: fmt.Sprintf("Beaconing of %s", s.processName),
+		Description: fmt.Sprintf(
+			"%s (%s) connects to %s every %s with very regular timing, which is typical for malware contacting its command and control server.",
+			s.processName,
+			s.processPath,
+			s.destination,
+			time.Duration(s.beacon.Interval)*time.Second,
+		),
+		AdditionalData:  s.beacon,
+		MitigationLevel: mitigationLevel,
+		Started:         s.beacon.FirstSeen,
+		Expires:         now.Add(threatRefreshTTL).Unix(),
+	})
+	s.reported = true
+	s.reportedEvents = len(s.events)
+	s.reportedHighLvl = highLvl
+}
+
+func (s *series) threatID() string {
+	return threatIDPrefix + s.destination + "-" + s.processPath
+}
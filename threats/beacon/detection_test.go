@@ -0,0 +1,161 @@
+package beacon
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+func newTestDetector(allowed ...string) (*detector, map[string]*status.Threat) {
+	recorder := reporter.NewRecorder()
+	d := newDetector()
+	d.reporter = recorder
+	d.isAllowed = func(destination string) bool {
+		for _, entry := range allowed {
+			if entry == destination {
+				return true
+			}
+		}
+		return false
+	}
+	return d, recorder.Threats
+}
+
+// feed reports connections of the given process to the destination, starting at start, with the given intervals.
+func feed(d *detector, path, destination string, start time.Time, intervals []time.Duration) (last time.Time) {
+	last = start
+	for i := 0; i <= len(intervals); i++ {
+		if i > 0 {
+			last = last.Add(intervals[i-1])
+		}
+		d.observe(&connection{
+			linkID:      fmt.Sprintf("%s-%s-%d", path, destination, i),
+			pid:         42,
+			processName: "agent",
+			processPath: path,
+			destination: destination,
+			started:     last.Unix(),
+		})
+	}
+	return last
+}
+
+func regularIntervals(n int, interval time.Duration) []time.Duration {
+	intervals := make([]time.Duration, n)
+	for i := range intervals {
+		intervals[i] = interval
+	}
+	return intervals
+}
+
+func TestRegularBeacon(t *testing.T) {
+	d, reported := newTestDetector()
+	start := time.Now().Add(-2 * time.Hour)
+
+	// small jitter of up to a second
+	rng := rand.New(rand.NewSource(1))
+	intervals := regularIntervals(30, time.Minute)
+	for i := range intervals {
+		intervals[i] += time.Duration(rng.Intn(1000)) * time.Millisecond
+	}
+	last := feed(d, "/tmp/agent", "c2.example.com.", start, intervals)
+
+	d.check(last.Add(10 * time.Second))
+	threat, ok := reported[threatIDPrefix+"c2.example.com.-/tmp/agent"]
+	if !ok {
+		t.Fatalf("beacon not reported: %+v", reported)
+	}
+	beacon := threat.AdditionalData.(*Beacon)
+	if beacon.Interval < 59 || beacon.Interval > 61 {
+		t.Errorf("unexpected interval: %d", beacon.Interval)
+	}
+	if beacon.ProcessPath != "/tmp/agent" || beacon.Destination != "c2.example.com." {
+		t.Errorf("unexpected beacon: %+v", beacon)
+	}
+	if threat.MitigationLevel != status.SecurityLevelSecure {
+		t.Errorf("unexpected mitigation level for score %d: %d", beacon.Score, threat.MitigationLevel)
+	}
+
+	// beacon stops
+	d.check(last.Add(time.Duration(missedBeacons+1) * time.Minute))
+	if len(reported) != 0 {
+		t.Errorf("expected threat to be removed after beacon stopped, got %d threats", len(reported))
+	}
+}
+
+func TestIrregularConnections(t *testing.T) {
+	d, reported := newTestDetector()
+	start := time.Now().Add(-3 * time.Hour)
+
+	rng := rand.New(rand.NewSource(1))
+	intervals := make([]time.Duration, 40)
+	for i := range intervals {
+		intervals[i] = time.Duration(10+rng.Intn(600)) * time.Second
+	}
+	last := feed(d, "/usr/bin/browser", "news.example.com.", start, intervals)
+
+	d.check(last.Add(time.Second))
+	if len(reported) != 0 {
+		t.Errorf("irregular connections reported as beacon: %+v", reported)
+	}
+}
+
+func TestTooFewConnections(t *testing.T) {
+	d, reported := newTestDetector()
+	start := time.Now().Add(-time.Hour)
+
+	last := feed(d, "/tmp/agent", "c2.example.com.", start, regularIntervals(minIntervals-1, 5*time.Minute))
+	d.check(last.Add(time.Second))
+	if len(reported) != 0 {
+		t.Errorf("beacon reported with too few samples: %+v", reported)
+	}
+}
+
+func TestBurstsAreMerged(t *testing.T) {
+	d, reported := newTestDetector()
+	start := time.Now().Add(-time.Hour)
+
+	// three connections at once every two minutes
+	var intervals []time.Duration
+	for i := 0; i < 12; i++ {
+		intervals = append(intervals, 2*time.Minute, time.Second, time.Second)
+	}
+	last := feed(d, "/tmp/agent", "c2.example.com.", start, intervals[:len(intervals)-2])
+
+	d.check(last.Add(time.Second))
+	threat, ok := reported[threatIDPrefix+"c2.example.com.-/tmp/agent"]
+	if !ok {
+		t.Fatal("bursting beacon not reported")
+	}
+	if beacon := threat.AdditionalData.(*Beacon); beacon.Interval < 120 || beacon.Interval > 122 {
+		t.Errorf("unexpected interval: %d", beacon.Interval)
+	}
+}
+
+func TestAllowedDestination(t *testing.T) {
+	d, reported := newTestDetector("updates.example.com.")
+	start := time.Now().Add(-2 * time.Hour)
+
+	last := feed(d, "/usr/bin/updater", "updates.example.com.", start, regularIntervals(30, time.Minute))
+	d.check(last.Add(time.Second))
+	if len(reported) != 0 {
+		t.Errorf("allowed destination reported as beacon: %+v", reported)
+	}
+}
+
+func TestDuplicateLinks(t *testing.T) {
+	d, _ := newTestDetector()
+	start := time.Now()
+
+	feed(d, "/tmp/agent", "c2.example.com.", start, regularIntervals(5, time.Minute))
+	feed(d, "/tmp/agent", "c2.example.com.", start, regularIntervals(5, time.Minute))
+
+	s := d.series[seriesKey("/tmp/agent", "c2.example.com.")]
+	if len(s.events) != 6 {
+		t.Errorf("expected links to be observed only once, got %d events", len(s.events))
+	}
+}
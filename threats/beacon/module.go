@@ -0,0 +1,120 @@
+package beacon
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/network"
+	"github.com/Safing/portmaster/profile"
+
+	// module dependencies
+	_ "github.com/Safing/portmaster/status"
+)
+
+var (
+	checkInterval = 10 * time.Second
+
+	beaconDetector = newDetector()
+	// lastCollected holds the LastLinkEstablished value of every communication at the last collection.
+	lastCollected  = make(map[*network.Communication]int64)
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("threats:beacon", nil, start, stop, "network", "profile", "status")
+}
+
+func start() error {
+	go checker()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	return nil
+}
+
+func checker() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(checkInterval):
+			collectConnections()
+			beaconDetector.check(time.Now())
+		}
+	}
+}
+
+// collectConnections feeds all new outbound links of the network package to the detector. Links are kept for some minutes after they ended, so no link is missed between checks.
+func collectConnections() {
+	ownPid := os.Getpid()
+	collected := make(map[*network.Communication]int64)
+
+	for _, link := range network.GetLinks() {
+		comm := link.Communication()
+		if comm == nil {
+			continue
+		}
+
+		comm.Lock()
+		domain := comm.Domain
+		inbound := comm.Direction
+		lastLinkEstablished := comm.LastLinkEstablished
+		comm.Unlock()
+
+		if inbound {
+			continue
+		}
+		// skip communications without new links since the last collection
+		if lastLinkEstablished <= lastCollected[comm] {
+			collected[comm] = lastCollected[comm]
+			continue
+		}
+		collected[comm] = lastLinkEstablished
+
+		link.Lock()
+		linkID := link.ID
+		started := link.Started
+		remoteAddress := link.RemoteAddress
+		link.Unlock()
+
+		var destination string
+		switch domain {
+		case network.PeerInternet:
+			destination = remoteAddress
+		case network.PeerHost, network.PeerLAN, network.PeerInvalid,
+			network.IncomingHost, network.IncomingLAN, network.IncomingInternet, network.IncomingInvalid:
+			continue
+		default:
+			destination = domain
+		}
+
+		proc := comm.Process()
+		if proc == nil || proc.Pid == ownPid {
+			continue
+		}
+
+		beaconDetector.observe(&connection{
+			linkID:      linkID,
+			pid:         proc.Pid,
+			processName: proc.Name,
+			processPath: proc.Path,
+			destination: destination,
+			started:     started,
+		})
+	}
+
+	lastCollected = collected
+}
+
+// isAllowed returns whether connections to the given destination are known-good, because they are explicitly permitted in the global profile.
+func isAllowed(destination string) bool {
+	// only domains can be allowed
+	if strings.Contains(destination, " ") {
+		return false
+	}
+	return profile.IsPermittedByGlobalProfile(destination)
+}
@@ -0,0 +1,298 @@
+package dga
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Safing/portmaster/analytics/algs"
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+// Detection Types
+const (
+	DetectionDGA     uint8 = 1 << iota // many random-looking domains that do not exist
+	DetectionNXBurst                   // many domains that do not exist
+)
+
+var (
+	window = 10 * time.Minute
+	// threatTTL defines how long a detection is reported after it was last confirmed.
+	threatTTL     = 15 * time.Minute
+	blockDuration = 30 * time.Minute
+	// threatRefreshTTL defines how long a reported threat lives without being confirmed by the next check.
+	threatRefreshTTL = 5 * time.Minute
+
+	// randomNXThreshold defines how many distinct random-looking labels that do not exist must be queried to detect domain generation.
+	randomNXThreshold = 8
+	// nxBurstThreshold defines how many distinct domains that do not exist must be queried to detect a burst.
+	nxBurstThreshold = 50
+	// nxBurstRatio defines the minimum share of queries that must be answered with NXDOMAIN to detect a burst.
+	nxBurstRatio = 0.8
+
+	maxExamples         = 5
+	maxQueriesPerSource = 2048
+	maxSources          = 1024
+)
+
+// Detection describes a process that was detected querying generated or non-existent domains.
+type Detection struct {
+	Pid            int
+	ProcessName    string
+	ProcessPath    string
+	Queries        int
+	NXDomains      int
+	RandomNXLabels int
+	Examples       []string
+	FirstSeen      int64
+	LastSeen       int64
+	Blocked        bool
+}
+
+type query struct {
+	seen   int64 // unix nano
+	domain string
+	label  string
+	nx     bool
+	random bool
+}
+
+type source struct {
+	pid  int
+	name string
+	path string
+
+	queries []*query
+
+	// detected holds the time when a detection type was last confirmed.
+	detected     map[uint8]int64
+	firstSeen    int64
+	blockedUntil int64
+	stats        *Detection
+}
+
+type detector struct {
+	sync.Mutex
+
+	sources map[string]*source
+	// reported holds the number of NXDOMAIN queries of reported threats to detect changes.
+	reported map[string]int
+
+	getModel    func() *algs.NgramModel
+	shouldBlock func() bool
+	reporter    reporter.Reporter
+}
+
+func newDetector() *detector {
+	return &detector{
+		sources:  make(map[string]*source),
+		reported: make(map[string]int),
+		getModel: getModel,
+		shouldBlock: func() bool {
+			return blockProcessDNS(status.ActiveSecurityLevel())
+		},
+		reporter: reporter.Status,
+	}
+}
+
+// report records a DNS query of a process and checks the process for domain generation.
+func (d *detector) report(pid int, processName, processPath, fqdn string, nxDomain bool, now time.Time) {
+	label, random := isRandom(d.getModel(), fqdn)
+
+	d.Lock()
+	defer d.Unlock()
+
+	src, ok := d.sources[processPath]
+	if !ok {
+		if len(d.sources) >= maxSources {
+			return
+		}
+		src = &source{
+			path:      processPath,
+			detected:  make(map[uint8]int64),
+			firstSeen: now.Unix(),
+		}
+		d.sources[processPath] = src
+	}
+	src.pid = pid
+	src.name = processName
+
+	src.queries = append(src.queries, &query{
+		seen:   now.UnixNano(),
+		domain: fqdn,
+		label:  label,
+		nx:     nxDomain,
+		random: random,
+	})
+	if len(src.queries) > maxQueriesPerSource {
+		src.queries = src.queries[len(src.queries)-maxQueriesPerSource:]
+	}
+
+	// only NXDOMAIN responses can complete a detection
+	if !nxDomain {
+		return
+	}
+
+	detections := src.analyze(now)
+	if detections&DetectionDGA > 0 && src.blockedUntil <= now.Unix() && d.shouldBlock != nil && d.shouldBlock() {
+		src.blockedUntil = now.Add(blockDuration).Unix()
+	}
+}
+
+// analyze prunes old queries, updates the statistics and returns the detection types the source currently matches.
+func (src *source) analyze(now time.Time) (detections uint8) {
+	windowStart := now.Add(-window).UnixNano()
+	firstValid := 0
+	for firstValid < len(src.queries) && src.queries[firstValid].seen < windowStart {
+		firstValid++
+	}
+	src.queries = src.queries[firstValid:]
+
+	nxDomains := make(map[string]struct{})
+	randomNXLabels := make(map[string]struct{})
+	var examples []string
+	var nxQueries int
+	for _, q := range src.queries {
+		if !q.nx {
+			continue
+		}
+		nxQueries++
+		nxDomains[q.domain] = struct{}{}
+		if q.random {
+			if _, ok := randomNXLabels[q.label]; !ok && len(examples) < maxExamples {
+				examples = append(examples, q.domain)
+			}
+			randomNXLabels[q.label] = struct{}{}
+		}
+	}
+
+	if len(randomNXLabels) >= randomNXThreshold {
+		detections |= DetectionDGA
+	}
+	if len(nxDomains) >= nxBurstThreshold &&
+		float64(nxQueries) >= nxBurstRatio*float64(len(src.queries)) {
+		detections |= DetectionNXBurst
+	}
+
+	for _, detection := range []uint8{DetectionDGA, DetectionNXBurst} {
+		if detections&detection > 0 {
+			src.detected[detection] = now.Unix()
+		}
+	}
+
+	if len(examples) == 0 {
+		for domain := range nxDomains {
+			examples = append(examples, domain)
+			if len(examples) >= maxExamples {
+				break
+			}
+		}
+		sort.Strings(examples)
+	}
+	src.stats = &Detection{
+		Pid:            src.pid,
+		ProcessName:    src.name,
+		ProcessPath:    src.path,
+		Queries:        len(src.queries),
+		NXDomains:      len(nxDomains),
+		RandomNXLabels: len(randomNXLabels),
+		Examples:       examples,
+		FirstSeen:      src.firstSeen,
+		LastSeen:       now.Unix(),
+		Blocked:        src.blockedUntil > now.Unix(),
+	}
+
+	return detections
+}
+
+// isBlocked returns whether DNS queries of the given process should be denied.
+func (d *detector) isBlocked(processPath string, now time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	src, ok := d.sources[processPath]
+	if !ok {
+		return false
+	}
+	return src.blockedUntil > now.Unix()
+}
+
+// check expires old detections and publishes them as threats.
+func (d *detector) check(now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	for key, src := range d.sources {
+		src.analyze(now)
+
+		for _, detection := range []uint8{DetectionDGA, DetectionNXBurst} {
+			id := threatID(detection, src.path)
+			lastDetected, ok := src.detected[detection]
+			if ok && now.Sub(time.Unix(lastDetected, 0)) <= threatTTL {
+				d.publish(id, detection, src, now)
+				continue
+			}
+
+			delete(src.detected, detection)
+			if _, ok := d.reported[id]; ok {
+				delete(d.reported, id)
+				d.reporter.Delete(id)
+			}
+		}
+
+		if len(src.queries) == 0 && len(src.detected) == 0 && src.blockedUntil <= now.Unix() {
+			delete(d.sources, key)
+		}
+	}
+}
+
+// publish reports or confirms the threat of a detection. The detector must be locked.
+func (d *detector) publish(id string, detection uint8, src *source, now time.Time) {
+	if nxDomains, ok := d.reported[id]; ok &&
+		nxDomains == src.stats.NXDomains &&
+		d.reporter.Refresh(id, threatRefreshTTL) {
+		return
+	}
+
+	var name, description string
+	mitigationLevel := status.SecurityLevelDynamic
+	switch detection {
+	case DetectionDGA:
+		name = fmt.Sprintf("Domain Generation by %s", src.name)
+		description = fmt.Sprintf(
+			"%s (%s) queried %d random-looking domains that do not exist, eg. %s. Malware generates domains like this to find its command and control servers.",
+			src.name, src.path, src.stats.RandomNXLabels, strings.Join(src.stats.Examples, ", "),
+		)
+		mitigationLevel = status.SecurityLevelSecure
+	case DetectionNXBurst:
+		name = fmt.Sprintf("Non-Existent Domain Burst by %s", src.name)
+		description = fmt.Sprintf(
+			"%s (%s) queried %d domains that do not exist, eg. %s.",
+			src.name, src.path, src.stats.NXDomains, strings.Join(src.stats.Examples, ", "),
+		)
+	}
+
+	d.reporter.Add(&status.Threat{
+		ID:              id,
+		Name:            name,
+		Description:     description,
+		AdditionalData:  src.stats,
+		MitigationLevel: mitigationLevel,
+		Expires:         now.Add(threatRefreshTTL).Unix(),
+	})
+	d.reported[id] = src.stats.NXDomains
+}
+
+func threatID(detection uint8, processPath string) string {
+	switch detection {
+	case DetectionDGA:
+		return "dga-" + processPath
+	case DetectionNXBurst:
+		return "nxdomain-burst-" + processPath
+	default:
+		return "dga-unknown-" + processPath
+	}
+}
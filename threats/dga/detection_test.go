@@ -0,0 +1,134 @@
+package dga
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+func newTestDetector(block bool) (*detector, map[string]*status.Threat) {
+	recorder := reporter.NewRecorder()
+	d := newDetector()
+	d.reporter = recorder
+	d.shouldBlock = func() bool {
+		return block
+	}
+	return d, recorder.Threats
+}
+
+func randomLabel(rng *rand.Rand, length int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	label := make([]byte, length)
+	for i := range label {
+		label[i] = chars[rng.Intn(len(chars))]
+	}
+	return string(label)
+}
+
+func TestRandomness(t *testing.T) {
+	m := getModel()
+	for _, domain := range []string{
+		"www.google.com.",
+		"d3c4f5g6h7.cloudfront.net.",
+		"mbtq6opnuodp34gcrma65fxacgxv5ukr7lq6xuhr4mhoibe7.er.spotify.com.",
+		"stackoverflow.com.",
+		"login.microsoftonline.com.",
+		"xn--80ak6aa92e.com.",
+	} {
+		if label, random := isRandom(m, domain); random {
+			t.Errorf("%s should not look random (label %s scored %.2f)", domain, label, randomness(m, label))
+		}
+	}
+	for _, domain := range []string{
+		"xjwqpzkrtv.com.",
+		"kq3v9z1x7ht2.net.",
+		"www.hgfjdsakzxmc.info.",
+	} {
+		if label, random := isRandom(m, domain); !random {
+			t.Errorf("%s should look random (label %s scored %.2f)", domain, label, randomness(m, label))
+		}
+	}
+}
+
+func TestDGA(t *testing.T) {
+	d, reported := newTestDetector(true)
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	path := "/tmp/malware"
+
+	// regular traffic
+	for i := 0; i < 20; i++ {
+		d.report(1, "malware", path, "www.example.com.", false, now)
+	}
+	d.check(now)
+	if len(reported) != 0 || d.isBlocked(path, now) {
+		t.Fatal("regular traffic reported")
+	}
+
+	for i := 0; i < randomNXThreshold*2; i++ {
+		d.report(1, "malware", path, fmt.Sprintf("%s.com.", randomLabel(rng, 12)), true, now)
+	}
+	d.check(now)
+	threat, ok := reported[threatID(DetectionDGA, path)]
+	if !ok {
+		t.Fatalf("domain generation not reported: %+v", reported)
+	}
+	detection := threat.AdditionalData.(*Detection)
+	if detection.ProcessPath != path || detection.RandomNXLabels < randomNXThreshold || !detection.Blocked {
+		t.Errorf("unexpected detection: %+v", detection)
+	}
+	if !d.isBlocked(path, now) {
+		t.Error("process should be blocked")
+	}
+	if d.isBlocked("/usr/bin/other", now) {
+		t.Error("other process should not be blocked")
+	}
+
+	// detection expires
+	d.check(now.Add(window + threatTTL + time.Minute))
+	if len(reported) != 0 {
+		t.Errorf("expected threat to expire, got %d threats", len(reported))
+	}
+	if d.isBlocked(path, now.Add(blockDuration+time.Second)) {
+		t.Error("block should expire")
+	}
+}
+
+func TestNXBurst(t *testing.T) {
+	d, reported := newTestDetector(true)
+	now := time.Now()
+	path := "/usr/bin/misconfigured"
+
+	for i := 0; i < nxBurstThreshold; i++ {
+		d.report(2, "misconfigured", path, fmt.Sprintf("host%d.corp.example.com.", i), true, now)
+	}
+	d.check(now)
+	if _, ok := reported[threatID(DetectionNXBurst, path)]; !ok {
+		t.Fatalf("nxdomain burst not reported: %+v", reported)
+	}
+	if _, ok := reported[threatID(DetectionDGA, path)]; ok {
+		t.Error("regular domains reported as domain generation")
+	}
+	if d.isBlocked(path, now) {
+		t.Error("nxdomain bursts should not block the process")
+	}
+}
+
+func TestCDNSubdomains(t *testing.T) {
+	d, reported := newTestDetector(true)
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+
+	// random subdomains of a regular domain must not be regarded as generated domains
+	for i := 0; i < randomNXThreshold*2; i++ {
+		d.report(3, "browser", "/usr/bin/browser", fmt.Sprintf("%s.cloudfront.net.", randomLabel(rng, 12)), true, now)
+	}
+	d.check(now)
+	if len(reported) != 0 {
+		t.Errorf("cdn subdomains reported: %+v", reported)
+	}
+}
@@ -0,0 +1,97 @@
+package dga
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portmaster/analytics/algs"
+	"github.com/Safing/portmaster/updates"
+)
+
+const (
+	modelIdentifier = "intel/dga/ngram-model.json"
+)
+
+var (
+	model        *algs.NgramModel
+	modelVersion string
+	modelLock    sync.RWMutex
+)
+
+// builtinCorpus is used to train a fallback model, until a model was loaded from the update system.
+var builtinCorpus = `
+about account active admin agent alpha analytics android answer api app apple archive area assets audio auth
+auto backup bank base beta billing blog board book box bridge browser build business buy cache calendar call
+camera campus capital card care cart cast center central change channel chat check city class click client
+cloud club code collect com commerce common community company compute config connect console contact content
+control cookie core corp count country create credit custom daily data deal debug delivery demo design dev
+device digital direct discover display dist docs domain download drive east edge education email engine
+enterprise event example exchange express extra fast feed file filter finance find first fix flash flow font
+food forum free friend front games gateway general get global gold google graph green group guide hello help
+home host hub image index info inside insight intel inter internet item japan journal just key kids lab land
+language last learn library life light line link live load local login mail main manager map market master
+media meet member menu message metric micro mobile mode money monitor more motion music my name nation native
+net network news next node north note now office online open order origin outlook page panel partner pay
+people photo pixel place platform play plus point portal post power premium press price prime print privacy
+pro product profile project proxy public push quick radio rate read real record red region register relay
+remote report research resource rest review right room route safe sale sales scan school score search secure
+security send server service session share shop sign simple site smart social soft software solution sound
+source south space speed sport stack stage start static station status storage store stream studio style
+support sync system table talk team tech telemetry test time today tool top track trade traffic travel trust
+tube update upload user video view village vision voice watch water weather web west wiki window wireless
+work world yahoo your zone amazon microsoft facebook twitter netflix spotify github mozilla ubuntu debian
+wikipedia linkedin reddit instagram youtube paypal dropbox adobe oracle samsung intel nvidia akamai
+cloudflare fastly azure office windows skype steam valve apple icloud ebay alibaba baidu yandex bing duckduckgo
+whatsapp telegram signal slack zoom discord twitch pinterest tumblr wordpress medium stackoverflow gitlab
+bitbucket docker kubernetes python golang java rust node npm pypi maven gradle jetbrains atlassian salesforce
+shopify stripe mailchimp zendesk hubspot godaddy namecheap digitalocean heroku vercel netlify firebase
+doubleclick googleapis gstatic googlevideo ggpht akamaiedge cloudfront edgekey edgesuite msedge trafficmanager
+`
+
+func init() {
+	model = algs.TrainNgramModel(2, strings.Fields(builtinCorpus), 0.98)
+	modelVersion = "builtin"
+}
+
+func getModel() *algs.NgramModel {
+	modelLock.RLock()
+	defer modelLock.RUnlock()
+	return model
+}
+
+// updateModel loads the model from the update system, if a new version is available.
+func updateModel() {
+	modelLock.RLock()
+	current := modelVersion
+	modelLock.RUnlock()
+
+	file, err := updates.GetFile(modelIdentifier)
+	if err != nil {
+		log.Debugf("threats/dga: could not get model, using %s model: %s", current, err)
+		return
+	}
+	if file.Version() == current {
+		return
+	}
+
+	f, err := os.Open(file.Path())
+	if err != nil {
+		log.Warningf("threats/dga: could not open model: %s", err)
+		return
+	}
+	defer f.Close()
+
+	newModel, err := algs.LoadNgramModel(f)
+	if err != nil {
+		log.Warningf("threats/dga: could not load model %s: %s", file.Version(), err)
+		return
+	}
+
+	modelLock.Lock()
+	model = newModel
+	modelVersion = file.Version()
+	modelLock.Unlock()
+	log.Infof("threats/dga: loaded model %s", file.Version())
+}
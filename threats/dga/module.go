@@ -0,0 +1,92 @@
+package dga
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/process"
+	"github.com/Safing/portmaster/status"
+
+	// module dependencies
+	_ "github.com/Safing/portmaster/updates"
+)
+
+var (
+	checkInterval       = 10 * time.Second
+	modelUpdateInterval = 1 * time.Hour
+
+	blockProcessDNS status.SecurityLevelOption
+
+	dgaDetector    = newDetector()
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("threats:dga", prep, start, stop, "status", "updates")
+}
+
+func prep() error {
+	err := config.Register(&config.Option{
+		Name:            "Block DNS of Domain Generating Processes",
+		Key:             "threats/dga/blockProcessDNS",
+		Description:     "Temporarily deny all DNS queries of processes that were detected querying randomly generated domains.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		ExternalOptType: "security level",
+		DefaultValue:    6,
+		ValidationRegex: "^(7|6|4)$",
+	})
+	if err != nil {
+		return err
+	}
+	blockProcessDNS = status.ConfigIsActiveConcurrent("threats/dga/blockProcessDNS")
+
+	return nil
+}
+
+func start() error {
+	go checker()
+	go modelUpdater()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	return nil
+}
+
+func checker() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(checkInterval):
+			dgaDetector.check(time.Now())
+		}
+	}
+}
+
+func modelUpdater() {
+	updateModel()
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(modelUpdateInterval):
+			updateModel()
+		}
+	}
+}
+
+// Report reports a DNS query of a process and the response code it was answered with for domain generation detection. Only NXDOMAIN responses count as failed lookups, other failures, eg. of the resolvers, do not.
+func Report(proc *process.Process, fqdn string, rcode int) {
+	dgaDetector.report(proc.Pid, proc.Name, proc.Path, fqdn, rcode == dns.RcodeNameError, time.Now())
+}
+
+// IsBlocked returns whether DNS queries of the given process should be denied, because it was detected querying generated domains.
+func IsBlocked(proc *process.Process) bool {
+	return dgaDetector.isBlocked(proc.Path, time.Now())
+}
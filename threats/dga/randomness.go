@@ -0,0 +1,77 @@
+package dga
+
+import (
+	"math"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/Safing/portmaster/analytics/algs"
+)
+
+var (
+	// minLabelLength defines the minimum length of a label to be judged, shorter labels cannot be told apart from abbreviations.
+	minLabelLength = 7
+	// randomThreshold defines the randomness score from which on a label is regarded as random-looking.
+	randomThreshold = 0.45
+
+	// signal weights, must add up to 1
+	ngramWeight   = 0.6
+	entropyWeight = 0.2
+	digitsWeight  = 0.1
+	lmsWeight     = 0.1
+)
+
+// registeredLabel returns the label of the domain that was registered with the registry, eg. "example" for "www.example.co.uk.". Random subdomains, as used by CDNs, are ignored this way.
+func registeredLabel(fqdn string) string {
+	domain := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+	registered, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return ""
+	}
+	return strings.SplitN(registered, ".", 2)[0]
+}
+
+// randomness scores how random a label looks from 0 to 1 by combining multiple signals.
+func randomness(m *algs.NgramModel, label string) float64 {
+	// ignore short and internationalized labels
+	if len(label) < minLabelLength || strings.HasPrefix(label, "xn--") {
+		return 0
+	}
+
+	var score float64
+
+	// character sequences that are uncommon in regular domains
+	if m != nil {
+		score += ngramWeight * m.Randomness(label)
+	}
+
+	// high character diversity
+	score += entropyWeight * clamp(algs.Entropy(label)-2.5)
+
+	// digits mixed into letters
+	var digits int
+	for _, c := range label {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	if digits >= 2 && digits < len(label) {
+		score += digitsWeight * clamp(float64(digits)/float64(len(label))*4)
+	}
+
+	// short runs of letters
+	score += lmsWeight * (1 - algs.LmsScore(label)/100)
+
+	return score
+}
+
+// isRandom returns whether the registered label of the given domain looks randomly generated.
+func isRandom(m *algs.NgramModel, fqdn string) (label string, random bool) {
+	label = registeredLabel(fqdn)
+	return label, randomness(m, label) >= randomThreshold
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,326 @@
+package dnstunnel
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+var (
+	window = 5 * time.Minute
+	// threatTTL defines how long a tunnel is reported after it was last confirmed.
+	threatTTL     = 15 * time.Minute
+	blockDuration = 10 * time.Minute
+	// threatRefreshTTL defines how long a reported threat lives without being confirmed by the next check.
+	threatRefreshTTL = 5 * time.Minute
+	// confirmDuration defines how long a tunnel must keep being detected until it raises the security level.
+	confirmDuration = 2 * time.Minute
+
+	// longLabelLength defines from which length on a label is regarded as carrying data.
+	longLabelLength = 32
+	// longLabelRatio and dataTypeRatio define the share of queries with long labels or data query types (TXT, NULL) that indicates a tunnel.
+	longLabelRatio = 0.5
+	dataTypeRatio  = 0.5
+	// minEntropyRatio defines the character entropy of the unique subdomains, relative to the maximum entropy of the characters used, that indicates encoded data. Encoded (encrypted or compressed) data uses all characters of its encoding about equally often and is close to 1, host names are made of words and numbers and stay well below.
+	minEntropyRatio = 0.95
+	// minEncodingChars is the number of different characters of the smallest encoding regarded, hex.
+	minEncodingChars = 16
+
+	maxSamples       = 5
+	maxQueriesPerKey = 4096
+	maxTunnels       = 4096
+)
+
+// Tunnel describes a process that was detected transmitting data via DNS queries to a domain.
+type Tunnel struct {
+	Pid              int
+	ProcessName      string
+	ProcessPath      string
+	Domain           string
+	Queries          int
+	UniqueSubdomains int
+	LongLabels       int
+	DataQueries      int
+	// Volume is the total length of all unique subdomains in bytes.
+	Volume int
+	// EntropyRatio is the character entropy of all unique subdomains, relative to the maximum entropy of the characters used.
+	EntropyRatio float64
+	Samples      []string
+	FirstSeen    int64
+	LastSeen     int64
+	Blocked      bool
+	// Confirmed is set when the tunnel kept being detected for some time.
+	Confirmed bool
+}
+
+type tunnelQuery struct {
+	seen      int64 // unix nano
+	subdomain string
+	longLabel bool
+	dataType  bool
+}
+
+type tunnel struct {
+	pid    int
+	name   string
+	path   string
+	domain string
+
+	queries []*tunnelQuery
+
+	detected      int64
+	firstDetected int64
+	firstSeen     int64
+	blockedUntil  int64
+	stats         *Tunnel
+
+	reportedConfirmed bool
+}
+
+type detector struct {
+	sync.Mutex
+
+	tunnels map[string]*tunnel
+	// reported holds the number of unique subdomains of reported threats to detect changes.
+	reported map[string]int
+
+	// thresholds returns the minimum number of unique subdomains and the minimum data volume in bytes of a tunnel.
+	thresholds  func() (uniqueSubdomains, volume int)
+	isAllowed   func(domain string) bool
+	shouldBlock func() bool
+	reporter    reporter.Reporter
+}
+
+func newDetector() *detector {
+	return &detector{
+		tunnels:    make(map[string]*tunnel),
+		reported:   make(map[string]int),
+		thresholds: configuredThresholds,
+		isAllowed:  isAllowed,
+		shouldBlock: func() bool {
+			return blockTunnels(status.ActiveSecurityLevel())
+		},
+		reporter: reporter.Status,
+	}
+}
+
+// splitDomain splits the given domain into the subdomain and the domain that was registered with the registry, eg. "www" and "example.co.uk" for "www.example.co.uk.".
+func splitDomain(fqdn string) (subdomain, registered string) {
+	domain := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+	registered, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(domain, registered), "."), registered
+}
+
+// report records a DNS query of a process and returns whether the query should be blocked, because it is part of a detected tunnel.
+func (d *detector) report(pid int, processName, processPath, fqdn string, qtype dns.Type, now time.Time) (block bool) {
+	subdomain, registered := splitDomain(fqdn)
+	if registered == "" {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	key := processPath + " " + registered
+	t, ok := d.tunnels[key]
+	if !ok {
+		if subdomain == "" || len(d.tunnels) >= maxTunnels || (d.isAllowed != nil && d.isAllowed(registered)) {
+			return false
+		}
+		t = &tunnel{
+			path:      processPath,
+			domain:    registered,
+			firstSeen: now.Unix(),
+		}
+		d.tunnels[key] = t
+	}
+	t.pid = pid
+	t.name = processName
+
+	if subdomain != "" {
+		q := &tunnelQuery{
+			seen:      now.UnixNano(),
+			subdomain: subdomain,
+			dataType:  qtype == dns.Type(dns.TypeTXT) || qtype == dns.Type(dns.TypeNULL),
+		}
+		for _, label := range strings.Split(subdomain, ".") {
+			if len(label) >= longLabelLength {
+				q.longLabel = true
+				break
+			}
+		}
+		t.queries = append(t.queries, q)
+		if len(t.queries) > maxQueriesPerKey {
+			t.queries = t.queries[len(t.queries)-maxQueriesPerKey:]
+		}
+	}
+
+	uniqueThreshold, volumeThreshold := d.thresholds()
+	if t.analyze(now, uniqueThreshold, volumeThreshold) && d.shouldBlock != nil && d.shouldBlock() {
+		t.blockedUntil = now.Add(blockDuration).Unix()
+	}
+
+	return t.blockedUntil > now.Unix()
+}
+
+// analyze prunes old queries, updates the statistics and returns whether the queries currently look like a tunnel.
+func (t *tunnel) analyze(now time.Time, uniqueThreshold, volumeThreshold int) (detected bool) {
+	windowStart := now.Add(-window).UnixNano()
+	firstValid := 0
+	for firstValid < len(t.queries) && t.queries[firstValid].seen < windowStart {
+		firstValid++
+	}
+	t.queries = t.queries[firstValid:]
+
+	unique := make(map[string]struct{})
+	chars := make(map[rune]int)
+	var samples []string
+	var longLabels, dataQueries, volume int
+	for _, q := range t.queries {
+		if q.longLabel {
+			longLabels++
+		}
+		if q.dataType {
+			dataQueries++
+		}
+		if _, ok := unique[q.subdomain]; ok {
+			continue
+		}
+		unique[q.subdomain] = struct{}{}
+		volume += len(q.subdomain)
+		for _, c := range q.subdomain {
+			if c != '.' {
+				chars[c]++
+			}
+		}
+		if len(samples) < maxSamples {
+			samples = append(samples, q.subdomain+"."+t.domain)
+		}
+	}
+	entropyRatio := entropyRatio(chars)
+
+	// many unique subdomains and a lot of data alone are common for CDNs, the data must also look encoded
+	if len(unique) >= uniqueThreshold && volume >= volumeThreshold && len(t.queries) > 0 {
+		queries := float64(len(t.queries))
+		detected = entropyRatio >= minEntropyRatio ||
+			float64(longLabels) >= longLabelRatio*queries ||
+			float64(dataQueries) >= dataTypeRatio*queries
+	}
+	if detected {
+		t.detected = now.Unix()
+		if t.firstDetected == 0 {
+			t.firstDetected = now.Unix()
+		}
+	}
+
+	t.stats = &Tunnel{
+		Pid:              t.pid,
+		ProcessName:      t.name,
+		ProcessPath:      t.path,
+		Domain:           t.domain,
+		Queries:          len(t.queries),
+		UniqueSubdomains: len(unique),
+		LongLabels:       longLabels,
+		DataQueries:      dataQueries,
+		Volume:           volume,
+		EntropyRatio:     entropyRatio,
+		Samples:          samples,
+		FirstSeen:        t.firstSeen,
+		LastSeen:         now.Unix(),
+		Blocked:          t.blockedUntil > now.Unix(),
+		Confirmed:        t.detected > 0 && t.detected-t.firstDetected >= int64(confirmDuration/time.Second),
+	}
+	return detected
+}
+
+// entropyRatio returns the Shannon entropy of the given character counts, relative to the maximum entropy of the number of different characters. Fewer characters than used by any encoding, eg. only digits, are not regarded as encoded data.
+func entropyRatio(chars map[rune]int) float64 {
+	if len(chars) < minEncodingChars {
+		return 0
+	}
+
+	var total int
+	for _, count := range chars {
+		total += count
+	}
+
+	var entropy float64
+	for _, count := range chars {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy / math.Log2(float64(len(chars)))
+}
+
+// check expires old detections and publishes them as threats.
+func (d *detector) check(now time.Time) {
+	uniqueThreshold, volumeThreshold := d.thresholds()
+
+	d.Lock()
+	defer d.Unlock()
+
+	for key, t := range d.tunnels {
+		t.analyze(now, uniqueThreshold, volumeThreshold)
+
+		id := t.threatID()
+		if t.detected > 0 && now.Sub(time.Unix(t.detected, 0)) <= threatTTL {
+			d.publish(id, t, now)
+		} else {
+			t.detected = 0
+			t.firstDetected = 0
+			if _, ok := d.reported[id]; ok {
+				delete(d.reported, id)
+				d.reporter.Delete(id)
+			}
+		}
+
+		if len(t.queries) == 0 && t.detected == 0 && t.blockedUntil <= now.Unix() {
+			delete(d.tunnels, key)
+		}
+	}
+}
+
+// publish reports or confirms the threat of a tunnel. The detector must be locked.
+func (d *detector) publish(id string, t *tunnel, now time.Time) {
+	if unique, ok := d.reported[id]; ok &&
+		unique == t.stats.UniqueSubdomains &&
+		t.reportedConfirmed == t.stats.Confirmed &&
+		d.reporter.Refresh(id, threatRefreshTTL) {
+		return
+	}
+
+	// do not raise the security level because of a single burst of queries
+	mitigationLevel := status.SecurityLevelDynamic
+	if t.stats.Confirmed {
+		mitigationLevel = status.SecurityLevelSecure
+	}
+
+	d.reporter.Add(&status.Threat{
+		ID:   id,
+		Name: fmt.Sprintf("DNS Tunnel by %s", t.name),
+		Description: fmt.Sprintf(
+			"%s (%s) sent %d queries with %d unique subdomains (%d bytes) to %s, eg. %s. This is typical for data being transmitted through DNS.",
+			t.name, t.path, t.stats.Queries, t.stats.UniqueSubdomains, t.stats.Volume, t.domain, strings.Join(t.stats.Samples, ", "),
+		),
+		AdditionalData:  t.stats,
+		MitigationLevel: mitigationLevel,
+		Expires:         now.Add(threatRefreshTTL).Unix(),
+	})
+	d.reported[id] = t.stats.UniqueSubdomains
+	t.reportedConfirmed = t.stats.Confirmed
+}
+
+func (t *tunnel) threatID() string {
+	return fmt.Sprintf("dns-tunnel-%s-%s", t.domain, t.path)
+}
@@ -0,0 +1,166 @@
+package dnstunnel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+var (
+	testTXT = dns.Type(dns.TypeTXT)
+	testA   = dns.Type(dns.TypeA)
+)
+
+func newTestDetector(block bool) (*detector, map[string]*status.Threat) {
+	recorder := reporter.NewRecorder()
+	d := newDetector()
+	d.reporter = recorder
+	d.thresholds = func() (int, int) {
+		return 20, 1024
+	}
+	d.isAllowed = func(domain string) bool {
+		return domain == "allowed.example"
+	}
+	d.shouldBlock = func() bool {
+		return block
+	}
+	return d, recorder.Threats
+}
+
+// encode returns a subdomain that encodes the given chunk like data exfiltration tools do, which usually encrypt the data first.
+func encode(i int) string {
+	encrypted := sha256.Sum256([]byte(fmt.Sprintf("secret document chunk %04d", i)))
+	return hex.EncodeToString(encrypted[:])[:62]
+}
+
+func TestSplitDomain(t *testing.T) {
+	for fqdn, expected := range map[string][2]string{
+		"www.example.com.":     {"www", "example.com"},
+		"a.b.example.co.uk.":   {"a.b", "example.co.uk"},
+		"example.com.":         {"", "example.com"},
+		"WWW.Example.COM.":     {"www", "example.com"},
+		"deep.sub.example.org": {"deep.sub", "example.org"},
+	} {
+		subdomain, registered := splitDomain(fqdn)
+		if subdomain != expected[0] || registered != expected[1] {
+			t.Errorf("%s: got %q and %q, expected %q and %q", fqdn, subdomain, registered, expected[0], expected[1])
+		}
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	d, reported := newTestDetector(true)
+	now := time.Now()
+	path := "/tmp/exfil"
+
+	var blocked bool
+	for i := 0; i < 19; i++ {
+		blocked = d.report(1, "exfil", path, encode(i)+".tunnel.example.", testTXT, now)
+	}
+	d.check(now)
+	if blocked || len(reported) != 0 {
+		t.Fatal("tunnel detected before threshold was reached")
+	}
+
+	blocked = d.report(1, "exfil", path, encode(19)+".tunnel.example.", testTXT, now)
+	if !blocked {
+		t.Error("tunnel should be blocked")
+	}
+	d.check(now)
+	threat, ok := reported["dns-tunnel-tunnel.example-"+path]
+	if !ok {
+		t.Fatalf("tunnel not reported: %+v", reported)
+	}
+	tunnel := threat.AdditionalData.(*Tunnel)
+	if tunnel.UniqueSubdomains != 20 || tunnel.DataQueries != 20 || tunnel.LongLabels != 20 || len(tunnel.Samples) != maxSamples {
+		t.Errorf("unexpected evidence: %+v", tunnel)
+	}
+	if threat.MitigationLevel != status.SecurityLevelDynamic {
+		t.Errorf("unconfirmed tunnel should not raise the security level, got mitigation level %d", threat.MitigationLevel)
+	}
+
+	// tunnel is confirmed when it keeps being detected
+	later := now.Add(confirmDuration)
+	d.report(1, "exfil", path, encode(20)+".tunnel.example.", testTXT, later)
+	d.check(later)
+	threat = reported["dns-tunnel-tunnel.example-"+path]
+	if threat.MitigationLevel != status.SecurityLevelSecure {
+		t.Errorf("confirmed tunnel should raise the security level, got mitigation level %d", threat.MitigationLevel)
+	}
+
+	// other domains and processes are not affected
+	if d.report(1, "exfil", path, "www.example.com.", testA, now) {
+		t.Error("other domain should not be blocked")
+	}
+	if d.report(2, "other", "/usr/bin/other", encode(1)+".tunnel.example.", testTXT, now) {
+		t.Error("other process should not be blocked")
+	}
+
+	// detection expires
+	d.check(now.Add(window + threatTTL + time.Minute))
+	if len(reported) != 0 {
+		t.Errorf("expected threat to expire, got %d threats", len(reported))
+	}
+	if d.report(1, "exfil", path, "www.tunnel.example.", testA, now.Add(blockDuration+time.Second)) {
+		t.Error("block should expire")
+	}
+}
+
+func TestManySubdomainsWithoutData(t *testing.T) {
+	d, reported := newTestDetector(true)
+	now := time.Now()
+
+	// short A queries, as used by CDNs and for load balancing
+	for i := 0; i < 100; i++ {
+		d.report(1, "browser", "/usr/bin/browser", fmt.Sprintf("img%d.cdn.example.", i), testA, now)
+	}
+	d.check(now)
+	if len(reported) != 0 {
+		t.Errorf("regular subdomains reported as tunnel: %+v", reported)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	d, reported := newTestDetector(false)
+	now := time.Now()
+
+	// short labels, but a lot of encoded data
+	for i := 0; i < 60; i++ {
+		d.report(1, "exfil", "/tmp/exfil", fmt.Sprintf("%s.%s.v.example.", encode(i)[:24], encode(i)[24:48]), testA, now)
+	}
+	d.check(now)
+	if _, ok := reported["dns-tunnel-v.example-/tmp/exfil"]; !ok {
+		t.Errorf("high volume tunnel not reported: %+v", reported)
+	}
+
+	// a lot of data, but host names
+	for i := 0; i < 60; i++ {
+		d.report(2, "browser", "/usr/bin/browser", fmt.Sprintf("item%04d-thumbnail-small.static.v.example.", i), testA, now)
+	}
+	d.check(now)
+	if _, ok := reported["dns-tunnel-v.example-/usr/bin/browser"]; ok {
+		t.Errorf("high volume of host names reported as tunnel: %+v", reported)
+	}
+}
+
+func TestAllowedDomain(t *testing.T) {
+	d, reported := newTestDetector(true)
+	now := time.Now()
+
+	for i := 0; i < 50; i++ {
+		if d.report(1, "antivirus", "/usr/bin/antivirus", encode(i)+".allowed.example.", testTXT, now) {
+			t.Fatal("allowed domain should not be blocked")
+		}
+	}
+	d.check(now)
+	if len(reported) != 0 {
+		t.Errorf("allowed domain reported: %+v", reported)
+	}
+}
@@ -0,0 +1,116 @@
+package dnstunnel
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/process"
+	"github.com/Safing/portmaster/profile"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	checkInterval = 10 * time.Second
+
+	defaultUniqueSubdomainThreshold = 100
+	defaultVolumeThreshold          = 8192
+
+	blockTunnels             status.SecurityLevelOption
+	uniqueSubdomainThreshold config.IntOption
+	volumeThreshold          config.IntOption
+
+	tunnelDetector = newDetector()
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("threats:dnstunnel", prep, start, stop, "profile", "status")
+}
+
+func prep() error {
+	err := config.Register(&config.Option{
+		Name:            "Block DNS Tunnels",
+		Key:             "threats/dnstunnel/blockTunnels",
+		Description:     "Temporarily deny DNS queries of a process to a domain, if they were detected transmitting data.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		ExternalOptType: "security level",
+		DefaultValue:    6,
+		ValidationRegex: "^(7|6|4)$",
+	})
+	if err != nil {
+		return err
+	}
+	blockTunnels = status.ConfigIsActiveConcurrent("threats/dnstunnel/blockTunnels")
+
+	err = config.Register(&config.Option{
+		Name:            "DNS Tunnel Subdomain Threshold",
+		Key:             "threats/dnstunnel/uniqueSubdomainThreshold",
+		Description:     "Minimum number of unique subdomains of one domain that a process must query within five minutes to be regarded as tunneling data.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    defaultUniqueSubdomainThreshold,
+		ValidationRegex: "^[1-9][0-9]{0,4}$",
+	})
+	if err != nil {
+		return err
+	}
+	uniqueSubdomainThreshold = config.Concurrent.GetAsInt("threats/dnstunnel/uniqueSubdomainThreshold", int64(defaultUniqueSubdomainThreshold))
+
+	err = config.Register(&config.Option{
+		Name:            "DNS Tunnel Volume Threshold",
+		Key:             "threats/dnstunnel/volumeThreshold",
+		Description:     "Minimum amount of data in bytes, encoded in unique subdomains, that a process must send to one domain within five minutes to be regarded as tunneling data. Additionally, the subdomains must look like encoded data.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    defaultVolumeThreshold,
+		ValidationRegex: "^[1-9][0-9]{0,6}$",
+	})
+	if err != nil {
+		return err
+	}
+	volumeThreshold = config.Concurrent.GetAsInt("threats/dnstunnel/volumeThreshold", int64(defaultVolumeThreshold))
+
+	return nil
+}
+
+func start() error {
+	go checker()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	return nil
+}
+
+func checker() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(checkInterval):
+			tunnelDetector.check(time.Now())
+		}
+	}
+}
+
+func configuredThresholds() (uniqueSubdomains, volume int) {
+	if uniqueSubdomainThreshold == nil || volumeThreshold == nil {
+		return defaultUniqueSubdomainThreshold, defaultVolumeThreshold
+	}
+	return int(uniqueSubdomainThreshold()), int(volumeThreshold())
+}
+
+// isAllowed returns whether queries to the given domain are known-good, because it is explicitly permitted in the global profile.
+func isAllowed(domain string) bool {
+	return profile.IsPermittedByGlobalProfile(domain + ".")
+}
+
+// Check reports a DNS query of a process for tunnel detection and returns whether it should be denied, because it is part of a detected tunnel.
+func Check(proc *process.Process, fqdn string, qtype dns.Type) (block bool) {
+	return tunnelDetector.report(proc.Pid, proc.Name, proc.Path, fqdn, qtype, time.Now())
+}
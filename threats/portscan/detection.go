@@ -1 +1,327 @@
 package portscan
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Safing/portmaster/network/netutils"
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+// Scan Types
+const (
+	ScanVertical   uint8 = 1 << iota // many ports on one host
+	ScanHorizontal                   // the same port on many hosts
+	ScanSlow                         // many ports over a long time
+)
+
+const (
+	threatIDPrefix = "portscan-"
+)
+
+var (
+	shortWindow = 1 * time.Minute
+	slowWindow  = 1 * time.Hour
+	// threatTTL defines how long a vertical or horizontal scan is reported after the last packet of the scanner was seen.
+	threatTTL     = 15 * time.Minute
+	blockDuration = 10 * time.Minute
+	// threatRefreshTTL defines how long a reported threat lives without being confirmed by the next cleanup.
+	threatRefreshTTL = 5 * time.Minute
+
+	verticalThreshold   = 12
+	horizontalThreshold = 6
+	slowThreshold       = 30
+
+	maxEventsPerSource = 1024
+	maxSources         = 4096
+)
+
+// Scanner describes a source that was detected to scan this host.
+type Scanner struct {
+	IP        string
+	Ports     int
+	Hosts     int
+	FirstSeen int64
+	LastSeen  int64
+	Blocked   bool
+}
+
+type scanEvent struct {
+	seen int64 // unix nano
+	host string
+	port string
+}
+
+type source struct {
+	ip        net.IP
+	events    []*scanEvent
+	scanTypes uint8
+	firstSeen int64
+	lastSeen  int64
+
+	ports        int
+	hosts        int
+	blockedUntil int64
+}
+
+type detector struct {
+	sync.Mutex
+
+	sources map[string]*source
+	changed bool
+	threats map[string]*status.Threat
+
+	shouldBlock func() bool
+	reporter    reporter.Reporter
+}
+
+func newDetector() *detector {
+	return &detector{
+		sources: make(map[string]*source),
+		threats: make(map[string]*status.Threat),
+		shouldBlock: func() bool {
+			return blockScanners(status.ActiveSecurityLevel())
+		},
+		reporter: reporter.Status,
+	}
+}
+
+// report records a denied inbound connection attempt and checks the source for scanning behaviour.
+func (d *detector) report(remoteIP, localIP net.IP, protocol uint8, localPort uint16, now time.Time) {
+	if remoteIP == nil || netutils.IPIsLocalhost(remoteIP) {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	key := remoteIP.String()
+	src, ok := d.sources[key]
+	if !ok {
+		if len(d.sources) >= maxSources {
+			return
+		}
+		src = &source{
+			ip:        remoteIP,
+			firstSeen: now.Unix(),
+		}
+		d.sources[key] = src
+	}
+	src.lastSeen = now.Unix()
+
+	src.events = append(src.events, &scanEvent{
+		seen: now.UnixNano(),
+		host: localIP.String(),
+		port: fmt.Sprintf("%d/%d", protocol, localPort),
+	})
+	if len(src.events) > maxEventsPerSource {
+		src.events = src.events[len(src.events)-maxEventsPerSource:]
+	}
+
+	scanTypes := src.analyze(now)
+	if scanTypes == 0 {
+		return
+	}
+
+	src.scanTypes |= scanTypes
+	// block the scanner again if it continues after the block expired
+	if src.blockedUntil <= now.Unix() && d.shouldBlock != nil && d.shouldBlock() {
+		src.blockedUntil = now.Add(blockDuration).Unix()
+	}
+	d.changed = true
+}
+
+// analyze prunes old events and returns the scan types the source currently matches.
+func (src *source) analyze(now time.Time) (scanTypes uint8) {
+	// prune events outside the slow window
+	slowStart := now.Add(-slowWindow).UnixNano()
+	firstValid := 0
+	for firstValid < len(src.events) && src.events[firstValid].seen < slowStart {
+		firstValid++
+	}
+	src.events = src.events[firstValid:]
+
+	shortStart := now.Add(-shortWindow).UnixNano()
+	portsPerHost := make(map[string]map[string]struct{})
+	hostsPerPort := make(map[string]map[string]struct{})
+	allPorts := make(map[string]struct{})
+	allHosts := make(map[string]struct{})
+	allTargets := make(map[string]struct{})
+
+	for _, event := range src.events {
+		allPorts[event.port] = struct{}{}
+		allHosts[event.host] = struct{}{}
+		allTargets[event.host+" "+event.port] = struct{}{}
+
+		if event.seen < shortStart {
+			continue
+		}
+		if _, ok := portsPerHost[event.host]; !ok {
+			portsPerHost[event.host] = make(map[string]struct{})
+		}
+		portsPerHost[event.host][event.port] = struct{}{}
+		if _, ok := hostsPerPort[event.port]; !ok {
+			hostsPerPort[event.port] = make(map[string]struct{})
+		}
+		hostsPerPort[event.port][event.host] = struct{}{}
+	}
+	src.ports = len(allPorts)
+	src.hosts = len(allHosts)
+
+	for _, ports := range portsPerHost {
+		if len(ports) >= verticalThreshold {
+			scanTypes |= ScanVertical
+			break
+		}
+	}
+	for _, hosts := range hostsPerPort {
+		if len(hosts) >= horizontalThreshold {
+			scanTypes |= ScanHorizontal
+			break
+		}
+	}
+	if len(allTargets) >= slowThreshold && scanTypes == 0 {
+		scanTypes |= ScanSlow
+	}
+
+	return scanTypes
+}
+
+// isBlocked returns whether inbound traffic from the given IP should be dropped.
+func (d *detector) isBlocked(remoteIP net.IP, now time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	src, ok := d.sources[remoteIP.String()]
+	if !ok {
+		return false
+	}
+	return src.blockedUntil > now.Unix()
+}
+
+// cleanup expires old detections and publishes changes as threats.
+func (d *detector) cleanup(now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	for key, src := range d.sources {
+		src.analyze(now)
+		lastSeen := time.Unix(src.lastSeen, 0)
+
+		if src.scanTypes&(ScanVertical|ScanHorizontal) > 0 && now.Sub(lastSeen) > threatTTL {
+			src.scanTypes &^= ScanVertical | ScanHorizontal
+			d.changed = true
+		}
+		if src.scanTypes&ScanSlow > 0 && now.Sub(lastSeen) > slowWindow {
+			src.scanTypes &^= ScanSlow
+			d.changed = true
+		}
+
+		if src.scanTypes == 0 && len(src.events) == 0 && src.blockedUntil <= now.Unix() {
+			delete(d.sources, key)
+		}
+	}
+
+	if d.changed {
+		d.changed = false
+		d.publish(now)
+		return
+	}
+
+	// confirm active threats
+	for id := range d.threats {
+		if !d.reporter.Refresh(id, threatRefreshTTL) {
+			// threat expired or was dismissed, report again on next change
+			delete(d.threats, id)
+		}
+	}
+}
+
+// publish reports a threat for every scan type with active scanners and clears the others. The detector must be locked.
+func (d *detector) publish(now time.Time) {
+	for _, scanType := range []uint8{ScanVertical, ScanHorizontal, ScanSlow} {
+		id := threatIDPrefix + scanTypeName(scanType)
+
+		var scanners []*Scanner
+		mitigationLevel := status.SecurityLevelDynamic
+		for _, src := range d.sources {
+			if src.scanTypes&scanType == 0 {
+				continue
+			}
+			scanners = append(scanners, &Scanner{
+				IP:        src.ip.String(),
+				Ports:     src.ports,
+				Hosts:     src.hosts,
+				FirstSeen: src.firstSeen,
+				LastSeen:  src.lastSeen,
+				Blocked:   src.blockedUntil > now.Unix(),
+			})
+			// scans from within the local network are more alarming
+			if netutils.IPIsLAN(src.ip) {
+				mitigationLevel = status.SecurityLevelSecure
+			}
+		}
+
+		if len(scanners) == 0 {
+			if _, ok := d.threats[id]; ok {
+				delete(d.threats, id)
+				d.reporter.Delete(id)
+			}
+			continue
+		}
+
+		sort.Slice(scanners, func(i, j int) bool {
+			return scanners[i].IP < scanners[j].IP
+		})
+		ips := make([]string, 0, len(scanners))
+		for _, scanner := range scanners {
+			ips = append(ips, scanner.IP)
+		}
+
+		threat := &status.Threat{
+			ID:              id,
+			Name:            fmt.Sprintf("%s Port Scan", strings.Title(scanTypeName(scanType))),
+			Description:     fmt.Sprintf("%s: %s", scanTypeDescription(scanType), strings.Join(ips, ", ")),
+			AdditionalData:  scanners,
+			MitigationLevel: mitigationLevel,
+			Started:         now.Unix(),
+			Expires:         now.Add(threatRefreshTTL).Unix(),
+		}
+		if existing, ok := d.threats[id]; ok {
+			threat.Started = existing.Started
+		}
+		d.threats[id] = threat
+		d.reporter.Add(threat)
+	}
+}
+
+func scanTypeName(scanType uint8) string {
+	switch scanType {
+	case ScanVertical:
+		return "vertical"
+	case ScanHorizontal:
+		return "horizontal"
+	case ScanSlow:
+		return "slow"
+	default:
+		return "unknown"
+	}
+}
+
+func scanTypeDescription(scanType uint8) string {
+	switch scanType {
+	case ScanVertical:
+		return "These devices are probing many ports of this computer to find running services"
+	case ScanHorizontal:
+		return "These devices are probing the same service on many addresses of this computer"
+	case ScanSlow:
+		return "These devices are slowly probing many ports of this computer over a long time"
+	default:
+		return "These devices are scanning this computer"
+	}
+}
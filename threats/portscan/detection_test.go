@@ -0,0 +1,161 @@
+package portscan
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Safing/portmaster/status"
+	"github.com/Safing/portmaster/threats/reporter"
+)
+
+func newTestDetector(block bool) (*detector, map[string]*status.Threat) {
+	recorder := reporter.NewRecorder()
+	d := newDetector()
+	d.reporter = recorder
+	d.shouldBlock = func() bool {
+		return block
+	}
+	return d, recorder.Threats
+}
+
+func TestVerticalScan(t *testing.T) {
+	d, reported := newTestDetector(true)
+	scanner := net.ParseIP("192.168.1.66")
+	local := net.ParseIP("192.168.1.10")
+	now := time.Now()
+
+	for port := uint16(1); port < uint16(verticalThreshold); port++ {
+		d.report(scanner, local, 6, port, now)
+	}
+	d.cleanup(now)
+	if len(reported) != 0 || d.isBlocked(scanner, now) {
+		t.Fatal("scan reported before threshold was reached")
+	}
+
+	d.report(scanner, local, 6, 1000, now)
+	d.cleanup(now)
+	threat, ok := reported[threatIDPrefix+"vertical"]
+	if !ok {
+		t.Fatal("vertical scan not reported")
+	}
+	if threat.MitigationLevel != status.SecurityLevelSecure {
+		t.Errorf("unexpected mitigation level for LAN scanner: %d", threat.MitigationLevel)
+	}
+	scanners := threat.AdditionalData.([]*Scanner)
+	if len(scanners) != 1 || scanners[0].IP != "192.168.1.66" || !scanners[0].Blocked {
+		t.Errorf("unexpected scanners: %+v", scanners)
+	}
+	if !d.isBlocked(scanner, now) {
+		t.Error("scanner should be blocked")
+	}
+	if d.isBlocked(scanner, now.Add(blockDuration+time.Second)) {
+		t.Error("block should expire")
+	}
+
+	// threat expires
+	d.cleanup(now.Add(threatTTL + time.Minute))
+	if len(reported) != 0 {
+		t.Errorf("expected threat to expire, got %d threats", len(reported))
+	}
+}
+
+func TestReblockContinuedScan(t *testing.T) {
+	d, reported := newTestDetector(true)
+	scanner := net.ParseIP("203.0.113.9")
+	local := net.ParseIP("192.168.1.10")
+	now := time.Now()
+
+	for port := uint16(1); port <= uint16(verticalThreshold); port++ {
+		d.report(scanner, local, 6, port, now)
+	}
+	if !d.isBlocked(scanner, now) {
+		t.Fatal("scanner should be blocked")
+	}
+
+	// the block expires while the scan is still reported as a threat
+	later := now.Add(blockDuration + time.Second)
+	d.cleanup(later)
+	if d.isBlocked(scanner, later) {
+		t.Fatal("block should expire")
+	}
+	if _, ok := reported[threatIDPrefix+"vertical"]; !ok {
+		t.Fatal("vertical scan should still be reported")
+	}
+
+	// the scanner continues
+	for port := uint16(100); port < 100+uint16(verticalThreshold); port++ {
+		d.report(scanner, local, 6, port, later)
+	}
+	if !d.isBlocked(scanner, later) {
+		t.Error("scanner should be blocked again")
+	}
+	if d.isBlocked(scanner, later.Add(blockDuration+time.Second)) {
+		t.Error("renewed block should expire")
+	}
+	d.cleanup(later)
+	scanners := reported[threatIDPrefix+"vertical"].AdditionalData.([]*Scanner)
+	if len(scanners) != 1 || !scanners[0].Blocked {
+		t.Errorf("unexpected scanners: %+v", scanners)
+	}
+}
+
+func TestHorizontalScan(t *testing.T) {
+	d, reported := newTestDetector(false)
+	scanner := net.ParseIP("203.0.113.5")
+	now := time.Now()
+
+	for i := 0; i < horizontalThreshold; i++ {
+		d.report(scanner, net.IPv4(10, 0, 0, byte(i+1)), 6, 22, now)
+	}
+	d.cleanup(now)
+	threat, ok := reported[threatIDPrefix+"horizontal"]
+	if !ok {
+		t.Fatal("horizontal scan not reported")
+	}
+	if threat.MitigationLevel != status.SecurityLevelDynamic {
+		t.Errorf("unexpected mitigation level for Internet scanner: %d", threat.MitigationLevel)
+	}
+	if d.isBlocked(scanner, now) {
+		t.Error("scanner should not be blocked when blocking is disabled")
+	}
+}
+
+func TestSlowScan(t *testing.T) {
+	d, reported := newTestDetector(false)
+	scanner := net.ParseIP("203.0.113.7")
+	local := net.ParseIP("192.168.1.10")
+	now := time.Now()
+
+	// one port per minute never reaches the vertical threshold
+	for i := 0; i < slowThreshold; i++ {
+		d.report(scanner, local, 6, uint16(i+1), now.Add(time.Duration(i)*shortWindow))
+	}
+	last := now.Add(time.Duration(slowThreshold-1) * shortWindow)
+	d.cleanup(last)
+	if _, ok := reported[threatIDPrefix+"vertical"]; ok {
+		t.Error("slow scan should not be reported as vertical scan")
+	}
+	if _, ok := reported[threatIDPrefix+"slow"]; !ok {
+		t.Fatal("slow scan not reported")
+	}
+
+	d.cleanup(last.Add(slowWindow + time.Minute))
+	if len(reported) != 0 {
+		t.Errorf("expected threat to expire, got %d threats", len(reported))
+	}
+	if len(d.sources) != 0 {
+		t.Errorf("expected sources to be cleaned up, got %d", len(d.sources))
+	}
+}
+
+func TestLocalhostIgnored(t *testing.T) {
+	d, _ := newTestDetector(true)
+	now := time.Now()
+	for port := uint16(1); port < 100; port++ {
+		d.report(net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.1"), 6, port, now)
+	}
+	if len(d.sources) != 0 {
+		t.Error("localhost should be ignored")
+	}
+}
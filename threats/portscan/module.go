@@ -1 +1,73 @@
 package portscan
+
+import (
+	"net"
+	"time"
+
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portmaster/internal/modstatus"
+	"github.com/Safing/portmaster/status"
+)
+
+var (
+	cleanupInterval = 10 * time.Second
+
+	blockScanners status.SecurityLevelOption
+
+	scanDetector   = newDetector()
+	shutdownSignal = make(chan struct{})
+)
+
+func init() {
+	modstatus.Register("threats:portscan", prep, start, stop, "status")
+}
+
+func prep() error {
+	err := config.Register(&config.Option{
+		Name:            "Block Port Scanners",
+		Key:             "threats/portscan/blockScanners",
+		Description:     "Temporarily drop all incoming traffic from devices that were detected scanning this computer.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		ExternalOptType: "security level",
+		DefaultValue:    6,
+		ValidationRegex: "^(7|6|4)$",
+	})
+	if err != nil {
+		return err
+	}
+	blockScanners = status.ConfigIsActiveConcurrent("threats/portscan/blockScanners")
+
+	return nil
+}
+
+func start() error {
+	go cleaner()
+	return nil
+}
+
+func stop() error {
+	close(shutdownSignal)
+	return nil
+}
+
+func cleaner() {
+	for {
+		select {
+		case <-shutdownSignal:
+			return
+		case <-time.After(cleanupInterval):
+			scanDetector.cleanup(time.Now())
+		}
+	}
+}
+
+// Report reports a denied inbound connection attempt from remoteIP to the given local address for port scan detection.
+func Report(remoteIP, localIP net.IP, protocol uint8, localPort uint16) {
+	scanDetector.report(remoteIP, localIP, protocol, localPort, time.Now())
+}
+
+// IsBlocked returns whether inbound traffic from the given IP should be dropped, because it was detected scanning this computer.
+func IsBlocked(remoteIP net.IP) bool {
+	return scanDetector.isBlocked(remoteIP, time.Now())
+}
@@ -0,0 +1,88 @@
+// Package reporter publishes the threats found by the threat detectors. Detectors report through a Reporter, so that they can be tested without the system status.
+package reporter
+
+import (
+	"time"
+
+	"github.com/Safing/portmaster/status"
+)
+
+// Reporter publishes threats.
+type Reporter interface {
+	// Add adds the threat or updates the threat with the same ID.
+	Add(threat *status.Threat)
+	// Refresh extends the lifetime of the threat with the given ID and returns whether it still exists.
+	Refresh(id string, ttl time.Duration) bool
+	// Delete removes the threat with the given ID, which marks it as resolved.
+	Delete(id string)
+	// Dismissed returns whether the user dismissed the threat with the given ID.
+	Dismissed(id string) bool
+}
+
+// Status publishes threats to the system status.
+var Status Reporter = statusReporter{}
+
+type statusReporter struct{}
+
+func (statusReporter) Add(threat *status.Threat) {
+	status.AddOrUpdateThreat(threat)
+}
+
+func (statusReporter) Refresh(id string, ttl time.Duration) bool {
+	return status.RefreshThreat(id, ttl)
+}
+
+func (statusReporter) Delete(id string) {
+	status.DeleteThreat(id)
+}
+
+func (statusReporter) Dismissed(id string) bool {
+	return status.IsThreatDismissed(id)
+}
+
+// Recorder keeps reported threats in memory instead of publishing them. It is used to test detectors.
+type Recorder struct {
+	// Threats holds the currently reported threats by their ID.
+	Threats map[string]*status.Threat
+	// DismissedThreats holds the IDs of threats dismissed with Dismiss.
+	DismissedThreats map[string]bool
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		Threats:          make(map[string]*status.Threat),
+		DismissedThreats: make(map[string]bool),
+	}
+}
+
+// Add records the threat, unless it was dismissed.
+func (r *Recorder) Add(threat *status.Threat) {
+	if r.DismissedThreats[threat.ID] {
+		return
+	}
+	r.Threats[threat.ID] = threat
+}
+
+// Refresh returns whether the threat is recorded.
+func (r *Recorder) Refresh(id string, ttl time.Duration) bool {
+	_, ok := r.Threats[id]
+	return ok
+}
+
+// Delete removes the threat and its dismissal.
+func (r *Recorder) Delete(id string) {
+	delete(r.Threats, id)
+	delete(r.DismissedThreats, id)
+}
+
+// Dismissed returns whether the threat was dismissed.
+func (r *Recorder) Dismissed(id string) bool {
+	return r.DismissedThreats[id]
+}
+
+// Dismiss removes the threat as if the user dismissed it.
+func (r *Recorder) Dismiss(id string) {
+	delete(r.Threats, id)
+	r.DismissedThreats[id] = true
+}
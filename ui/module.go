@@ -2,11 +2,11 @@ package ui
 
 import (
 	"github.com/Safing/portbase/api"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 )
 
 func init() {
-	modules.Register("ui", prep, nil, nil, "updates", "api")
+	modstatus.Register("ui", prep, nil, nil, "updates", "api")
 }
 
 func prep() error {
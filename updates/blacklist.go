@@ -0,0 +1,175 @@
+package updates
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/renameio"
+)
+
+const (
+	blacklistFile = "blacklist.json"
+	healthyFile   = "healthy.json"
+)
+
+// blacklistEntry describes why a versioned file must not be used anymore.
+type blacklistEntry struct {
+	Reason string
+	Time   int64
+}
+
+var (
+	// blacklist holds broken files by their versioned path.
+	blacklist     = make(map[string]*blacklistEntry)
+	blacklistLock sync.Mutex
+)
+
+// loadBlacklist loads the blacklist from the update storage.
+func loadBlacklist() error {
+	newBlacklist := make(map[string]*blacklistEntry)
+	data, err := ioutil.ReadFile(filepath.Join(updateStoragePath, blacklistFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		err = json.Unmarshal(data, &newBlacklist)
+		if err != nil {
+			return err
+		}
+	}
+
+	blacklistLock.Lock()
+	defer blacklistLock.Unlock()
+	blacklist = newBlacklist
+	return nil
+}
+
+func isBlacklisted(versionedPath string) bool {
+	blacklistLock.Lock()
+	defer blacklistLock.Unlock()
+
+	_, ok := blacklist[versionedPath]
+	return ok
+}
+
+// BlacklistVersion marks a version of a component as broken. It will not be used anymore and the previous version is used instead, until a newer version is available. The available updates must be reloaded with LoadLatest afterwards.
+func BlacklistVersion(identifier, version, reason string) error {
+	blacklistLock.Lock()
+	defer blacklistLock.Unlock()
+
+	blacklist[GetVersionedPath(identifier, version)] = &blacklistEntry{
+		Reason: reason,
+		Time:   time.Now().Unix(),
+	}
+	return saveBlacklist()
+}
+
+// UnblacklistVersion removes a version of a component from the blacklist. If version is empty, all versions of the component are removed. It returns the removed versions. The available updates must be reloaded with LoadLatest afterwards.
+func UnblacklistVersion(identifier, version string) (removed []string, err error) {
+	err = loadBlacklist()
+	if err != nil {
+		return nil, err
+	}
+
+	blacklistLock.Lock()
+	defer blacklistLock.Unlock()
+
+	for versionedPath := range blacklist {
+		blacklistedIdentifier, blacklistedVersion, ok := GetIdentifierAndVersion(versionedPath)
+		if !ok || blacklistedIdentifier != identifier {
+			continue
+		}
+		if version == "" || version == blacklistedVersion {
+			delete(blacklist, versionedPath)
+			removed = append(removed, blacklistedVersion)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, saveBlacklist()
+}
+
+// saveBlacklist writes the blacklist to the update storage. blacklistLock must be locked.
+func saveBlacklist() error {
+	data, err := json.MarshalIndent(blacklist, "", "  ")
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(filepath.Join(updateStoragePath, blacklistFile), data, 0644)
+}
+
+// MarkVersionHealthy records that a version of a component ran successfully. Only versions newer than the last healthy one are rolled back after crashing.
+func MarkVersionHealthy(identifier, version string) error {
+	healthy, err := loadHealthyVersions()
+	if err != nil {
+		return err
+	}
+	if healthy[identifier] == version {
+		return nil
+	}
+	healthy[identifier] = version
+
+	data, err := json.MarshalIndent(healthy, "", "  ")
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(filepath.Join(updateStoragePath, healthyFile), data, 0644)
+}
+
+// LastHealthyVersion returns the version of a component that last ran successfully, or an empty string if none did yet.
+func LastHealthyVersion(identifier string) (string, error) {
+	healthy, err := loadHealthyVersions()
+	if err != nil {
+		return "", err
+	}
+	return healthy[identifier], nil
+}
+
+func loadHealthyVersions() (map[string]string, error) {
+	healthy := make(map[string]string)
+	data, err := ioutil.ReadFile(filepath.Join(updateStoragePath, healthyFile))
+	switch {
+	case os.IsNotExist(err):
+		return healthy, nil
+	case err != nil:
+		return nil, err
+	}
+	err = json.Unmarshal(data, &healthy)
+	if err != nil {
+		return nil, err
+	}
+	return healthy, nil
+}
+
+// updateBlacklistStatus records the blacklisted versions in the version status.
+func updateBlacklistStatus() {
+	blacklistLock.Lock()
+	defer blacklistLock.Unlock()
+	status.Lock()
+	defer status.Unlock()
+
+	for versionedPath, entry := range blacklist {
+		identifier, version, ok := GetIdentifierAndVersion(versionedPath)
+		if !ok {
+			continue
+		}
+
+		moduleStatus, ok := status.Modules[identifier]
+		if !ok {
+			moduleStatus = &VersionStatusEntry{}
+			status.Modules[identifier] = moduleStatus
+		}
+		if moduleStatus.BlacklistedVersions == nil {
+			moduleStatus.BlacklistedVersions = make(map[string]string)
+		}
+		moduleStatus.BlacklistedVersions[version] = entry.Reason
+	}
+
+	go status.save()
+}
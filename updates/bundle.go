@@ -0,0 +1,279 @@
+package updates
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/renameio"
+
+	"github.com/Safing/portbase/log"
+)
+
+// Update bundles are gzip compressed tar archives for installing updates without internet access. They contain the signed indexes of one or more channels, followed by the versioned files listed in them. All paths are relative to the update storage, eg. "stable.signed.json" and "linux_amd64/core/portmaster_v0-2-5".
+
+const (
+	// BundleExtension is the file extension of update bundles.
+	BundleExtension = ".tar.gz"
+
+	maxBundleIndexSize = 10 << 20 // 10MB
+)
+
+// ImportBundle verifies the update bundle at the given path, installs its files and indexes to the update storage and reloads the available updates. It returns the number of newly installed files.
+func ImportBundle(bundlePath string) (installed int, err error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read bundle: %s", err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	err = CheckDir(filepath.Join(updateStoragePath, "tmp"))
+	if err != nil {
+		return 0, fmt.Errorf("could not prepare tmp directory for import: %s", err)
+	}
+
+	indexes := make(map[string][]byte)
+	hashes := make(map[string]string)
+	var filesStarted bool
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return installed, fmt.Errorf("failed to read bundle: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, err := cleanBundlePath(header.Name)
+		if err != nil {
+			return installed, err
+		}
+
+		// indexes
+		if channel, ok := bundleIndexChannel(name); ok {
+			if filesStarted {
+				return installed, errors.New("invalid bundle: indexes must precede all files")
+			}
+			data, index, err := readBundleIndex(tarReader, channel)
+			if err != nil {
+				return installed, err
+			}
+			indexes[channel] = data
+			for versionedPath, hash := range index.Hashes {
+				hashes[versionedPath] = hash
+			}
+			continue
+		}
+
+		// files
+		filesStarted = true
+		hash, ok := hashes[name]
+		if !ok {
+			return installed, fmt.Errorf("refusing to import %s: %s", name, ErrNoHash)
+		}
+		realFilePath := filepath.Join(updateStoragePath, filepath.FromSlash(name))
+		if _, err := os.Stat(realFilePath); err == nil {
+			// already installed
+			continue
+		}
+		err = writeVerifiedFile(realFilePath, tarReader, header.Size, hash)
+		if err != nil {
+			return installed, fmt.Errorf("failed to import %s: %s", name, err)
+		}
+		installed++
+		log.Infof("updates: imported %s", name)
+	}
+	if len(indexes) == 0 {
+		return installed, errors.New("invalid bundle: no index found")
+	}
+
+	// save indexes after all files are in place
+	for channel, data := range indexes {
+		err = renameio.WriteFile(filepath.Join(updateStoragePath, indexFileName(channel)), data, 0644)
+		if err != nil {
+			return installed, fmt.Errorf("failed to save %s: %s", indexFileName(channel), err)
+		}
+	}
+
+	// reload
+	err = LoadIndexes()
+	if err != nil && !os.IsNotExist(err) {
+		return installed, err
+	}
+	return installed, LoadLatest()
+}
+
+// readBundleIndex reads and verifies an index from a bundle and refuses to roll back the stored index.
+func readBundleIndex(r io.Reader, channel string) ([]byte, *Index, error) {
+	indexFile := indexFileName(channel)
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBundleIndexSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %s", indexFile, err)
+	}
+	index, err := verifyIndex(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to verify %s: %s", indexFile, err)
+	}
+
+	storedIndex, err := loadStoredIndex(channel)
+	if err == nil && index.Published < storedIndex.Published {
+		return nil, nil, fmt.Errorf("%s is older than the current index, refusing to roll back", indexFile)
+	}
+	return data, index, nil
+}
+
+// WriteBundle writes an update bundle with the given signed indexes and the files listed in them. The files are read from baseDir and are checked against the hashes of the indexes.
+func WriteBundle(w io.Writer, baseDir string, indexPaths []string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	hashes := make(map[string]string)
+	for _, indexPath := range indexPaths {
+		name := filepath.Base(indexPath)
+		if _, ok := bundleIndexChannel(name); !ok {
+			return fmt.Errorf("invalid index name %s, must be <channel>.signed.json", name)
+		}
+
+		data, err := ioutil.ReadFile(indexPath)
+		if err != nil {
+			return err
+		}
+		si, err := ParseSignedIndex(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %s", indexPath, err)
+		}
+		index := &Index{}
+		err = json.Unmarshal(si.Index, index)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %s", indexPath, err)
+		}
+		for versionedPath, hash := range index.Hashes {
+			hashes[versionedPath] = hash
+		}
+
+		err = writeBundleEntry(tarWriter, name, indexPath, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	versionedPaths := make([]string, 0, len(hashes))
+	for versionedPath := range hashes {
+		versionedPaths = append(versionedPaths, versionedPath)
+	}
+	sort.Strings(versionedPaths)
+	for _, versionedPath := range versionedPaths {
+		err := writeBundleEntry(tarWriter, versionedPath, filepath.Join(baseDir, filepath.FromSlash(versionedPath)), hashes[versionedPath])
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func writeBundleEntry(tarWriter *tar.Writer, name, filePath, expectedHash string) error {
+	if expectedHash != "" {
+		hash, err := HashFile(filePath)
+		if err != nil {
+			return err
+		}
+		if hash != expectedHash {
+			return fmt.Errorf("%s: %s", filePath, ErrHashMismatch)
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
+
+// cleanBundlePath checks that a path of a bundle stays within the update storage.
+func cleanBundlePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid bundle: illegal path %s", name)
+	}
+	return cleaned, nil
+}
+
+func bundleIndexChannel(name string) (channel string, ok bool) {
+	for _, channel := range []string{ChannelStable, ChannelBeta, ChannelDev} {
+		if name == indexFileName(channel) {
+			return channel, true
+		}
+	}
+	return "", false
+}
+
+func bundleImporter() {
+	for {
+		importDroppedBundles()
+		time.Sleep(1 * time.Minute)
+	}
+}
+
+// importDroppedBundles imports all bundles that were placed in the import directory of the update storage. Imported bundles are removed, failed bundles are renamed to prevent retrying.
+func importDroppedBundles() {
+	importDir := filepath.Join(updateStoragePath, "import")
+	bundles, err := filepath.Glob(filepath.Join(importDir, "*"+BundleExtension))
+	if err != nil || len(bundles) == 0 {
+		return
+	}
+
+	for _, bundlePath := range bundles {
+		installed, err := ImportBundle(bundlePath)
+		if err != nil {
+			log.Warningf("updates: failed to import bundle %s: %s", bundlePath, err)
+			err = os.Rename(bundlePath, bundlePath+".failed")
+		} else {
+			log.Infof("updates: imported bundle %s with %d new files", bundlePath, installed)
+			err = os.Remove(bundlePath)
+		}
+		if err != nil {
+			log.Warningf("updates: failed to clean up bundle %s: %s", bundlePath, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package updates
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestBundle(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKeysOnce.Do(func() {})
+	oldKeys, oldKeysErr := trustedKeys, trustedKeysErr
+	trustedKeys, trustedKeysErr = map[string]ed25519.PublicKey{"test": publicKey}, nil
+	defer func() {
+		trustedKeys, trustedKeysErr = oldKeys, oldKeysErr
+	}()
+
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// build release dir
+	releaseDir := filepath.Join(tmpDir, "release")
+	versionedPath := "all/ui/assets_v1-2-3.zip"
+	err = os.MkdirAll(filepath.Join(releaseDir, "all/ui"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(releaseDir, filepath.FromSlash(versionedPath)), []byte("assets"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewIndex(map[string]string{"all/ui/assets.zip": "1.2.3"})
+	index.Hashes[versionedPath], err = HashFile(filepath.Join(releaseDir, filepath.FromSlash(versionedPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	si, err := SignIndex(index, "test", privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPath := filepath.Join(releaseDir, indexFileName(ChannelStable))
+	err = ioutil.WriteFile(indexPath, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// build bundle
+	bundlePath := filepath.Join(tmpDir, "bundle"+BundleExtension)
+	buf := &bytes.Buffer{}
+	err = WriteBundle(buf, releaseDir, []string{indexPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(bundlePath, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// import bundle
+	oldStoragePath := updateStoragePath
+	updateStoragePath = filepath.Join(tmpDir, "storage")
+	defer func() {
+		updateStoragePath = oldStoragePath
+	}()
+
+	installed, err := ImportBundle(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed != 1 {
+		t.Errorf("expected 1 installed file, got %d", installed)
+	}
+	if _, err := os.Stat(filepath.Join(updateStoragePath, filepath.FromSlash(versionedPath))); err != nil {
+		t.Errorf("file should be installed: %s", err)
+	}
+	if getFileHash(versionedPath) != index.Hashes[versionedPath] {
+		t.Error("index should be loaded")
+	}
+
+	// a modified file must not be bundled
+	err = ioutil.WriteFile(filepath.Join(releaseDir, filepath.FromSlash(versionedPath)), []byte("malicious"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteBundle(&bytes.Buffer{}, releaseDir, []string{indexPath})
+	if err == nil {
+		t.Error("bundle with modified file should be rejected")
+	}
+}
+
+func TestCleanBundlePath(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "/etc/passwd", "all/../../x", "."} {
+		if _, err := cleanBundlePath(name); err == nil {
+			t.Errorf("%s should be rejected", name)
+		}
+	}
+	cleaned, err := cleanBundlePath("./all/ui/assets_v1-2-3.zip")
+	if err != nil || cleaned != "all/ui/assets_v1-2-3.zip" {
+		t.Errorf("unexpected result: %s, %v", cleaned, err)
+	}
+}
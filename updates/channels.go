@@ -0,0 +1,93 @@
+package updates
+
+import (
+	"strings"
+)
+
+// Release Channels
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+	ChannelDev    = "dev"
+
+	// channelPinned and channelLocal mark versions that were not selected from an index.
+	channelPinned = "pinned"
+	channelLocal  = "local"
+)
+
+func indexFileName(channel string) string {
+	return channel + ".signed.json"
+}
+
+// activeChannels returns the channels whose indexes are used with the selected release channel.
+func activeChannels() []string {
+	switch updateChannel() {
+	case ChannelDev:
+		return []string{ChannelStable, ChannelBeta, ChannelDev}
+	case ChannelBeta:
+		return []string{ChannelStable, ChannelBeta}
+	default:
+		return []string{ChannelStable}
+	}
+}
+
+// channelUpdates returns the versions of the given channel. updatesLock must be locked.
+func channelUpdates(channel string) map[string]string {
+	switch channel {
+	case ChannelStable:
+		return stableUpdates
+	case ChannelBeta:
+		return betaUpdates
+	case ChannelDev:
+		return devUpdates
+	default:
+		return nil
+	}
+}
+
+// getPinnedVersion returns the version the given identifier is pinned to. Pins may omit the platform prefix of the identifier.
+func getPinnedVersion(identifier string) (version string, ok bool) {
+	shortIdentifier := identifier
+	if i := strings.Index(identifier, "/"); i >= 0 {
+		shortIdentifier = identifier[i+1:]
+	}
+
+	for _, pin := range pinnedVersions() {
+		parts := strings.SplitN(pin, "@", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if parts[0] == identifier || parts[0] == shortIdentifier {
+			return parts[1], true
+		}
+	}
+	return "", false
+}
+
+// selectVersion returns the version of the given identifier that should be used and the channel it was selected from. The highest version of all active channels is selected, unless the identifier is pinned. Blacklisted versions are skipped. updatesLock must be locked.
+func selectVersion(identifier string) (version, channel string, ok bool) {
+	version, ok = getPinnedVersion(identifier)
+	if ok {
+		return version, channelPinned, true
+	}
+
+	for _, activeChannel := range activeChannels() {
+		channelVersion, ok := channelUpdates(activeChannel)[identifier]
+		if !ok || isBlacklisted(GetVersionedPath(identifier, channelVersion)) {
+			continue
+		}
+		if version == "" || CompareVersions(channelVersion, version) > 0 {
+			version = channelVersion
+			channel = activeChannel
+		}
+	}
+	if version != "" {
+		return version, channel, true
+	}
+
+	version, ok = localUpdates[identifier]
+	if ok {
+		return version, channelLocal, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,87 @@
+package updates
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Safing/portbase/log"
+)
+
+// cleanupStorage deletes old versions of all components from the update storage. The selected version, the configured amount of previous versions and versions in use are kept.
+func cleanupStorage() {
+	keep := int(keepVersions())
+
+	var toDelete []string
+	updatesLock.RLock()
+	for identifier, versions := range localVersions {
+		current, _, ok := selectVersion(identifier)
+		if !ok {
+			continue
+		}
+		toDelete = append(toDelete, selectForDeletion(identifier, versions, current, keep, getUsedVersions(identifier))...)
+	}
+	updatesLock.RUnlock()
+
+	if len(toDelete) == 0 {
+		return
+	}
+	for _, versionedPath := range toDelete {
+		err := os.Remove(filepath.Join(updateStoragePath, filepath.FromSlash(versionedPath)))
+		if err != nil {
+			log.Warningf("updates: failed to delete old version %s: %s", versionedPath, err)
+		} else {
+			log.Infof("updates: deleted old version %s", versionedPath)
+		}
+	}
+
+	err := LoadLatest()
+	if err != nil {
+		log.Warningf("updates: failed to reload updates after cleanup: %s", err)
+	}
+}
+
+// selectForDeletion returns the versioned paths of the versions that exceed the retention. Newer versions than the current one are only deleted, if they are blacklisted.
+func selectForDeletion(identifier string, versions []string, current string, keep int, inUse map[string]bool) (toDelete []string) {
+	sorted := make([]string, len(versions))
+	copy(sorted, versions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return CompareVersions(sorted[i], sorted[j]) > 0
+	})
+
+	var kept int
+	for _, version := range sorted {
+		versionedPath := GetVersionedPath(identifier, version)
+		blacklisted := isBlacklisted(versionedPath)
+		cmp := CompareVersions(version, current)
+
+		switch {
+		case cmp == 0, inUse[version]:
+			continue
+		case cmp > 0 && !blacklisted:
+			continue
+		case cmp < 0 && !blacklisted && kept < keep:
+			kept++
+			continue
+		}
+		toDelete = append(toDelete, versionedPath)
+	}
+	return toDelete
+}
+
+// getUsedVersions returns the versions of the identifier that are known to be in use.
+func getUsedVersions(identifier string) map[string]bool {
+	status.Lock()
+	defer status.Unlock()
+
+	inUse := make(map[string]bool)
+	if entry, ok := status.Modules[identifier]; ok && entry.LastVersionUsed != "" {
+		inUse[entry.LastVersionUsed] = true
+	}
+	// the running core is started by pmctl and not recorded
+	if strings.HasSuffix(identifier, coreIdentifier) && status.Core != nil {
+		inUse[status.Core.Version] = true
+	}
+	return inUse
+}
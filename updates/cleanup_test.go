@@ -0,0 +1,164 @@
+package updates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSelectForDeletion(t *testing.T) {
+	blacklistLock.Lock()
+	blacklist = map[string]*blacklistEntry{
+		"all/test/file_v1-12-0": {Reason: "test"},
+	}
+	blacklistLock.Unlock()
+	defer func() {
+		blacklistLock.Lock()
+		blacklist = make(map[string]*blacklistEntry)
+		blacklistLock.Unlock()
+	}()
+
+	versions := []string{"1.2.0", "1.9.0", "1.10.0", "1.11.0", "1.12.0", "1.13.0", "1.8.0"}
+	toDelete := selectForDeletion("all/test/file", versions, "1.11.0", 2, map[string]bool{"1.2.0": true})
+	sort.Strings(toDelete)
+
+	expected := []string{"all/test/file_v1-12-0", "all/test/file_v1-8-0"}
+	if !reflect.DeepEqual(toDelete, expected) {
+		t.Errorf("expected %v, got %v", expected, toDelete)
+	}
+}
+
+func TestBlacklistFallback(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldStoragePath := updateStoragePath
+	updateStoragePath = tmpDir
+	defer func() {
+		updateStoragePath = oldStoragePath
+		blacklistLock.Lock()
+		blacklist = make(map[string]*blacklistEntry)
+		blacklistLock.Unlock()
+	}()
+
+	err = os.MkdirAll(filepath.Join(tmpDir, "all/test"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"file_v1-9-0", "file_v1-10-0"} {
+		err = ioutil.WriteFile(filepath.Join(tmpDir, "all/test", name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = LoadLatest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := GetLocalFile("test/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version() != "1.10.0" {
+		t.Fatalf("expected 1.10.0, got %s", file.Version())
+	}
+	fallback, ok := file.Fallback()
+	if !ok || fallback != "1.9.0" {
+		t.Fatalf("expected fallback 1.9.0, got %s", fallback)
+	}
+
+	err = file.Blacklist("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = GetLocalFile("test/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version() != "1.9.0" {
+		t.Errorf("expected rollback to 1.9.0, got %s", file.Version())
+	}
+	if _, ok := file.Fallback(); ok {
+		t.Error("there should be no fallback left")
+	}
+}
+
+func TestUnblacklistAndHealthyVersions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldStoragePath := updateStoragePath
+	updateStoragePath = tmpDir
+	defer func() {
+		updateStoragePath = oldStoragePath
+		blacklistLock.Lock()
+		blacklist = make(map[string]*blacklistEntry)
+		blacklistLock.Unlock()
+	}()
+
+	for _, version := range []string{"1.9.0", "1.10.0"} {
+		err = BlacklistVersion("all/test/file", version, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = BlacklistVersion("all/other/file", "1.0.0", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := UnblacklistVersion("all/test/file", "1.10.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"1.10.0"}) {
+		t.Errorf("expected 1.10.0 to be removed, got %v", removed)
+	}
+	if isBlacklisted("all/test/file_v1-10-0") || !isBlacklisted("all/test/file_v1-9-0") {
+		t.Error("only 1.10.0 should have been removed from the blacklist")
+	}
+
+	removed, err = UnblacklistVersion("all/test/file", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"1.9.0"}) {
+		t.Errorf("expected 1.9.0 to be removed, got %v", removed)
+	}
+	if !isBlacklisted("all/other/file_v1-0-0") {
+		t.Error("other files should stay blacklisted")
+	}
+
+	// blacklist must be persisted
+	err = loadBlacklist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isBlacklisted("all/test/file_v1-9-0") || !isBlacklisted("all/other/file_v1-0-0") {
+		t.Error("blacklist was not saved")
+	}
+
+	// healthy versions
+	version, err := LastHealthyVersion("all/test/file")
+	if err != nil || version != "" {
+		t.Errorf("expected no healthy version, got %q (%v)", version, err)
+	}
+	err = MarkVersionHealthy("all/test/file", "1.9.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err = LastHealthyVersion("all/test/file")
+	if err != nil || version != "1.9.0" {
+		t.Errorf("expected healthy version 1.9.0, got %q (%v)", version, err)
+	}
+}
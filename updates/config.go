@@ -0,0 +1,159 @@
+package updates
+
+import (
+	"github.com/Safing/portbase/config"
+	"github.com/Safing/portbase/database"
+	"github.com/Safing/portbase/database/query"
+	"github.com/Safing/portbase/log"
+	"github.com/Safing/portbase/modules"
+)
+
+var (
+	// defaults are used until the config is registered, eg. when used by pmctl
+	updateChannel  = func() string { return ChannelStable }
+	pinnedVersions = func() []string { return nil }
+	updateMirrors  = func() []string { return updateURLs }
+	updateProxy    = func() string { return "" }
+	bandwidthLimit = func() int64 { return 0 }
+	keepVersions   = func() int64 { return defaultKeepVersions }
+)
+
+const (
+	defaultKeepVersions = 2
+
+	channelConfigKey = "config:updates/channel"
+)
+
+func registerConfig() error {
+	err := config.Register(&config.Option{
+		Name:            "Release Channel",
+		Key:             "updates/channel",
+		Description:     "The release channel to receive updates from: stable, beta or dev. Beta and dev also receive stable releases, if they are newer.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeString,
+		DefaultValue:    ChannelStable,
+		ValidationRegex: "^(stable|beta|dev)$",
+	})
+	if err != nil {
+		return err
+	}
+	updateChannel = config.Concurrent.GetAsString("updates/channel", ChannelStable)
+
+	err = config.Register(&config.Option{
+		Name:            "Pinned Versions",
+		Key:             "updates/pinnedVersions",
+		Description:     "Pin components to a version, overriding the release channel. Format: <identifier>@<version>, eg. core/portmaster@0.2.5.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeStringArray,
+		DefaultValue:    []string{},
+		ValidationRegex: `^[a-zA-Z0-9_./-]+@[0-9]+\.[0-9]+\.[0-9]+b?$`,
+	})
+	if err != nil {
+		return err
+	}
+	pinnedVersions = config.Concurrent.GetAsStringArray("updates/pinnedVersions", []string{})
+
+	err = config.Register(&config.Option{
+		Name:            "Update Mirrors",
+		Key:             "updates/mirrors",
+		Description:     "Servers to download updates from. Failing mirrors are avoided for a while.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeStringArray,
+		DefaultValue:    updateURLs,
+		ValidationRegex: "^https?://.+",
+	})
+	if err != nil {
+		return err
+	}
+	updateMirrors = config.Concurrent.GetAsStringArray("updates/mirrors", updateURLs)
+
+	err = config.Register(&config.Option{
+		Name:            "Update Proxy",
+		Key:             "updates/proxy",
+		Description:     "Download updates through an HTTP or SOCKS5 proxy, eg. socks5://127.0.0.1:9050. Leave empty to connect directly.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeString,
+		DefaultValue:    "",
+		ValidationRegex: "^((http|https|socks5)://.+)?$",
+	})
+	if err != nil {
+		return err
+	}
+	updateProxy = config.Concurrent.GetAsString("updates/proxy", "")
+
+	err = config.Register(&config.Option{
+		Name:            "Update Bandwidth Limit",
+		Key:             "updates/bandwidthLimit",
+		Description:     "Limit update downloads to this many kilobytes per second. Set to 0 to disable the limit.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    0,
+		ValidationRegex: "^[0-9]{1,7}$",
+	})
+	if err != nil {
+		return err
+	}
+	bandwidthLimit = config.Concurrent.GetAsInt("updates/bandwidthLimit", 0)
+
+	err = config.Register(&config.Option{
+		Name:            "Keep Previous Versions",
+		Key:             "updates/keepPreviousVersions",
+		Description:     "Amount of previous versions of each component to keep for rolling back. Older versions are deleted.",
+		ExpertiseLevel:  config.ExpertiseLevelExpert,
+		OptType:         config.OptTypeInt,
+		DefaultValue:    defaultKeepVersions,
+		ValidationRegex: "^[0-9]{1,2}$",
+	})
+	if err != nil {
+		return err
+	}
+	keepVersions = config.Concurrent.GetAsInt("updates/keepPreviousVersions", defaultKeepVersions)
+
+	return nil
+}
+
+// initChannelListener re-applies the release channel when it is changed.
+func initChannelListener() error {
+	sub, err := database.NewInterface(nil).Subscribe(query.New(channelConfigKey))
+	if err != nil {
+		return err
+	}
+
+	go channelListener(sub)
+	return nil
+}
+
+func channelListener(sub *database.Subscription) {
+	defer sub.Cancel()
+
+	activeChannel := updateChannel()
+	for {
+		select {
+		case <-modules.ShuttingDown():
+			return
+		case r := <-sub.Feed:
+			if r == nil {
+				return
+			}
+
+			channel := updateChannel()
+			if channel == activeChannel {
+				continue
+			}
+			log.Infof("updates: release channel changed from %s to %s", activeChannel, channel)
+			activeChannel = channel
+
+			// use the stored indexes of the new channels right away, then fetch current ones
+			err := LoadIndexes()
+			if err != nil {
+				log.Warningf("updates: failed to load indexes: %s", err)
+			}
+			go func() {
+				err := CheckForUpdates()
+				if err != nil {
+					log.Warningf("updates: failed to check for updates: %s", err)
+				}
+			}()
+		}
+	}
+}
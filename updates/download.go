@@ -0,0 +1,349 @@
+package updates
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Safing/portbase/log"
+)
+
+const (
+	mirrorFailureExpiry    = 10 * time.Minute
+	downloadStatusInterval = 1 * time.Second
+)
+
+var (
+	localAddrFactory func(network string) net.Addr
+
+	httpClient      *http.Client
+	httpClientProxy string
+	httpClientLock  sync.Mutex
+
+	mirrorHealth     = make(map[string]*mirrorStatus)
+	mirrorHealthLock sync.Mutex
+
+	// downloadLocks serializes downloads of the same file, as they share the partial download file.
+	downloadLocks     = make(map[string]*downloadLock)
+	downloadLocksLock sync.Mutex
+)
+
+type downloadLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockDownload locks the download of the file with the given hash and returns the function to unlock it again.
+func lockDownload(hash string) (unlock func()) {
+	downloadLocksLock.Lock()
+	dl, ok := downloadLocks[hash]
+	if !ok {
+		dl = &downloadLock{}
+		downloadLocks[hash] = dl
+	}
+	dl.users++
+	downloadLocksLock.Unlock()
+
+	dl.Lock()
+	return func() {
+		dl.Unlock()
+
+		downloadLocksLock.Lock()
+		defer downloadLocksLock.Unlock()
+		dl.users--
+		if dl.users == 0 {
+			delete(downloadLocks, hash)
+		}
+	}
+}
+
+// SetLocalAddrFactory supplies the updates package with a function to set local addresses for connections, so that downloads are permitted by the firewall.
+func SetLocalAddrFactory(laf func(network string) net.Addr) {
+	if localAddrFactory == nil {
+		localAddrFactory = laf
+	}
+}
+
+// getHTTPClient returns the HTTP client for downloads. It is rebuilt when the proxy setting changes.
+func getHTTPClient() (*http.Client, error) {
+	proxySetting := updateProxy()
+
+	httpClientLock.Lock()
+	defer httpClientLock.Unlock()
+
+	if httpClient != nil && httpClientProxy == proxySetting {
+		return httpClient, nil
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialPermitted,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+	if proxySetting != "" {
+		proxyURL, err := url.Parse(proxySetting)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %s", proxySetting, err)
+		}
+		// net/http supports http, https and socks5 proxies
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	httpClient = &http.Client{
+		Transport: transport,
+	}
+	httpClientProxy = proxySetting
+	return httpClient, nil
+}
+
+func dialPermitted(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if localAddrFactory != nil {
+		if localAddr, ok := localAddrFactory("tcp").(*net.TCPAddr); ok && localAddr != nil {
+			dialer.LocalAddr = localAddr
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+type mirrorStatus struct {
+	failures    int
+	lastFailure time.Time
+}
+
+// getMirrors returns the configured mirrors, ordered by their recent failures.
+func getMirrors() []string {
+	mirrors := updateMirrors()
+	if len(mirrors) == 0 {
+		mirrors = updateURLs
+	}
+	sorted := make([]string, len(mirrors))
+	copy(sorted, mirrors)
+
+	mirrorHealthLock.Lock()
+	defer mirrorHealthLock.Unlock()
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return recentMirrorFailures(sorted[i]) < recentMirrorFailures(sorted[j])
+	})
+	return sorted
+}
+
+// recentMirrorFailures returns the amount of recent failures of the mirror. mirrorHealthLock must be locked.
+func recentMirrorFailures(mirror string) int {
+	ms, ok := mirrorHealth[mirror]
+	if !ok || time.Since(ms.lastFailure) > mirrorFailureExpiry {
+		return 0
+	}
+	return ms.failures
+}
+
+// reportMirror records the result of a request to the mirror.
+func reportMirror(mirror string, err error) {
+	mirrorHealthLock.Lock()
+	defer mirrorHealthLock.Unlock()
+
+	if err == nil {
+		delete(mirrorHealth, mirror)
+		return
+	}
+
+	ms, ok := mirrorHealth[mirror]
+	if !ok || time.Since(ms.lastFailure) > mirrorFailureExpiry {
+		ms = &mirrorStatus{}
+		mirrorHealth[mirror] = ms
+	}
+	ms.failures++
+	ms.lastFailure = time.Now()
+}
+
+// downloadFile downloads the file from the mirror into the tmp dir, verifies it and moves it to realFilepath. Partial downloads are kept and resumed on the next call.
+func downloadFile(mirror, updateFilepath, realFilepath, expectedHash string) error {
+	downloadURL, err := joinURLandPath(mirror, updateFilepath)
+	if err != nil {
+		return fmt.Errorf("error build url (%s + %s): %s", mirror, updateFilepath, err)
+	}
+	client, err := getHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	// wait for concurrent downloads of the same file, they may have already finished it
+	unlock := lockDownload(expectedHash)
+	defer unlock()
+	if hash, err := HashFile(realFilepath); err == nil && hash == expectedHash {
+		return nil
+	}
+
+	// open partial file, it is named after the hash to resume across mirrors
+	tmpDir := filepath.Join(updateStoragePath, "tmp")
+	err = CheckDir(tmpDir)
+	if err != nil {
+		return fmt.Errorf("could not prepare tmp directory for download: %s", err)
+	}
+	partialPath := filepath.Join(tmpDir, expectedHash+".part")
+	partialFile, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open partial download file: %s", err)
+	}
+	defer partialFile.Close()
+	offset, err := partialFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", downloadURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching url (%s): %s", downloadURL, err)
+	}
+	defer resp.Body.Close()
+
+	complete := false
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		log.Tracef("updates: resuming download of %s at %d bytes", updateFilepath, offset)
+	case http.StatusOK:
+		// start over, if the server does not support resuming
+		if offset > 0 {
+			err = partialFile.Truncate(0)
+			if err != nil {
+				return err
+			}
+			_, err = partialFile.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			offset = 0
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is already complete
+		complete = offset > 0
+		if !complete {
+			return fmt.Errorf("unexpected response status: %s", resp.Status)
+		}
+	default:
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	if !complete {
+		size := int64(-1)
+		if resp.ContentLength >= 0 {
+			size = offset + resp.ContentLength
+		}
+		reader := &downloadReader{
+			r:             resp.Body,
+			versionedPath: updateFilepath,
+			mirror:        mirror,
+			size:          size,
+			downloaded:    offset,
+			limit:         bandwidthLimit() * 1024,
+			started:       time.Now(),
+		}
+		defer removeDownloadStatus(updateFilepath)
+
+		n, err := io.Copy(partialFile, reader)
+		if err != nil {
+			return fmt.Errorf("failed downloading %s: %s", downloadURL, err)
+		}
+		if resp.ContentLength >= 0 && resp.ContentLength != n {
+			return fmt.Errorf("download unfinished, written %d out of %d bytes", n, resp.ContentLength)
+		}
+	}
+	err = partialFile.Close()
+	if err != nil {
+		return err
+	}
+
+	// verify file before putting it in place
+	hash, err := HashFile(partialPath)
+	if err != nil {
+		return err
+	}
+	if hash != expectedHash {
+		_ = os.Remove(partialPath)
+		return fmt.Errorf("rejecting %s: %s", downloadURL, ErrHashMismatch)
+	}
+
+	// finalize file
+	err = CheckDir(filepath.Dir(realFilepath))
+	if err != nil {
+		return fmt.Errorf("could not create updates folder: %s", err)
+	}
+	err = os.Rename(partialPath, realFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to finalize file %s: %s", realFilepath, err)
+	}
+	// set permissions
+	if runtime.GOOS != "windows" {
+		err = os.Chmod(realFilepath, 0644)
+		if err != nil {
+			log.Warningf("updates: failed to set permissions on downloaded file %s: %s", realFilepath, err)
+		}
+	}
+
+	return nil
+}
+
+// downloadReader limits the bandwidth of a download and publishes its progress.
+type downloadReader struct {
+	r             io.Reader
+	versionedPath string
+	mirror        string
+	size          int64
+	downloaded    int64
+	// limit is the bandwidth limit in bytes per second, 0 disables the limit.
+	limit        int64
+	started      time.Time
+	limitedBytes int64
+	lastStatus   time.Time
+}
+
+func (dr *downloadReader) Read(p []byte) (n int, err error) {
+	if dr.limit > 0 && int64(len(p)) > dr.limit {
+		p = p[:dr.limit]
+	}
+
+	n, err = dr.r.Read(p)
+	dr.downloaded += int64(n)
+
+	// sleep until the limit is met
+	if dr.limit > 0 {
+		dr.limitedBytes += int64(n)
+		expected := time.Duration(float64(dr.limitedBytes) / float64(dr.limit) * float64(time.Second))
+		if wait := expected - time.Since(dr.started); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	if time.Since(dr.lastStatus) > downloadStatusInterval {
+		dr.lastStatus = time.Now()
+		updateDownloadStatus(dr.versionedPath, &DownloadStatus{
+			Mirror:     dr.mirror,
+			Size:       dr.size,
+			Downloaded: dr.downloaded,
+			Started:    dr.started.Unix(),
+		})
+	}
+	return n, err
+}
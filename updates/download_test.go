@@ -0,0 +1,166 @@
+package updates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadResumeAndFailover(t *testing.T) {
+	content := bytes.Repeat([]byte("portmaster"), 1000)
+	hash := sha256.Sum256(content)
+	expectedHash := hex.EncodeToString(hash[:])
+
+	var rangeRequested bool
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			rangeRequested = true
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer goodServer.Close()
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer badServer.Close()
+
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldMirrors, oldStoragePath := updateMirrors, updateStoragePath
+	updateMirrors = func() []string { return []string{badServer.URL, goodServer.URL} }
+	updateStoragePath = tmpDir
+	defer func() {
+		updateMirrors, updateStoragePath = oldMirrors, oldStoragePath
+	}()
+
+	// place partial download
+	err = CheckDir(filepath.Join(tmpDir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "tmp", expectedHash+".part"), content[:1234], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// first try fails on the bad mirror
+	realFilePath := filepath.Join(tmpDir, "all/test/file_v1-0-0")
+	err = fetchFile(realFilePath, "all/test/file_v1-0-0", expectedHash, 0)
+	if err == nil {
+		t.Fatal("first try should fail on the bad mirror")
+	}
+	if getMirrors()[0] != goodServer.URL {
+		t.Fatal("failing mirror should be avoided")
+	}
+
+	// second try resumes on the good mirror
+	err = fetchFile(realFilePath, "all/test/file_v1-0-0", expectedHash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rangeRequested {
+		t.Error("download should have been resumed")
+	}
+	data, err := ioutil.ReadFile(realFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("downloaded file does not match")
+	}
+}
+
+func TestDownloadReaderLimit(t *testing.T) {
+	dr := &downloadReader{
+		r:       bytes.NewReader(make([]byte, 3000)),
+		limit:   10000,
+		started: time.Now(),
+	}
+	n, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n) != 3000 {
+		t.Fatalf("expected 3000 bytes, got %d", len(n))
+	}
+	if elapsed := time.Since(dr.started); elapsed < 250*time.Millisecond {
+		t.Errorf("download should be limited, took only %s", elapsed)
+	}
+}
+
+func TestConcurrentDownloads(t *testing.T) {
+	content := bytes.Repeat([]byte("portmaster"), 10000)
+	hash := sha256.Sum256(content)
+	expectedHash := hex.EncodeToString(hash[:])
+
+	var requestsLock sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsLock.Lock()
+		requests++
+		requestsLock.Unlock()
+		// deliver slowly, so that downloads overlap
+		for i := 0; i < len(content); i += 10000 {
+			_, _ = w.Write(content[i : i+10000])
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldStoragePath := updateStoragePath
+	updateStoragePath = tmpDir
+	defer func() {
+		updateStoragePath = oldStoragePath
+	}()
+
+	realFilePath := filepath.Join(tmpDir, "all/test/file_v1-0-0")
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- downloadFile(server.URL, "all/test/file_v1-0-0", realFilePath, expectedHash)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(realFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("downloaded file does not match")
+	}
+	if requests != 1 {
+		t.Errorf("file should have been downloaded once, was requested %d times", requests)
+	}
+	if len(downloadLocks) != 0 {
+		t.Error("download locks should be released")
+	}
+}
@@ -2,6 +2,8 @@ package updates
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,21 +25,34 @@ var (
 	}
 )
 
-func fetchFile(realFilepath, updateFilepath string, tries int) error {
+func fetchFile(realFilepath, updateFilepath, expectedHash string, tries int) error {
+	// only download files that are listed in the signed index
+	if expectedHash == "" {
+		return fmt.Errorf("updates: refusing to download %s: %s", updateFilepath, ErrNoHash)
+	}
+
 	// backoff when retrying
 	if tries > 0 {
 		time.Sleep(time.Duration(tries*tries) * time.Second)
 	}
 
-	// create URL
-	downloadURL, err := joinURLandPath(updateURLs[tries%len(updateURLs)], updateFilepath)
+	// download from the healthiest mirror
+	mirror := getMirrors()[0]
+	err := downloadFile(mirror, updateFilepath, realFilepath, expectedHash)
+	reportMirror(mirror, err)
 	if err != nil {
-		return fmt.Errorf("error build url (%s + %s): %s", updateURLs[tries%len(updateURLs)], updateFilepath, err)
+		return fmt.Errorf("updates: failed to download %s from %s: %s", updateFilepath, mirror, err)
 	}
 
+	log.Infof("updates: fetched %s from %s (stored to %s)", updateFilepath, mirror, realFilepath)
+	return nil
+}
+
+// writeVerifiedFile writes the data of the reader to the given path, if it has the expected size and hash. A size of -1 skips the size check.
+func writeVerifiedFile(realFilepath string, r io.Reader, expectedSize int64, expectedHash string) error {
 	// check destination dir
 	dirPath := filepath.Dir(realFilepath)
-	err = CheckDir(dirPath)
+	err := CheckDir(dirPath)
 	if err != nil {
 		return fmt.Errorf("updates: could not create updates folder: %s", dirPath)
 	}
@@ -45,24 +60,23 @@ func fetchFile(realFilepath, updateFilepath string, tries int) error {
 	// open file for writing
 	atomicFile, err := renameio.TempFile(filepath.Join(updateStoragePath, "tmp"), realFilepath)
 	if err != nil {
-		return fmt.Errorf("updates: could not create temp file for download: %s", err)
+		return fmt.Errorf("updates: could not create temp file: %s", err)
 	}
 	defer atomicFile.Cleanup()
 
-	// start file download
-	resp, err := http.Get(downloadURL)
+	// write file
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(atomicFile, hasher), r)
 	if err != nil {
-		return fmt.Errorf("error fetching url (%s): %s", downloadURL, err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	// download and write file
-	n, err := io.Copy(atomicFile, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed downloading %s: %s", downloadURL, err)
+	if expectedSize >= 0 && expectedSize != n {
+		return fmt.Errorf("unfinished, written %d out of %d bytes", n, expectedSize)
 	}
-	if resp.ContentLength != n {
-		return fmt.Errorf("download unfinished, written %d out of %d bytes.", n, resp.ContentLength)
+
+	// verify file before putting it in place
+	if hex.EncodeToString(hasher.Sum(nil)) != expectedHash {
+		return ErrHashMismatch
 	}
 
 	// finalize file
@@ -74,11 +88,10 @@ func fetchFile(realFilepath, updateFilepath string, tries int) error {
 	if runtime.GOOS != "windows" {
 		err = os.Chmod(realFilepath, 0644)
 		if err != nil {
-			log.Warningf("updates: failed to set permissions on downloaded file %s: %s", realFilepath, err)
+			log.Warningf("updates: failed to set permissions on file %s: %s", realFilepath, err)
 		}
 	}
 
-	log.Infof("updates: fetched %s (stored to %s)", downloadURL, realFilepath)
 	return nil
 }
 
@@ -88,26 +101,41 @@ func fetchData(downloadPath string, tries int) ([]byte, error) {
 		time.Sleep(time.Duration(tries*tries) * time.Second)
 	}
 
+	mirror := getMirrors()[0]
+	data, err := fetchDataFromMirror(mirror, downloadPath)
+	reportMirror(mirror, err)
+	return data, err
+}
+
+func fetchDataFromMirror(mirror, downloadPath string) ([]byte, error) {
 	// create URL
-	downloadURL, err := joinURLandPath(updateURLs[tries%len(updateURLs)], downloadPath)
+	downloadURL, err := joinURLandPath(mirror, downloadPath)
 	if err != nil {
-		return nil, fmt.Errorf("error build url (%s + %s): %s", updateURLs[tries%len(updateURLs)], downloadPath, err)
+		return nil, fmt.Errorf("error build url (%s + %s): %s", mirror, downloadPath, err)
+	}
+
+	client, err := getHTTPClient()
+	if err != nil {
+		return nil, err
 	}
 
 	// start file download
-	resp, err := http.Get(downloadURL)
+	resp, err := client.Get(downloadURL)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching url (%s): %s", downloadURL, err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching url (%s): %s", downloadURL, resp.Status)
+	}
 
 	// download and write file
-	buf := bytes.NewBuffer(make([]byte, 0, resp.ContentLength))
+	buf := &bytes.Buffer{}
 	n, err := io.Copy(buf, resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed downloading %s: %s", downloadURL, err)
 	}
-	if resp.ContentLength != n {
+	if resp.ContentLength >= 0 && resp.ContentLength != n {
 		return nil, fmt.Errorf("download unfinished, written %d out of %d bytes.", n, resp.ContentLength)
 	}
 
@@ -2,19 +2,26 @@ package updates
 
 // File represents a file from the update system.
 type File struct {
-	filepath string
-	version  string
-	stable   bool
+	identifier string
+	filepath   string
+	version    string
+	stable     bool
 }
 
-func NewFile(filepath string, version string, stable bool) *File {
+func NewFile(identifier, filepath, version string, stable bool) *File {
 	return &File{
-		filepath: filepath,
-		version:  version,
-		stable:   stable,
+		identifier: identifier,
+		filepath:   filepath,
+		version:    version,
+		stable:     stable,
 	}
 }
 
+// Identifier returns the identifier of the file, including the platform.
+func (f *File) Identifier() string {
+	return f.identifier
+}
+
 // Path returns the filepath of the file.
 func (f *File) Path() string {
 	return f.filepath
@@ -41,6 +48,27 @@ func (f *File) ReportError() {
 }
 
 // Blacklist notifies the update system that this file is somehow broken, and should be ignored from now on.
-func (f *File) Blacklist() {
+func (f *File) Blacklist(reason string) error {
+	err := BlacklistVersion(f.identifier, f.version, reason)
+	if err != nil {
+		return err
+	}
+	return LoadLatest()
+}
+
+// Fallback returns the highest version below the version of the file that is available locally and not blacklisted.
+func (f *File) Fallback() (version string, ok bool) {
+	updatesLock.RLock()
+	defer updatesLock.RUnlock()
 
+	for _, localVersion := range localVersions[f.identifier] {
+		if CompareVersions(localVersion, f.version) >= 0 ||
+			isBlacklisted(GetVersionedPath(f.identifier, localVersion)) {
+			continue
+		}
+		if version == "" || CompareVersions(localVersion, version) > 0 {
+			version = localVersion
+		}
+	}
+	return version, version != ""
 }
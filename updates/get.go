@@ -47,26 +47,27 @@ func GetLocalFile(identifier string) (*File, error) {
 }
 
 func getLatestFilePath(identifier string) (versionedFilePath, version string, stable bool, ok bool) {
+	versionedFilePath, version, channel, ok := getLatestFile(identifier)
+	return versionedFilePath, version, channel == ChannelStable, ok
+}
+
+func getLatestFile(identifier string) (versionedFilePath, version, channel string, ok bool) {
 	updatesLock.RLock()
 	defer updatesLock.RUnlock()
 
-	version, ok = stableUpdates[identifier]
+	version, channel, ok = selectVersion(identifier)
 	if !ok {
-		version, ok = localUpdates[identifier]
-		if !ok {
-			log.Tracef("updates: file %s does not exist", identifier)
-			return "", "", false, false
-			// TODO: if in development mode, reload latest index to check for newly sideloaded updates
-			// err := reloadLatest()
-		}
+		log.Tracef("updates: file %s does not exist", identifier)
+		return "", "", "", false
+		// TODO: if in development mode, reload latest index to check for newly sideloaded updates
+		// err := reloadLatest()
 	}
 
-	// TODO: Fix for stable release
-	return GetVersionedPath(identifier, version), version, false, true
+	return GetVersionedPath(identifier, version), version, channel, true
 }
 
 func loadOrFetchFile(identifier string, fetch bool) (*File, error) {
-	versionedFilePath, version, stable, ok := getLatestFilePath(identifier)
+	versionedFilePath, version, channel, ok := getLatestFile(identifier)
 	if !ok {
 		// TODO: if in development mode, search updates dir for sideloaded apps
 		return nil, ErrNotFound
@@ -76,8 +77,8 @@ func loadOrFetchFile(identifier string, fetch bool) (*File, error) {
 	realFilePath := filepath.Join(updateStoragePath, filepath.FromSlash(versionedFilePath))
 	if _, err := os.Stat(realFilePath); err == nil {
 		// file exists
-		updateUsedStatus(identifier, version)
-		return NewFile(realFilePath, version, stable), nil
+		updateUsedStatus(identifier, version, channel)
+		return NewFile(identifier, realFilePath, version, channel == ChannelStable), nil
 	}
 
 	// check download dir
@@ -93,12 +94,12 @@ func loadOrFetchFile(identifier string, fetch bool) (*File, error) {
 	// download file
 	log.Tracef("updates: starting download of %s", versionedFilePath)
 	for tries := 0; tries < 5; tries++ {
-		err = fetchFile(realFilePath, versionedFilePath, tries)
+		err = fetchFile(realFilePath, versionedFilePath, getFileHash(versionedFilePath), tries)
 		if err != nil {
 			log.Tracef("updates: failed to download %s: %s, retrying (%d)", versionedFilePath, err, tries+1)
 		} else {
-			updateUsedStatus(identifier, version)
-			return NewFile(realFilePath, version, stable), nil
+			updateUsedStatus(identifier, version, channel)
+			return NewFile(identifier, realFilePath, version, channel == ChannelStable), nil
 		}
 	}
 	log.Warningf("updates: failed to download %s: %s", versionedFilePath, err)
@@ -0,0 +1,245 @@
+package updates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// releaseSigningKeys are the public keys of the keys that sign the indexes of official releases, formatted as "<key ID>:<base64 public key>".
+// They are added by the release owners from the offline release signing key. Builds without any release signing or pinned keys do not accept any update.
+var releaseSigningKeys = [...]string{}
+
+var (
+	// pinnedKeys overrides the release signing keys with a comma separated list of "<key ID>:<base64 public key>", eg. for test builds. It is set at build time with -ldflags "-X github.com/Safing/portmaster/updates.pinnedKeys=...".
+	pinnedKeys string
+
+	trustedKeys     map[string]ed25519.PublicKey
+	trustedKeysErr  error
+	trustedKeysOnce sync.Once
+
+	// ErrNoValidSignature is returned when an index is not signed by any trusted key.
+	ErrNoValidSignature = errors.New("index is not signed by a trusted key")
+	// ErrHashMismatch is returned when a downloaded file does not match the hash of the signed index.
+	ErrHashMismatch = errors.New("file does not match the hash of the signed index")
+	// ErrNoHash is returned when a file that should be downloaded is not listed in the signed index.
+	ErrNoHash = errors.New("file is not listed in the signed index")
+)
+
+// Index lists the available versions of all components and the hashes of their files.
+type Index struct {
+	// Published is the unix timestamp of when the index was created. It must only increase, in order to prevent rolling back to older indexes.
+	Published int64
+	// Versions maps identifiers to their version.
+	Versions map[string]string
+	// Hashes maps versioned paths to the hex encoded SHA256 hash of the file.
+	Hashes map[string]string
+}
+
+// SignedIndex holds a serialized index and signatures over it.
+type SignedIndex struct {
+	Index      json.RawMessage
+	Signatures []*Signature
+}
+
+// Signature is an Ed25519 signature of a signed index.
+type Signature struct {
+	KeyID     string
+	Signature []byte
+}
+
+// NewIndex returns a new index with the given versions, published now.
+func NewIndex(versions map[string]string) *Index {
+	return &Index{
+		Published: time.Now().Unix(),
+		Versions:  versions,
+		Hashes:    make(map[string]string),
+	}
+}
+
+// SignIndex serializes and signs the given index.
+func SignIndex(index *Index, keyID string, key ed25519.PrivateKey) (*SignedIndex, error) {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+
+	si := &SignedIndex{
+		Index: data,
+	}
+	err = si.AddSignature(keyID, key)
+	if err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// AddSignature adds a signature with the given key.
+func (si *SignedIndex) AddSignature(keyID string, key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return errors.New("invalid private key size")
+	}
+
+	data, err := si.signedData()
+	if err != nil {
+		return err
+	}
+	si.Signatures = append(si.Signatures, &Signature{
+		KeyID:     keyID,
+		Signature: ed25519.Sign(key, data),
+	})
+	return nil
+}
+
+// Verify checks that the index is signed by at least one of the given keys and returns the parsed index.
+func (si *SignedIndex) Verify(keys map[string]ed25519.PublicKey) (*Index, error) {
+	data, err := si.signedData()
+	if err != nil {
+		return nil, err
+	}
+
+	var verified bool
+	for _, sig := range si.Signatures {
+		key, ok := keys[sig.KeyID]
+		if ok && ed25519.Verify(key, data, sig.Signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrNoValidSignature
+	}
+
+	index := &Index{}
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index: %s", err)
+	}
+	if len(index.Versions) == 0 {
+		return nil, errors.New("index is empty")
+	}
+	if index.Hashes == nil {
+		index.Hashes = make(map[string]string)
+	}
+	return index, nil
+}
+
+// signedData returns the compacted index, as the signed index may have been reformatted.
+func (si *SignedIndex) signedData() ([]byte, error) {
+	if len(si.Index) == 0 {
+		return nil, errors.New("signed index does not contain an index")
+	}
+
+	buf := &bytes.Buffer{}
+	err := json.Compact(buf, si.Index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact index: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseSignedIndex parses a signed index, without verifying it.
+func ParseSignedIndex(data []byte) (*SignedIndex, error) {
+	si := &SignedIndex{}
+	err := json.Unmarshal(data, si)
+	if err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// verifyIndex parses the given signed index and verifies it with the pinned keys.
+func verifyIndex(data []byte) (*Index, error) {
+	keys, err := getTrustedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	si, err := ParseSignedIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed index: %s", err)
+	}
+	return si.Verify(keys)
+}
+
+// TrustedKeys returns the pinned update signing keys.
+func TrustedKeys() (map[string]ed25519.PublicKey, error) {
+	return getTrustedKeys()
+}
+
+func getTrustedKeys() (map[string]ed25519.PublicKey, error) {
+	trustedKeysOnce.Do(func() {
+		trustedKeys, trustedKeysErr = loadTrustedKeys()
+	})
+	return trustedKeys, trustedKeysErr
+}
+
+// loadTrustedKeys parses the release signing keys, or the keys that override them.
+func loadTrustedKeys() (map[string]ed25519.PublicKey, error) {
+	list := pinnedKeys
+	if list == "" {
+		list = strings.Join(releaseSigningKeys[:], ",")
+	}
+	keys, err := ParsePublicKeys(list)
+	if err == nil && len(keys) == 0 {
+		err = errors.New("no update signing keys were pinned at build time")
+	}
+	return keys, err
+}
+
+// ParsePublicKeys parses a comma separated list of "<key ID>:<base64 public key>".
+func ParsePublicKeys(list string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid key %q, must be formatted as <key ID>:<base64 public key>", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %s", parts[0], err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid key %s: must be %d bytes long", parts[0], ed25519.PublicKeySize)
+		}
+		keys[parts[0]] = ed25519.PublicKey(key)
+	}
+	return keys, nil
+}
+
+// FormatPublicKey formats a public key for use with ParsePublicKeys.
+func FormatPublicKey(keyID string, key ed25519.PublicKey) string {
+	return keyID + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// HashFile returns the hex encoded SHA256 hash of the file at the given path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,167 @@
+package updates
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignedIndex(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := NewIndex(map[string]string{
+		"all/ui/assets.zip": "1.2.3",
+	})
+	index.Hashes["all/ui/assets_v1-2-3.zip"] = "abc"
+
+	si, err := SignIndex(index, "test", privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reformatting must not break the signature
+	data, err := json.MarshalIndent(si, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	si, err = ParseSignedIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := si.Verify(map[string]ed25519.PublicKey{"test": publicKey})
+	if err != nil {
+		t.Fatalf("failed to verify: %s", err)
+	}
+	if verified.Versions["all/ui/assets.zip"] != "1.2.3" || verified.Hashes["all/ui/assets_v1-2-3.zip"] != "abc" {
+		t.Errorf("unexpected index: %+v", verified)
+	}
+
+	// wrong key
+	_, err = si.Verify(map[string]ed25519.PublicKey{"test": otherPublicKey})
+	if err != ErrNoValidSignature {
+		t.Errorf("should fail with wrong key, got %v", err)
+	}
+
+	// tampered index
+	si.Index = json.RawMessage(`{"Published":1,"Versions":{"all/ui/assets.zip":"6.6.6"},"Hashes":{}}`)
+	_, err = si.Verify(map[string]ed25519.PublicKey{"test": publicKey})
+	if err != ErrNoValidSignature {
+		t.Errorf("should fail with tampered index, got %v", err)
+	}
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ParsePublicKeys(FormatPublicKey("a", publicKey) + ", " + FormatPublicKey("b", publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(keys))
+	}
+
+	_, err = ParsePublicKeys("a:dGVzdA==")
+	if err == nil {
+		t.Error("should fail on invalid key size")
+	}
+}
+
+func TestReleaseSigningKeys(t *testing.T) {
+	keys, err := ParsePublicKeys(strings.Join(releaseSigningKeys[:], ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(releaseSigningKeys) {
+		t.Errorf("release signing keys must have unique IDs")
+	}
+
+	if pinnedKeys == "" {
+		trusted, err := loadTrustedKeys()
+		if len(releaseSigningKeys) == 0 {
+			if err == nil {
+				t.Error("builds without signing keys must not trust any key")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trusted) != len(keys) {
+			t.Errorf("release signing keys should be trusted by default")
+		}
+	}
+}
+
+func TestFetchFileVerifiesHash(t *testing.T) {
+	content := []byte("portmaster")
+	hash := sha256.Sum256(content)
+	expectedHash := hex.EncodeToString(hash[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/linux_amd64/core/portmaster_v1-0-0":
+			w.Write(content)
+		default:
+			w.Write([]byte("malicious"))
+		}
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldURLs, oldStoragePath := updateURLs, updateStoragePath
+	updateURLs, updateStoragePath = []string{server.URL}, tmpDir
+	defer func() {
+		updateURLs, updateStoragePath = oldURLs, oldStoragePath
+	}()
+	err = CheckDir(filepath.Join(tmpDir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := filepath.Join(tmpDir, "linux_amd64/core/portmaster_v1-0-0")
+	err = fetchFile(good, "linux_amd64/core/portmaster_v1-0-0", expectedHash, 0)
+	if err != nil {
+		t.Fatalf("failed to fetch file: %s", err)
+	}
+
+	bad := filepath.Join(tmpDir, "linux_amd64/core/portmaster_v6-6-6")
+	err = fetchFile(bad, "linux_amd64/core/portmaster_v6-6-6", expectedHash, 0)
+	if err == nil {
+		t.Error("file with wrong hash should be rejected")
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Error("rejected file must not be written")
+	}
+
+	err = fetchFile(bad, "linux_amd64/core/portmaster_v6-6-6", "", 0)
+	if err == nil {
+		t.Error("file without hash should be rejected")
+	}
+}
@@ -1,8 +1,6 @@
 package updates
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -16,32 +14,56 @@ import (
 var (
 	stableUpdates = make(map[string]string)
 	betaUpdates   = make(map[string]string)
+	devUpdates    = make(map[string]string)
 	localUpdates  = make(map[string]string)
-	updatesLock   sync.RWMutex
+	// localVersions holds all versions of all identifiers in the update storage.
+	localVersions = make(map[string][]string)
+	// indexHashes holds the hashes of versioned paths from the signed index of each channel.
+	indexHashes = make(map[string]map[string]string)
+	// indexPublished holds the publishing time of the loaded index of each channel.
+	indexPublished = make(map[string]int64)
+	updatesLock    sync.RWMutex
 )
 
-// LoadLatest (re)loads the latest available updates from disk.
+// LoadLatest (re)loads the latest available updates from disk. Blacklisted versions are skipped.
 func LoadLatest() error {
-	newLocalUpdates := make(map[string]string)
+	err := loadBlacklist()
+	if err != nil {
+		log.Warningf("updates: failed to load blacklist: %s", err)
+	}
+
+	newLocalVersions := make(map[string][]string)
 
 	// all
 	prefix := "all"
-	new, err1 := ScanForLatest(filepath.Join(updateStoragePath, prefix), false)
-	for key, val := range new {
-		newLocalUpdates[filepath.ToSlash(filepath.Join(prefix, key))] = val
+	versions, err1 := scanForVersions(filepath.Join(updateStoragePath, prefix), false)
+	for key, val := range versions {
+		newLocalVersions[filepath.ToSlash(filepath.Join(prefix, key))] = val
 	}
 
 	// os_platform
 	prefix = fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
-	new, err2 := ScanForLatest(filepath.Join(updateStoragePath, prefix), false)
-	for key, val := range new {
-		newLocalUpdates[filepath.ToSlash(filepath.Join(prefix, key))] = val
+	versions, err2 := scanForVersions(filepath.Join(updateStoragePath, prefix), false)
+	for key, val := range versions {
+		newLocalVersions[filepath.ToSlash(filepath.Join(prefix, key))] = val
 	}
 
 	if err1 != nil && err2 != nil {
 		return fmt.Errorf("could not load latest update versions: %s, %s", err1, err2)
 	}
 
+	newLocalUpdates := make(map[string]string)
+	for identifier, versions := range newLocalVersions {
+		for _, version := range versions {
+			if isBlacklisted(GetVersionedPath(identifier, version)) {
+				continue
+			}
+			if CompareVersions(version, newLocalUpdates[identifier]) > 0 {
+				newLocalUpdates[identifier] = version
+			}
+		}
+	}
+
 	log.Tracef("updates: loading latest updates:")
 
 	for key, val := range newLocalUpdates {
@@ -50,11 +72,13 @@ func LoadLatest() error {
 
 	updatesLock.Lock()
 	localUpdates = newLocalUpdates
+	localVersions = newLocalVersions
 	updatesLock.Unlock()
 
 	log.Tracef("updates: load complete")
 
 	// update version status
+	updateBlacklistStatus()
 	updatesLock.RLock()
 	defer updatesLock.RUnlock()
 	updateStatus(versionClassLocal, localUpdates)
@@ -62,9 +86,28 @@ func LoadLatest() error {
 	return nil
 }
 
+// ScanForLatest returns the latest version of all identifiers within baseDir.
 func ScanForLatest(baseDir string, hardFail bool) (latest map[string]string, lastError error) {
-	var added int
+	versions, lastError := scanForVersions(baseDir, hardFail)
+	if versions == nil {
+		return nil, lastError
+	}
+
 	latest = make(map[string]string)
+	for identifier, identifierVersions := range versions {
+		for _, version := range identifierVersions {
+			if CompareVersions(version, latest[identifier]) > 0 {
+				latest[identifier] = version
+			}
+		}
+	}
+	return latest, lastError
+}
+
+// scanForVersions returns all versions of all identifiers within baseDir.
+func scanForVersions(baseDir string, hardFail bool) (versions map[string][]string, lastError error) {
+	var added int
+	versions = make(map[string][]string)
 
 	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -91,17 +134,8 @@ func ScanForLatest(baseDir string, hardFail bool) (latest map[string]string, las
 			return nil
 		}
 
-		// add/update index
-		storedVersion, ok := latest[identifierPath]
-		if ok {
-			// FIXME: this will fail on multi-digit version segments!
-			// FIXME: use https://github.com/hashicorp/go-version
-			if version > storedVersion {
-				latest[identifierPath] = version
-			}
-		} else {
-			latest[identifierPath] = version
-		}
+		// add to index
+		versions[identifierPath] = append(versions[identifierPath], version)
 
 		return nil
 	})
@@ -111,38 +145,94 @@ func ScanForLatest(baseDir string, hardFail bool) (latest map[string]string, las
 			return nil, lastError
 		}
 		if added == 0 {
-			return latest, lastError
+			return versions, lastError
 		}
 	}
-	return latest, nil
+	return versions, nil
 }
 
+// LoadIndexes loads the signed indexes of the active release channels from disk and verifies them. Only the stable index is required.
 func LoadIndexes() error {
-	data, err := ioutil.ReadFile(filepath.Join(updateStoragePath, "stable.json"))
-	if err != nil {
-		return err
+	for _, channel := range activeChannels() {
+		err := loadIndex(channel)
+		if err != nil {
+			if channel == ChannelStable {
+				return err
+			}
+			if !os.IsNotExist(err) {
+				log.Warningf("updates: failed to load %s index: %s", channel, err)
+			}
+		}
 	}
 
-	newStableUpdates := make(map[string]string)
-	err = json.Unmarshal(data, &newStableUpdates)
+	// update version status
+	updatesLock.RLock()
+	defer updatesLock.RUnlock()
+	updateIndexStatus()
+
+	return nil
+}
+
+func loadIndex(channel string) error {
+	index, err := loadStoredIndex(channel)
 	if err != nil {
 		return err
 	}
 
-	if len(newStableUpdates) == 0 {
-		return errors.New("stable.json is empty")
-	}
-
-	log.Tracef("updates: loaded stable.json")
+	log.Tracef("updates: loaded %s", indexFileName(channel))
 
 	updatesLock.Lock()
-	stableUpdates = newStableUpdates
+	setIndex(channel, index)
 	updatesLock.Unlock()
 
-	// update version status
+	return nil
+}
+
+// loadStoredIndex reads and verifies the stored index of the given channel.
+func loadStoredIndex(channel string) (*Index, error) {
+	indexFile := indexFileName(channel)
+	data, err := ioutil.ReadFile(filepath.Join(updateStoragePath, indexFile))
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := verifyIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %s", indexFile, err)
+	}
+	return index, nil
+}
+
+// setIndex activates the given verified index for the given channel. updatesLock must be locked.
+func setIndex(channel string, index *Index) {
+	switch channel {
+	case ChannelStable:
+		stableUpdates = index.Versions
+	case ChannelBeta:
+		betaUpdates = index.Versions
+	case ChannelDev:
+		devUpdates = index.Versions
+	default:
+		return
+	}
+	indexHashes[channel] = index.Hashes
+	indexPublished[channel] = index.Published
+}
+
+func getFileHash(versionedFilePath string) string {
 	updatesLock.RLock()
 	defer updatesLock.RUnlock()
-	updateStatus(versionClassStable, stableUpdates)
 
-	return nil
+	return fileHash(versionedFilePath)
+}
+
+// fileHash returns the hash of the versioned path from any loaded index. updatesLock must be locked.
+func fileHash(versionedFilePath string) string {
+	for _, hashes := range indexHashes {
+		hash, ok := hashes[versionedFilePath]
+		if ok {
+			return hash
+		}
+	}
+	return ""
 }
@@ -9,7 +9,7 @@ import (
 	"github.com/Safing/portbase/database"
 	"github.com/Safing/portbase/info"
 	"github.com/Safing/portbase/log"
-	"github.com/Safing/portbase/modules"
+	"github.com/Safing/portmaster/internal/modstatus"
 )
 
 var (
@@ -24,7 +24,7 @@ func SetDatabaseRoot(path string) {
 }
 
 func init() {
-	modules.Register("updates", prep, start, nil, "core")
+	modstatus.Register("updates", prep, start, nil, "core")
 }
 
 func prep() error {
@@ -38,6 +38,16 @@ func prep() error {
 	if err != nil {
 		return err
 	}
+	// bundles placed here are imported automatically
+	err = CheckDir(filepath.Join(updateStoragePath, "import"))
+	if err != nil {
+		return err
+	}
+
+	err = registerConfig()
+	if err != nil {
+		return err
+	}
 
 	status.Core = info.GetInfo()
 
@@ -53,9 +63,10 @@ func start() error {
 	err = LoadIndexes()
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Infof("updates: stable.json does not yet exist, waiting for first update cycle")
+			log.Infof("updates: %s does not yet exist, waiting for first update cycle", indexFileName(ChannelStable))
 		} else {
-			return err
+			// the next update cycle will fetch a valid index
+			log.Warningf("updates: failed to load indexes, waiting for next update cycle: %s", err)
 		}
 	}
 
@@ -64,7 +75,13 @@ func start() error {
 		return err
 	}
 
+	err = initChannelListener()
+	if err != nil {
+		return err
+	}
+
 	go updater()
+	go bundleImporter()
 	go updateNotifier()
 	return nil
 }
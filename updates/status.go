@@ -24,11 +24,12 @@ const (
 	versionClassLocal versionClass = iota
 	versionClassStable
 	versionClassBeta
+	versionClassDev
 )
 
 // working vars
 var (
-	status *versionStatus
+	status *VersionStatus
 
 	statusDB         = database.NewInterface(nil)
 	statusHook       *database.RegisteredHook
@@ -36,22 +37,24 @@ var (
 )
 
 func init() {
-	status = &versionStatus{
-		Modules: make(map[string]*versionStatusEntry),
+	status = &VersionStatus{
+		Modules:   make(map[string]*VersionStatusEntry),
+		Downloads: make(map[string]*DownloadStatus),
 	}
 	status.SetKey(statusDBKey)
 }
 
-// versionStatus holds update version status information.
-type versionStatus struct {
+// VersionStatus holds update version status information. It is saved to the database as core:status/updates.
+type VersionStatus struct {
 	record.Base
 	sync.Mutex
 
-	Core    *info.Info
-	Modules map[string]*versionStatusEntry
+	Core      *info.Info
+	Modules   map[string]*VersionStatusEntry
+	Downloads map[string]*DownloadStatus
 }
 
-func (vs *versionStatus) save() {
+func (vs *VersionStatus) save() {
 	enableStatusSave.SetTo(true)
 	err := statusDB.Put(vs)
 	if err != nil {
@@ -59,27 +62,33 @@ func (vs *versionStatus) save() {
 	}
 }
 
-// versionStatusEntry holds information about the update status of a module.
-type versionStatusEntry struct {
+// VersionStatusEntry holds information about the update status of a module.
+type VersionStatusEntry struct {
 	LastVersionUsed string
 	LocalVersion    string
 	StableVersion   string
 	BetaVersion     string
+	DevVersion      string
+	// Channel is the release channel the last used version was selected from: stable, beta, dev, pinned or local.
+	Channel string
+	// BlacklistedVersions maps versions that failed to the reason of the failure.
+	BlacklistedVersions map[string]string
 }
 
-func updateUsedStatus(identifier string, version string) {
+func updateUsedStatus(identifier, version, channel string) {
 	status.Lock()
 	defer status.Unlock()
 
 	entry, ok := status.Modules[identifier]
 	if !ok {
-		entry = &versionStatusEntry{}
+		entry = &VersionStatusEntry{}
 		status.Modules[identifier] = entry
 	}
 
 	entry.LastVersionUsed = version
+	entry.Channel = channel
 
-	log.Tracef("updates: updated last used version of %s: %s", identifier, version)
+	log.Tracef("updates: updated last used version of %s: %s (%s)", identifier, version, channel)
 
 	go status.save()
 }
@@ -92,7 +101,7 @@ func updateStatus(vClass versionClass, state map[string]string) {
 
 		entry, ok := status.Modules[identifier]
 		if !ok {
-			entry = &versionStatusEntry{}
+			entry = &VersionStatusEntry{}
 			status.Modules[identifier] = entry
 		}
 
@@ -103,12 +112,50 @@ func updateStatus(vClass versionClass, state map[string]string) {
 			entry.StableVersion = version
 		case versionClassBeta:
 			entry.BetaVersion = version
+		case versionClassDev:
+			entry.DevVersion = version
 		}
 	}
 
 	go status.save()
 }
 
+// updateIndexStatus updates the version status of all channels. updatesLock must be locked.
+func updateIndexStatus() {
+	updateStatus(versionClassStable, stableUpdates)
+	updateStatus(versionClassBeta, betaUpdates)
+	updateStatus(versionClassDev, devUpdates)
+}
+
+// DownloadStatus holds the progress of a running download.
+type DownloadStatus struct {
+	Mirror string
+	// Size is -1, if the size is unknown.
+	Size       int64
+	Downloaded int64
+	Started    int64
+}
+
+func updateDownloadStatus(versionedPath string, ds *DownloadStatus) {
+	status.Lock()
+	defer status.Unlock()
+
+	status.Downloads[versionedPath] = ds
+
+	go status.save()
+}
+
+func removeDownloadStatus(versionedPath string) {
+	status.Lock()
+	defer status.Unlock()
+
+	_, ok := status.Downloads[versionedPath]
+	if ok {
+		delete(status.Downloads, versionedPath)
+		go status.save()
+	}
+}
+
 type updateStatusHook struct {
 	database.HookBase
 }
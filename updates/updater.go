@@ -1,13 +1,13 @@
 package updates
 
 import (
-	"encoding/json"
-	"errors"
-	"io/ioutil"
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"time"
 
+	"github.com/google/renameio"
+
 	"github.com/Safing/portbase/log"
 )
 
@@ -18,6 +18,7 @@ func updater() {
 		if err != nil {
 			log.Warningf("updates: failed to check for updates: %s", err)
 		}
+		cleanupStorage()
 		time.Sleep(1 * time.Hour)
 	}
 }
@@ -35,10 +36,79 @@ func CheckForUpdates() error {
 		log.Errorf("updates: failed to mark pmctl/pmctl as used to ensure updates: %s", err)
 	}
 
-	// download new index
+	// download new indexes
+	for _, channel := range activeChannels() {
+		err = updateIndex(channel)
+		if err != nil {
+			if channel == ChannelStable {
+				return err
+			}
+			log.Warningf("updates: failed to update %s index: %s", channel, err)
+		}
+	}
+
+	// update existing files
+	log.Tracef("updates: updating existing files")
+	for _, update := range getPendingUpdates() {
+		for tries := 0; tries < 3; tries++ {
+			err = fetchFile(update.realFilePath, update.filePath, update.hash, tries)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Warningf("failed to update %s to %s: %s", update.identifier, update.version, err)
+		}
+	}
+	log.Tracef("updates: finished updating existing files")
+
+	// update version status
+	updatesLock.RLock()
+	defer updatesLock.RUnlock()
+	updateIndexStatus()
+
+	return nil
+}
+
+// pendingUpdate is a file that needs to be downloaded to update a component.
+type pendingUpdate struct {
+	identifier   string
+	version      string
+	filePath     string
+	realFilePath string
+	hash         string
+}
+
+// getPendingUpdates returns the files that need to be downloaded to update the existing files. It does not hold updatesLock while downloading, so that the update files can still be used in the meantime.
+func getPendingUpdates() []*pendingUpdate {
+	updatesLock.RLock()
+	defer updatesLock.RUnlock()
+
+	var pending []*pendingUpdate
+	for identifier, oldVersion := range localUpdates {
+		newVersion, _, ok := selectVersion(identifier)
+		if ok && newVersion != oldVersion {
+			filePath := GetVersionedPath(identifier, newVersion)
+			pending = append(pending, &pendingUpdate{
+				identifier:   identifier,
+				version:      newVersion,
+				filePath:     filePath,
+				realFilePath: filepath.Join(updateStoragePath, filePath),
+				hash:         fileHash(filePath),
+			})
+		}
+	}
+	return pending
+}
+
+// updateIndex downloads, verifies and activates the index of the given channel.
+func updateIndex(channel string) error {
+	indexFile := indexFileName(channel)
+
 	var data []byte
+	var err error
 	for tries := 0; tries < 3; tries++ {
-		data, err = fetchData("stable.json", tries)
+		data, err = fetchData(indexFile, tries)
 		if err == nil {
 			break
 		}
@@ -47,58 +117,27 @@ func CheckForUpdates() error {
 		return err
 	}
 
-	newStableUpdates := make(map[string]string)
-	err = json.Unmarshal(data, &newStableUpdates)
+	// verify before using or storing anything
+	newIndex, err := verifyIndex(data)
 	if err != nil {
-		return err
-	}
-
-	if len(newStableUpdates) == 0 {
-		return errors.New("stable.json is empty")
+		return fmt.Errorf("failed to verify %s: %s", indexFile, err)
 	}
 
-	// FIXME IN STABLE: correct log line
-	log.Infof("updates: downloaded new update index: stable.json (alpha until we actually reach stable)")
-
-	// update existing files
-	log.Tracef("updates: updating existing files")
-	updatesLock.RLock()
-	for identifier, newVersion := range newStableUpdates {
-		oldVersion, ok := localUpdates[identifier]
-		if ok && newVersion != oldVersion {
-
-			filePath := GetVersionedPath(identifier, newVersion)
-			realFilePath := filepath.Join(updateStoragePath, filePath)
-			for tries := 0; tries < 3; tries++ {
-				err := fetchFile(realFilePath, filePath, tries)
-				if err == nil {
-					break
-				}
-			}
-			if err != nil {
-				log.Warningf("failed to update %s to %s: %s", identifier, newVersion, err)
-			}
+	updatesLock.Lock()
+	defer updatesLock.Unlock()
 
-		}
+	if newIndex.Published < indexPublished[channel] {
+		return fmt.Errorf("%s is older than the current index, refusing to roll back", indexFile)
 	}
-	updatesLock.RUnlock()
-	log.Tracef("updates: finished updating existing files")
+	setIndex(channel, newIndex)
 
-	// update stable index
-	updatesLock.Lock()
-	stableUpdates = newStableUpdates
-	updatesLock.Unlock()
+	log.Infof("updates: downloaded new update index: %s", indexFile)
 
-	// save stable index
-	err = ioutil.WriteFile(filepath.Join(updateStoragePath, "stable.json"), data, 0644)
+	// save index
+	err = renameio.WriteFile(filepath.Join(updateStoragePath, indexFile), data, 0644)
 	if err != nil {
-		log.Warningf("updates: failed to save new version of stable.json: %s", err)
+		log.Warningf("updates: failed to save new version of %s: %s", indexFile, err)
 	}
 
-	// update version status
-	updatesLock.RLock()
-	defer updatesLock.RUnlock()
-	updateStatus(versionClassStable, stableUpdates)
-
 	return nil
 }
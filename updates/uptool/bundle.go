@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/renameio"
+	"github.com/spf13/cobra"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+var (
+	bundleIndexPaths []string
+)
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.Flags().StringSliceVar(&bundleIndexPaths, "index", nil, "signed indexes to include, defaults to all signed indexes in the current directory")
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle <output.tar.gz>",
+	Short: "Build an offline update bundle from the signed indexes and files in the current directory",
+	Args:  cobra.ExactArgs(1),
+	RunE:  bundle,
+}
+
+func bundle(cmd *cobra.Command, args []string) error {
+	indexPaths := bundleIndexPaths
+	if len(indexPaths) == 0 {
+		var err error
+		indexPaths, err = filepath.Glob("*.signed.json")
+		if err != nil {
+			return err
+		}
+		if len(indexPaths) == 0 {
+			return fmt.Errorf("no signed indexes found, please run sign first")
+		}
+	}
+
+	bundleFile, err := renameio.TempFile("", args[0])
+	if err != nil {
+		return err
+	}
+	defer bundleFile.Cleanup()
+
+	err = updates.WriteBundle(bundleFile, ".", indexPaths)
+	if err != nil {
+		return err
+	}
+	err = bundleFile.CloseAtomicallyReplace()
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote bundle %s with %v (%d bytes)\n", args[0], indexPaths, info.Size())
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old index> <new index>",
+	Short: "Show the changes between two indexes, signed or unsigned",
+	Args:  cobra.ExactArgs(2),
+	RunE:  diff,
+}
+
+func diff(cmd *cobra.Command, args []string) error {
+	oldIndex, err := loadIndex(args[0])
+	if err != nil {
+		return err
+	}
+	newIndex, err := loadIndex(args[1])
+	if err != nil {
+		return err
+	}
+
+	changes := diffIndexes(oldIndex, newIndex)
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return nil
+}
+
+// diffIndexes returns the changed components, sorted by identifier.
+func diffIndexes(oldIndex, newIndex *updates.Index) (changes []string) {
+	identifiers := make(map[string]struct{})
+	for identifier := range oldIndex.Versions {
+		identifiers[identifier] = struct{}{}
+	}
+	for identifier := range newIndex.Versions {
+		identifiers[identifier] = struct{}{}
+	}
+	sorted := make([]string, 0, len(identifiers))
+	for identifier := range identifiers {
+		sorted = append(sorted, identifier)
+	}
+	sort.Strings(sorted)
+
+	for _, identifier := range sorted {
+		oldVersion, inOld := oldIndex.Versions[identifier]
+		newVersion, inNew := newIndex.Versions[identifier]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("+ %s v%s", identifier, newVersion))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("- %s v%s", identifier, oldVersion))
+		case oldVersion != newVersion:
+			direction := "upgrade"
+			if updates.CompareVersions(newVersion, oldVersion) < 0 {
+				direction = "downgrade"
+			}
+			changes = append(changes, fmt.Sprintf("~ %s v%s -> v%s (%s)", identifier, oldVersion, newVersion, direction))
+		default:
+			// same version, but a different file must never be published
+			versionedPath := updates.GetVersionedPath(identifier, newVersion)
+			if oldIndex.Hashes[versionedPath] != newIndex.Hashes[versionedPath] {
+				changes = append(changes, fmt.Sprintf("! %s v%s: hash changed", identifier, newVersion))
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	oldIndex := &updates.Index{
+		Versions: map[string]string{
+			"all/removed":   "1.0.0",
+			"all/upgraded":  "1.0.0",
+			"all/downgrade": "1.2.0",
+			"all/same":      "1.0.0",
+			"all/replaced":  "1.0.0",
+		},
+		Hashes: map[string]string{
+			"all/same_v1-0-0":     "aa",
+			"all/replaced_v1-0-0": "aa",
+		},
+	}
+	newIndex := &updates.Index{
+		Versions: map[string]string{
+			"all/added":     "0.1.0",
+			"all/upgraded":  "1.1.0",
+			"all/downgrade": "1.1.0",
+			"all/same":      "1.0.0",
+			"all/replaced":  "1.0.0",
+		},
+		Hashes: map[string]string{
+			"all/same_v1-0-0":     "aa",
+			"all/replaced_v1-0-0": "bb",
+		},
+	}
+
+	expected := []string{
+		"+ all/added v0.1.0",
+		"~ all/downgrade v1.2.0 -> v1.1.0 (downgrade)",
+		"- all/removed v1.0.0",
+		"! all/replaced v1.0.0: hash changed",
+		"~ all/upgraded v1.0.0 -> v1.1.0 (upgrade)",
+	}
+	changes := diffIndexes(oldIndex, newIndex)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes:\n%v\nexpected:\n%v", changes, expected)
+	}
+
+	if changes := diffIndexes(newIndex, newIndex); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+var (
+	indexChannel string
+)
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.Flags().StringVar(&indexChannel, "channel", updates.ChannelStable, "release channel of the index: stable, beta or dev")
+}
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Scan the current directory and write the unsigned index of a release channel, eg. stable.json",
+	Args:  cobra.NoArgs,
+	RunE:  writeChannelIndex,
+}
+
+func writeChannelIndex(cmd *cobra.Command, args []string) error {
+	err := checkChannel(indexChannel)
+	if err != nil {
+		return err
+	}
+
+	index, err := buildIndex()
+	if err != nil {
+		return err
+	}
+
+	indexPath := unsignedIndexPath(indexChannel)
+	err = writeIndex(indexPath, index)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s with %d components, sign it with: uptool sign --channel %s --key <key file>\n", indexPath, len(index.Versions), indexChannel)
+	return nil
+}
+
+func checkChannel(channel string) error {
+	switch channel {
+	case updates.ChannelStable, updates.ChannelBeta, updates.ChannelDev:
+		return nil
+	default:
+		return fmt.Errorf("invalid channel %q, must be stable, beta or dev", channel)
+	}
+}
+
+func unsignedIndexPath(channel string) string {
+	return channel + ".json"
+}
+
+func signedIndexPath(channel string) string {
+	return channel + ".signed.json"
+}
+
+// loadIndex reads an unsigned index, or the index of a signed index without verifying it.
+func loadIndex(indexPath string) (*updates.Index, error) {
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(indexPath, ".signed.json") {
+		si, err := updates.ParseSignedIndex(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", indexPath, err)
+		}
+		data = si.Index
+	}
+
+	index := &updates.Index{}
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", indexPath, err)
+	}
+	if index.Versions == nil {
+		index.Versions = make(map[string]string)
+	}
+	if index.Hashes == nil {
+		index.Hashes = make(map[string]string)
+	}
+	return index, nil
+}
+
+func writeIndex(indexPath string, index *updates.Index) error {
+	data, err := json.MarshalIndent(index, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(indexPath, data, 0644)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+}
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <destination> [index...]",
+	Short: "Copy the indexes and the files listed in them to a new directory tree. Uses all signed indexes in the current directory, if none are given.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  mirror,
+}
+
+func mirror(cmd *cobra.Command, args []string) error {
+	destination := args[0]
+	indexPaths := args[1:]
+	if len(indexPaths) == 0 {
+		var err error
+		indexPaths, err = filepath.Glob("*.signed.json")
+		if err != nil {
+			return err
+		}
+		if len(indexPaths) == 0 {
+			return fmt.Errorf("no signed indexes found")
+		}
+	}
+
+	hashes := make(map[string]string)
+	for _, indexPath := range indexPaths {
+		index, err := loadIndex(indexPath)
+		if err != nil {
+			return err
+		}
+		for versionedPath, hash := range index.Hashes {
+			hashes[versionedPath] = hash
+		}
+	}
+
+	versionedPaths := make([]string, 0, len(hashes))
+	for versionedPath := range hashes {
+		versionedPaths = append(versionedPaths, versionedPath)
+	}
+	sort.Strings(versionedPaths)
+
+	var copied int
+	for _, versionedPath := range versionedPaths {
+		src := filepath.FromSlash(versionedPath)
+		dst := filepath.Join(destination, src)
+
+		// verify source before copying
+		hash, err := updates.HashFile(src)
+		if err != nil {
+			return err
+		}
+		if hash != hashes[versionedPath] {
+			return fmt.Errorf("%s: %s", versionedPath, updates.ErrHashMismatch)
+		}
+
+		// skip files that are already mirrored
+		if hash, err := updates.HashFile(dst); err == nil && hash == hashes[versionedPath] {
+			continue
+		}
+
+		err = copyFile(src, dst)
+		if err != nil {
+			return err
+		}
+		copied++
+	}
+
+	// copy indexes last, so that mirrors never serve indexes without their files
+	for _, indexPath := range indexPaths {
+		err := copyFile(indexPath, filepath.Join(destination, filepath.Base(indexPath)))
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("mirrored %d indexes and %d files (%d new) to %s\n", len(indexPaths), len(versionedPaths), copied, destination)
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dstFile, srcFile)
+	if err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
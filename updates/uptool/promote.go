@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+var (
+	promoteFrom string
+	promoteTo   string
+)
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", updates.ChannelBeta, "channel to promote versions from")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", updates.ChannelStable, "channel to promote versions to")
+}
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote [identifier...]",
+	Short: "Promote versions from one unsigned channel index to another, eg. from beta.json to stable.json. Promotes all components, if none are given.",
+	RunE:  promote,
+}
+
+func promote(cmd *cobra.Command, args []string) error {
+	for _, channel := range []string{promoteFrom, promoteTo} {
+		err := checkChannel(channel)
+		if err != nil {
+			return err
+		}
+	}
+
+	fromIndex, err := loadIndex(unsignedIndexPath(promoteFrom))
+	if err != nil {
+		return err
+	}
+	toIndex, err := loadIndex(unsignedIndexPath(promoteTo))
+	switch {
+	case os.IsNotExist(err):
+		toIndex = updates.NewIndex(make(map[string]string))
+	case err != nil:
+		return err
+	}
+
+	promoted, err := promoteVersions(fromIndex, toIndex, args)
+	if err != nil {
+		return err
+	}
+	if promoted == 0 {
+		fmt.Println("nothing to promote")
+		return nil
+	}
+
+	toIndex.Published = time.Now().Unix()
+	err = writeIndex(unsignedIndexPath(promoteTo), toIndex)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("promoted %d components to %s, sign it with: uptool sign --channel %s --key <key file>\n", promoted, unsignedIndexPath(promoteTo), promoteTo)
+	return nil
+}
+
+// promoteVersions copies the versions of the given identifiers, or all, and their hashes from one index to the other. Versions are never downgraded.
+func promoteVersions(fromIndex, toIndex *updates.Index, identifiers []string) (promoted int, err error) {
+	if len(identifiers) == 0 {
+		for identifier := range fromIndex.Versions {
+			identifiers = append(identifiers, identifier)
+		}
+		sort.Strings(identifiers)
+	}
+
+	for _, identifier := range identifiers {
+		version, ok := fromIndex.Versions[identifier]
+		if !ok {
+			return 0, fmt.Errorf("%s is not in %s", identifier, unsignedIndexPath(promoteFrom))
+		}
+		oldVersion, ok := toIndex.Versions[identifier]
+		if ok && updates.CompareVersions(version, oldVersion) <= 0 {
+			fmt.Printf("%s: %s already has v%s\n", identifier, promoteTo, oldVersion)
+			continue
+		}
+
+		versionedPath := updates.GetVersionedPath(identifier, version)
+		hash, ok := fromIndex.Hashes[versionedPath]
+		if !ok {
+			return 0, fmt.Errorf("%s has no hash in %s", versionedPath, unsignedIndexPath(promoteFrom))
+		}
+
+		if oldVersion != "" {
+			delete(toIndex.Hashes, updates.GetVersionedPath(identifier, oldVersion))
+			fmt.Printf("%s: v%s -> v%s\n", identifier, oldVersion, version)
+		} else {
+			fmt.Printf("%s: v%s (new)\n", identifier, version)
+		}
+		toIndex.Versions[identifier] = version
+		toIndex.Hashes[versionedPath] = hash
+		promoted++
+	}
+	return promoted, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+func testBetaIndex() *updates.Index {
+	return &updates.Index{
+		Versions: map[string]string{
+			"all/a": "1.1.0",
+			"all/b": "2.0.0",
+			"all/c": "0.9.0",
+		},
+		Hashes: map[string]string{
+			"all/a_v1-1-0": "a11",
+			"all/b_v2-0-0": "b20",
+			"all/c_v0-9-0": "c09",
+		},
+	}
+}
+
+func testStableIndex() *updates.Index {
+	return &updates.Index{
+		Versions: map[string]string{
+			"all/a": "1.0.0",
+			"all/c": "1.0.0",
+		},
+		Hashes: map[string]string{
+			"all/a_v1-0-0": "a10",
+			"all/c_v1-0-0": "c10",
+		},
+	}
+}
+
+func TestPromoteVersions(t *testing.T) {
+	// promote all
+	stable := testStableIndex()
+	promoted, err := promoteVersions(testBetaIndex(), stable, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoted != 2 {
+		t.Errorf("expected 2 promoted components, got %d", promoted)
+	}
+	expected := &updates.Index{
+		Versions: map[string]string{
+			"all/a": "1.1.0",
+			"all/b": "2.0.0",
+			"all/c": "1.0.0", // never downgrade
+		},
+		Hashes: map[string]string{
+			"all/a_v1-1-0": "a11",
+			"all/b_v2-0-0": "b20",
+			"all/c_v1-0-0": "c10",
+		},
+	}
+	if !reflect.DeepEqual(stable, expected) {
+		t.Errorf("unexpected index after promotion: %+v", stable)
+	}
+
+	// promote selected
+	stable = testStableIndex()
+	promoted, err = promoteVersions(testBetaIndex(), stable, []string{"all/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoted != 1 || stable.Versions["all/a"] != "1.0.0" || stable.Versions["all/b"] != "2.0.0" {
+		t.Errorf("only all/b should have been promoted, got %+v", stable.Versions)
+	}
+
+	// unknown identifier
+	_, err = promoteVersions(testBetaIndex(), testStableIndex(), []string{"all/unknown"})
+	if err == nil {
+		t.Error("promoting an unknown component should fail")
+	}
+
+	// missing hash
+	beta := testBetaIndex()
+	delete(beta.Hashes, "all/a_v1-1-0")
+	_, err = promoteVersions(beta, testStableIndex(), []string{"all/a"})
+	if err == nil {
+		t.Error("promoting a component without hash should fail")
+	}
+}
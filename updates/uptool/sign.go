@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+var (
+	signKeyPath string
+	signKeyID   string
+	signChannel string
+)
+
+func init() {
+	rootCmd.AddCommand(signCmd)
+	signCmd.Flags().StringVar(&signKeyPath, "key", "", "path to the private key file")
+	signCmd.Flags().StringVar(&signKeyID, "key-id", "", "ID of the key, defaults to the name of the key file")
+	signCmd.Flags().StringVar(&signChannel, "channel", updates.ChannelStable, "release channel of the index: stable, beta or dev")
+
+	rootCmd.AddCommand(keygenCmd)
+}
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign the unsigned index of the channel, eg. stable.json, and write the signed index, eg. stable.signed.json. If there is no unsigned index, it is created by scanning the current directory. A previous signed index is replaced, unless it signs the same index, then the signature is added to it.",
+	RunE:  sign,
+}
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen <key ID>",
+	Short: "Generate a new signing key and print the public key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  keygen,
+}
+
+func sign(cmd *cobra.Command, args []string) error {
+	if signKeyPath == "" {
+		return errors.New("missing --key")
+	}
+	key, err := loadPrivateKey(signKeyPath)
+	if err != nil {
+		return err
+	}
+	keyID := signKeyID
+	if keyID == "" {
+		keyID = strings.TrimSuffix(filepath.Base(signKeyPath), ".key")
+	}
+
+	err = checkChannel(signChannel)
+	if err != nil {
+		return err
+	}
+
+	// always sign the unsigned index, as the signed one may be outdated
+	indexPath := unsignedIndexPath(signChannel)
+	index, err := loadIndex(indexPath)
+	if os.IsNotExist(err) {
+		index, err = buildIndex()
+		if err == nil {
+			err = writeIndex(indexPath, index)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	signedPath := signedIndexPath(signChannel)
+	si, err := signIndex(index, signedPath, keyID, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(si, "", " ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(signedPath, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("signed %s with %s and wrote %s (%d signatures)\n", indexPath, keyID, signedPath, len(si.Signatures))
+	return nil
+}
+
+// signIndex signs the index. If the signed index at signedPath signs the same index with other keys, the signature is added to it, else it is replaced.
+func signIndex(index *updates.Index, signedPath, keyID string, key ed25519.PrivateKey) (*updates.SignedIndex, error) {
+	data, err := ioutil.ReadFile(signedPath)
+	if err == nil {
+		si, err := updates.ParseSignedIndex(data)
+		if err == nil && sameIndex(si, index) && !signedBy(si, keyID) {
+			err = si.AddSignature(keyID, key)
+			if err != nil {
+				return nil, err
+			}
+			return si, nil
+		}
+	}
+
+	return updates.SignIndex(index, keyID, key)
+}
+
+func sameIndex(si *updates.SignedIndex, index *updates.Index) bool {
+	signed := &updates.Index{}
+	err := json.Unmarshal(si.Index, signed)
+	if err != nil {
+		return false
+	}
+	expected, err := json.Marshal(index)
+	if err != nil {
+		return false
+	}
+	actual, err := json.Marshal(signed)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(expected, actual)
+}
+
+func signedBy(si *updates.SignedIndex, keyID string) bool {
+	for _, sig := range si.Signatures {
+		if sig.KeyID == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// buildIndex scans the current directory for the latest versions and hashes their files.
+func buildIndex() (*updates.Index, error) {
+	latest, err := updates.ScanForLatest(".", true)
+	if err != nil {
+		return nil, err
+	}
+
+	index := updates.NewIndex(latest)
+	for identifier, version := range latest {
+		versionedPath := updates.GetVersionedPath(identifier, version)
+		hash, err := updates.HashFile(filepath.FromSlash(versionedPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %s", versionedPath, err)
+		}
+		index.Hashes[versionedPath] = hash
+	}
+	return index, nil
+}
+
+func keygen(cmd *cobra.Command, args []string) error {
+	keyID := args[0]
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	keyPath := keyID + ".key"
+	err = ioutil.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(privateKey)), 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("private key written to %s, keep it secret\n", keyPath)
+	fmt.Printf("public key: %s\n", updates.FormatPublicKey(keyID, publicKey))
+	return nil
+}
+
+func loadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %s", err)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid private key size")
+	}
+	return ed25519.PrivateKey(key), nil
+}
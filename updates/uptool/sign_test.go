@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+func TestSignIndex(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	signedPath := filepath.Join(tmpDir, "stable.signed.json")
+
+	publicKey1, privateKey1, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey2, privateKey2, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]ed25519.PublicKey{"key1": publicKey1, "key2": publicKey2}
+
+	write := func(si *updates.SignedIndex) {
+		data, err := json.Marshal(si)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(signedPath, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sign old index
+	oldIndex := updates.NewIndex(map[string]string{"all/a": "1.0.0"})
+	si, err := signIndex(oldIndex, signedPath, "key1", privateKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(si)
+
+	// same index is signed with another key
+	si, err = signIndex(oldIndex, signedPath, "key2", privateKey2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(si.Signatures) != 2 {
+		t.Fatalf("expected signature to be added, got %d signatures", len(si.Signatures))
+	}
+	if _, err := si.Verify(keys); err != nil {
+		t.Fatal(err)
+	}
+	write(si)
+
+	// a new index must replace the stale signed index
+	newIndex := updates.NewIndex(map[string]string{"all/a": "1.1.0"})
+	si, err = signIndex(newIndex, signedPath, "key1", privateKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(si.Signatures) != 1 {
+		t.Errorf("expected stale signatures to be dropped, got %d signatures", len(si.Signatures))
+	}
+	verified, err := si.Verify(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Versions["all/a"] != "1.1.0" {
+		t.Errorf("expected the new index to be signed, got v%s", verified.Versions["all/a"])
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/Safing/portmaster/updates"
+)
+
+var (
+	verifyKeys string
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVar(&verifyKeys, "keys", "", "trusted public keys as <key ID>:<base64 public key>, comma separated; defaults to the pinned keys")
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [signed index...]",
+	Short: "Verify the signatures of signed indexes and that all listed files exist in the current directory with matching hashes",
+	RunE:  verify,
+}
+
+func verify(cmd *cobra.Command, args []string) error {
+	indexPaths := args
+	if len(indexPaths) == 0 {
+		indexPaths = []string{"stable.signed.json"}
+	}
+
+	var keys map[string]ed25519.PublicKey
+	var err error
+	if verifyKeys != "" {
+		keys, err = updates.ParsePublicKeys(verifyKeys)
+	} else {
+		keys, err = updates.TrustedKeys()
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, indexPath := range indexPaths {
+		failed += verifyIndex(indexPath, keys)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verification failed with %d errors", failed)
+	}
+	return nil
+}
+
+// verifyIndex verifies a signed index and the files listed in it and returns the amount of failures.
+func verifyIndex(indexPath string, keys map[string]ed25519.PublicKey) (failed int) {
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		fmt.Printf("%s: %s\n", indexPath, err)
+		return 1
+	}
+	si, err := updates.ParseSignedIndex(data)
+	if err != nil {
+		fmt.Printf("%s: failed to parse: %s\n", indexPath, err)
+		return 1
+	}
+	index, err := si.Verify(keys)
+	if err != nil {
+		fmt.Printf("%s: %s\n", indexPath, err)
+		return 1
+	}
+	fmt.Printf("%s: signature ok\n", indexPath)
+
+	// every listed version needs a hash
+	for identifier, version := range index.Versions {
+		versionedPath := updates.GetVersionedPath(identifier, version)
+		if _, ok := index.Hashes[versionedPath]; !ok {
+			fmt.Printf("%s: no hash in index\n", versionedPath)
+			failed++
+		}
+	}
+
+	var versionedPaths []string
+	for versionedPath := range index.Hashes {
+		versionedPaths = append(versionedPaths, versionedPath)
+	}
+	sort.Strings(versionedPaths)
+
+	for _, versionedPath := range versionedPaths {
+		hash, err := updates.HashFile(filepath.FromSlash(versionedPath))
+		switch {
+		case os.IsNotExist(err):
+			fmt.Printf("%s: missing\n", versionedPath)
+			failed++
+		case err != nil:
+			fmt.Printf("%s: failed to hash: %s\n", versionedPath, err)
+			failed++
+		case hash != index.Hashes[versionedPath]:
+			fmt.Printf("%s: hash mismatch\n", versionedPath)
+			failed++
+		default:
+			fmt.Printf("%s: ok\n", versionedPath)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,93 @@
+package updates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var versionStringRegex = regexp.MustCompile(`^([0-9]+)\.([0-9]+)\.([0-9]+)(b?)$`)
+
+// Version is a semantic version as used by the update system, eg. 1.2.3 or 1.2.3b for a beta build.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Beta marks a beta build. Beta builds are published after the release they are based on and sort after it, eg. 1.2.3b follows 1.2.3.
+	Beta bool
+}
+
+// ParseVersion parses a version string.
+func ParseVersion(version string) (*Version, error) {
+	matches := versionStringRegex.FindStringSubmatch(version)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid version: %s", version)
+	}
+
+	v := &Version{
+		Beta: matches[4] == "b",
+	}
+	var err error
+	for i, segment := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		*segment, err = strconv.Atoi(matches[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s: %s", version, err)
+		}
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1, if the version is lower, equal or higher than the given version.
+func (v *Version) Compare(other *Version) int {
+	switch {
+	case v.Major != other.Major:
+		return compareInt(v.Major, other.Major)
+	case v.Minor != other.Minor:
+		return compareInt(v.Minor, other.Minor)
+	case v.Patch != other.Patch:
+		return compareInt(v.Patch, other.Patch)
+	case v.Beta == other.Beta:
+		return 0
+	case v.Beta:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func (v *Version) String() string {
+	if v.Beta {
+		return fmt.Sprintf("%d.%d.%db", v.Major, v.Minor, v.Patch)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// CompareVersions compares two version strings like Version.Compare. Invalid versions sort before valid versions.
+func CompareVersions(a, b string) int {
+	versionA, errA := ParseVersion(a)
+	versionB, errB := ParseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		default:
+			return 0
+		}
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	default:
+		return versionA.Compare(versionB)
+	}
+}
+
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
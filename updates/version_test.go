@@ -0,0 +1,97 @@
+package updates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"0.2.10", "0.2.9", 1},
+		{"10.0.0", "9.9.9", 1},
+		{"1.2.3b", "1.2.3", 1},
+		{"1.2.3b", "1.2.4", -1},
+		{"invalid", "0.0.1", -1},
+	}
+
+	for _, tc := range testCases {
+		result := CompareVersions(tc.a, tc.b)
+		if result != tc.expected {
+			t.Errorf("comparing %s to %s should return %d, got %d", tc.a, tc.b, tc.expected, result)
+		}
+	}
+
+	_, err := ParseVersion("1.2")
+	if err == nil {
+		t.Error("1.2 should be invalid")
+	}
+}
+
+func TestScanForLatestMultiDigit(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "testing_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{"asset_v1-9-0", "asset_v1-10-0", "asset_v1-2-30"} {
+		err = ioutil.WriteFile(filepath.Join(tmpDir, name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := ScanForLatest(tmpDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest["asset"] != "1.10.0" {
+		t.Errorf("expected 1.10.0, got %s", latest["asset"])
+	}
+}
+
+func TestSelectVersion(t *testing.T) {
+	oldChannel, oldPinned := updateChannel, pinnedVersions
+	updatesLock.Lock()
+	oldStable, oldBeta, oldDev := stableUpdates, betaUpdates, devUpdates
+	stableUpdates = map[string]string{"linux_amd64/core/portmaster": "0.2.9"}
+	betaUpdates = map[string]string{"linux_amd64/core/portmaster": "0.2.10"}
+	devUpdates = map[string]string{"linux_amd64/core/portmaster": "0.2.8"}
+	updatesLock.Unlock()
+	defer func() {
+		updateChannel, pinnedVersions = oldChannel, oldPinned
+		updatesLock.Lock()
+		stableUpdates, betaUpdates, devUpdates = oldStable, oldBeta, oldDev
+		updatesLock.Unlock()
+	}()
+
+	testSelect := func(expectedVersion, expectedChannel string) {
+		updatesLock.RLock()
+		defer updatesLock.RUnlock()
+		version, channel, ok := selectVersion("linux_amd64/core/portmaster")
+		if !ok || version != expectedVersion || channel != expectedChannel {
+			t.Errorf("expected %s from %s, got %s from %s", expectedVersion, expectedChannel, version, channel)
+		}
+	}
+
+	updateChannel = func() string { return ChannelStable }
+	testSelect("0.2.9", ChannelStable)
+
+	updateChannel = func() string { return ChannelBeta }
+	testSelect("0.2.10", ChannelBeta)
+
+	// an older dev version must not replace newer releases
+	updateChannel = func() string { return ChannelDev }
+	testSelect("0.2.10", ChannelBeta)
+
+	pinnedVersions = func() []string { return []string{"core/portmaster@0.2.5"} }
+	testSelect("0.2.5", channelPinned)
+}